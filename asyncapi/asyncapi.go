@@ -0,0 +1,366 @@
+// Package asyncapi generates AsyncAPI documents describing the topology
+// and the consumers of an application.
+//
+// For more information about AsyncAPI,
+// please visit https://www.asyncapi.com/docs/specifications/v2.2.0.
+package asyncapi
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/ar3s3ru/go-carrot/handler/router"
+	"github.com/ar3s3ru/go-carrot/listener"
+	"github.com/ar3s3ru/go-carrot/topology"
+
+	"github.com/streadway/amqp"
+)
+
+// Versions of the AsyncAPI specification and AMQP bindings used
+// by the generated documents.
+const (
+	Version            = "2.2.0"
+	AMQPBindingVersion = "0.2.0"
+)
+
+// Document is an AsyncAPI document.
+//
+// Channels are named after the kind and the name of the exchange or queue
+// they describe, since exchanges and queues can share the same name:
+// use ExchangeChannel and QueueChannel to get the channel names.
+type Document struct {
+	AsyncAPI string             `json:"asyncapi"`
+	Info     Info               `json:"info"`
+	Channels map[string]Channel `json:"channels"`
+}
+
+// ExchangeChannel returns the name of the Channel describing
+// the specified exchange.
+func ExchangeChannel(name string) string { return "exchange/" + name }
+
+// QueueChannel returns the name of the Channel describing
+// the specified queue.
+func QueueChannel(name string) string { return "queue/" + name }
+
+// Info contains metadata about the application described by the Document.
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Channel describes either an AMQP exchange or queue.
+//
+// Consumers of a queue are described by the publish operation of the queue
+// channel, since AsyncAPI operations are described from the point of view
+// of the other applications, which publish messages the consumers receive.
+// Since a channel has a single publish operation, multiple consumers
+// of the same queue are described by its messages.
+type Channel struct {
+	Description string          `json:"description,omitempty"`
+	Publish     *Operation      `json:"publish,omitempty"`
+	Bindings    ChannelBindings `json:"bindings"`
+
+	// Arguments and routes are not supported by the AMQP channel bindings,
+	// hence they're specified as extensions.
+	Arguments amqp.Table `json:"x-arguments,omitempty"`
+	Routes    []Route    `json:"x-bindings,omitempty"`
+}
+
+// Route is a binding from a source exchange to the exchange or queue
+// described by a Channel.
+type Route struct {
+	Exchange   string `json:"exchange"`
+	RoutingKey string `json:"routingKey"`
+}
+
+// ChannelBindings contains the protocol-specific information of a Channel.
+type ChannelBindings struct {
+	AMQP AMQPChannelBinding `json:"amqp"`
+}
+
+// AMQPChannelBinding describes the AMQP exchange or queue of a Channel.
+type AMQPChannelBinding struct {
+	Is             string        `json:"is"`
+	Exchange       *AMQPExchange `json:"exchange,omitempty"`
+	Queue          *AMQPQueue    `json:"queue,omitempty"`
+	BindingVersion string        `json:"bindingVersion"`
+}
+
+// AMQPExchange describes an AMQP exchange.
+type AMQPExchange struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	Durable    bool   `json:"durable"`
+	AutoDelete bool   `json:"autoDelete"`
+	VHost      string `json:"vhost"`
+}
+
+// AMQPQueue describes an AMQP queue.
+type AMQPQueue struct {
+	Name       string `json:"name"`
+	Durable    bool   `json:"durable"`
+	Exclusive  bool   `json:"exclusive"`
+	AutoDelete bool   `json:"autoDelete"`
+	VHost      string `json:"vhost"`
+}
+
+// Operation describes the consumers of a queue.
+//
+// A single consumer is described by the Operation itself, identified by
+// the consumer tag, while multiple consumers are described by one of
+// the messages of the Operation each, identified by the queue name.
+type Operation struct {
+	OperationID string   `json:"operationId"`
+	Summary     string   `json:"summary,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Message     *Message `json:"message,omitempty"`
+}
+
+// Message describes the messages received by a consumer, or by one of
+// the consumers of a queue, among the ones in OneOf.
+type Message struct {
+	Name        string          `json:"name,omitempty"`
+	Title       string          `json:"title,omitempty"`
+	Description string          `json:"description,omitempty"`
+	Payload     json.RawMessage `json:"payload,omitempty"`
+	OneOf       []Message       `json:"oneOf,omitempty"`
+}
+
+// Consumer is implemented by those Listeners able to describe the consumer
+// they start, such as consumer.Listener.
+type Consumer interface {
+	Queue() string
+	Tag() string
+	Title() string
+	Description() string
+}
+
+type generator struct {
+	vhost     string
+	declarers []topology.Declarer
+	listeners []listener.Listener
+	routers   []*router.Mux
+	payloads  map[string]json.RawMessage
+}
+
+// Generate generates a new AsyncAPI document, describing the topology,
+// the consumers and the router bindings specified with the options.
+//
+// Every exchange and queue is described by a Channel, named after it.
+// Consumers started by the Listeners are described as publish operations
+// of the Channel of their queue; router bindings without a Listener are
+// assumed to be named after the queue they consume from.
+func Generate(info Info, options ...Option) (Document, error) {
+	gen := generator{
+		vhost:    "/",
+		payloads: make(map[string]json.RawMessage),
+	}
+
+	for _, option := range options {
+		if option == nil {
+			continue
+		}
+
+		option(&gen)
+	}
+
+	doc := Document{
+		AsyncAPI: Version,
+		Info:     info,
+		Channels: make(map[string]Channel),
+	}
+
+	for _, declarer := range gen.declarers {
+		if err := gen.describeTopology(doc.Channels, declarer); err != nil {
+			return Document{}, err
+		}
+	}
+
+	described := make(map[string]bool)
+
+	for _, l := range gen.listeners {
+		listener.Walk(l, func(l listener.Listener) {
+			if consumer, ok := l.(Consumer); ok {
+				gen.describeConsumer(doc.Channels, consumer)
+				described[consumer.Tag()] = true
+			}
+		})
+	}
+
+	for _, mux := range gen.routers {
+		for _, binding := range mux.Bindings() {
+			if !described[binding] {
+				gen.describeConsumer(doc.Channels, boundConsumer(binding))
+			}
+		}
+	}
+
+	return doc, nil
+}
+
+func (gen generator) describeTopology(channels map[string]Channel, declarer topology.Declarer) error {
+	spec, err := topology.Describe(declarer)
+	if err != nil {
+		return fmt.Errorf("asyncapi.Generate: failed to describe topology, %w", err)
+	}
+
+	for _, exchange := range spec.Exchanges {
+		channel := channels[ExchangeChannel(exchange.Name)]
+		channel.Arguments = exchange.Arguments
+		channel.Bindings.AMQP = AMQPChannelBinding{
+			Is: "routingKey",
+			Exchange: &AMQPExchange{
+				Name:       exchange.Name,
+				Type:       exchange.Kind,
+				Durable:    exchange.Durable,
+				AutoDelete: exchange.AutoDelete,
+				VHost:      gen.vhost,
+			},
+			BindingVersion: AMQPBindingVersion,
+		}
+
+		channels[ExchangeChannel(exchange.Name)] = channel
+	}
+
+	for _, q := range spec.Queues {
+		channel := channels[QueueChannel(q.Name)]
+		channel.Description = q.Description
+		channel.Arguments = q.Arguments
+		channel.Bindings.AMQP = AMQPChannelBinding{
+			Is: "queue",
+			Queue: &AMQPQueue{
+				Name:       q.Name,
+				Durable:    q.Durable,
+				Exclusive:  q.Exclusive,
+				AutoDelete: q.AutoDelete,
+				VHost:      gen.vhost,
+			},
+			BindingVersion: AMQPBindingVersion,
+		}
+
+		channels[QueueChannel(q.Name)] = channel
+	}
+
+	for _, binding := range spec.Bindings {
+		name := QueueChannel(binding.Destination)
+		if binding.DestinationType == topology.ExchangeDestination {
+			name = ExchangeChannel(binding.Destination)
+		}
+
+		channel := channels[name]
+		channel.Routes = append(channel.Routes, Route{
+			Exchange:   binding.Source,
+			RoutingKey: binding.RoutingKey,
+		})
+
+		channels[name] = channel
+	}
+
+	return nil
+}
+
+func (gen generator) describeConsumer(channels map[string]Channel, consumer Consumer) {
+	name := QueueChannel(consumer.Queue())
+
+	channel, ok := channels[name]
+	if !ok {
+		channel.Bindings.AMQP = AMQPChannelBinding{
+			Is:             "queue",
+			Queue:          &AMQPQueue{Name: consumer.Queue(), VHost: gen.vhost},
+			BindingVersion: AMQPBindingVersion,
+		}
+	}
+
+	operation := &Operation{
+		OperationID: consumer.Tag(),
+		Summary:     consumer.Title(),
+		Description: consumer.Description(),
+	}
+
+	if payload, ok := gen.payloads[consumer.Tag()]; ok {
+		operation.Message = &Message{Payload: payload}
+	}
+
+	channel.Publish = mergeOperations(consumer.Queue(), channel.Publish, operation)
+	channels[name] = channel
+}
+
+// mergeOperations describes the consumers of a queue already described
+// by the existing Operation, if any, together with the consumer described
+// by the provided one, as one of its messages each.
+func mergeOperations(queue string, existing, operation *Operation) *Operation {
+	if existing == nil {
+		return operation
+	}
+
+	if existing.Message == nil || existing.Message.OneOf == nil {
+		existing = &Operation{
+			OperationID: queue,
+			Message:     &Message{OneOf: []Message{consumerMessage(existing)}},
+		}
+	}
+
+	existing.Message.OneOf = append(existing.Message.OneOf, consumerMessage(operation))
+
+	return existing
+}
+
+// consumerMessage describes the consumer described by the Operation
+// as a Message.
+func consumerMessage(operation *Operation) Message {
+	message := Message{
+		Name:        operation.OperationID,
+		Title:       operation.Summary,
+		Description: operation.Description,
+	}
+
+	if operation.Message != nil {
+		message.Payload = operation.Message.Payload
+	}
+
+	return message
+}
+
+// boundConsumer describes a router binding without a Listener.
+type boundConsumer string
+
+func (c boundConsumer) Queue() string     { return string(c) }
+func (c boundConsumer) Tag() string       { return string(c) }
+func (boundConsumer) Title() string       { return "" }
+func (boundConsumer) Description() string { return "" }
+
+// Option is an optional functionality that can be added to the generated
+// Document by the Generate function.
+type Option func(*generator)
+
+// Topology describes the exchanges, queues and bindings declared by
+// the provided Declarer. Multiple calls of this option are supported.
+func Topology(declarer topology.Declarer) Option {
+	return func(gen *generator) { gen.declarers = append(gen.declarers, declarer) }
+}
+
+// Listener describes the consumers started by the provided Listener,
+// including all the Listeners it wraps, such as in listener.Sink.
+// Multiple calls of this option are supported.
+func Listener(l listener.Listener) Option {
+	return func(gen *generator) { gen.listeners = append(gen.listeners, l) }
+}
+
+// Router describes the consumers bound to a message handler in the provided
+// router.Mux. Multiple calls of this option are supported.
+func Router(mux *router.Mux) Option {
+	return func(gen *generator) { gen.routers = append(gen.routers, mux) }
+}
+
+// Payload specifies the JSON Schema of the messages received by the consumer
+// identified by the specified tag.
+func Payload(tag string, schema json.RawMessage) Option {
+	return func(gen *generator) { gen.payloads[tag] = schema }
+}
+
+// VHost specifies the AMQP virtual host of exchanges and queues.
+// If not specified, "/" is used.
+func VHost(vhost string) Option {
+	return func(gen *generator) { gen.vhost = vhost }
+}
@@ -0,0 +1,103 @@
+package asyncapi_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/ar3s3ru/go-carrot/asyncapi"
+	"github.com/ar3s3ru/go-carrot/handler"
+	"github.com/ar3s3ru/go-carrot/handler/router"
+	"github.com/ar3s3ru/go-carrot/listener"
+	"github.com/ar3s3ru/go-carrot/listener/consumer"
+	"github.com/ar3s3ru/go-carrot/topology"
+	"github.com/ar3s3ru/go-carrot/topology/exchange"
+	"github.com/ar3s3ru/go-carrot/topology/exchange/kind"
+	"github.com/ar3s3ru/go-carrot/topology/queue"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate(t *testing.T) {
+	noop := handler.Func(func(context.Context, amqp.Delivery) error { return nil })
+
+	mux := router.New()
+	mux.Bind("orders-created", noop)
+	mux.Bind("audit", noop)
+
+	doc, err := asyncapi.Generate(
+		asyncapi.Info{Title: "orders", Version: "1.0.0"},
+		asyncapi.Topology(topology.All(
+			exchange.Declare("orders", exchange.Kind(kind.Direct), exchange.Durable),
+			// Exchanges and queues can share the same name.
+			exchange.Declare("audit", exchange.Kind(kind.Fanout)),
+			queue.Declare("audit", queue.BindTo("audit", "")),
+			queue.Declare("orders-created",
+				queue.Description("Orders created by customers"),
+				queue.Durable,
+				queue.BindTo("orders", "created"),
+				queue.DeadLetterWithQueue("orders-dlx", "created", queue.Declare(
+					"orders-created-dlq",
+					queue.Description("Orders failed to be processed"),
+				)),
+			),
+		)),
+		asyncapi.Listener(listener.Sink(
+			consumer.Listen("orders-created",
+				consumer.Title("Order created"),
+				consumer.Description("Reserves the stock for new orders"),
+			),
+			consumer.Listen("orders-created-dlq"),
+			// Multiple consumers of the same queue are described by their messages.
+			consumer.Listen("orders-created-dlq", consumer.Tag("orders-created-dlq-alert"), consumer.Title("Alert")),
+		)),
+		asyncapi.Router(mux),
+		asyncapi.Payload("orders-created", json.RawMessage(`{"type":"object"}`)),
+	)
+
+	require.NoError(t, err)
+	assert.Equal(t, asyncapi.Version, doc.AsyncAPI)
+	assert.Len(t, doc.Channels, 5)
+
+	orders := doc.Channels[asyncapi.ExchangeChannel("orders")]
+	assert.Equal(t, "routingKey", orders.Bindings.AMQP.Is)
+	assert.Equal(t, &asyncapi.AMQPExchange{Name: "orders", Type: "direct", Durable: true, VHost: "/"}, orders.Bindings.AMQP.Exchange)
+
+	created := doc.Channels[asyncapi.QueueChannel("orders-created")]
+	assert.Equal(t, "Orders created by customers", created.Description)
+	assert.Equal(t, "queue", created.Bindings.AMQP.Is)
+	assert.Equal(t, []asyncapi.Route{{Exchange: "orders", RoutingKey: "created"}}, created.Routes)
+	assert.Equal(t, "orders-dlx", created.Arguments["x-dead-letter-exchange"])
+	assert.Equal(t, &asyncapi.Operation{
+		OperationID: "orders-created",
+		Summary:     "Order created",
+		Description: "Reserves the stock for new orders",
+		Message:     &asyncapi.Message{Payload: json.RawMessage(`{"type":"object"}`)},
+	}, created.Publish)
+
+	dlq := doc.Channels[asyncapi.QueueChannel("orders-created-dlq")]
+	assert.Equal(t, "Orders failed to be processed", dlq.Description)
+	assert.Equal(t, []asyncapi.Route{{Exchange: "orders-dlx", RoutingKey: "created"}}, dlq.Routes)
+	assert.Equal(t, &asyncapi.Operation{
+		OperationID: "orders-created-dlq",
+		Message: &asyncapi.Message{OneOf: []asyncapi.Message{
+			{Name: "orders-created-dlq"},
+			{Name: "orders-created-dlq-alert", Title: "Alert"},
+		}},
+	}, dlq.Publish)
+
+	audit := doc.Channels[asyncapi.ExchangeChannel("audit")]
+	assert.Equal(t, "routingKey", audit.Bindings.AMQP.Is)
+	assert.Nil(t, audit.Publish)
+
+	// Router bindings without a Listener are described too.
+	auditQueue := doc.Channels[asyncapi.QueueChannel("audit")]
+	assert.Equal(t, "queue", auditQueue.Bindings.AMQP.Is)
+	assert.Equal(t, []asyncapi.Route{{Exchange: "audit"}}, auditQueue.Routes)
+	assert.Equal(t, &asyncapi.Operation{OperationID: "audit"}, auditQueue.Publish)
+
+	_, err = json.Marshal(doc)
+	assert.NoError(t, err)
+}
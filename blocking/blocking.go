@@ -0,0 +1,192 @@
+// Package blocking tracks the connection.blocked and connection.unblocked
+// notifications sent by the AMQP broker when it hits a resource alarm,
+// such as a memory or disk alarm, during which publishing hangs.
+//
+// Use a Tracker with the publisher.FailWhenBlocked or publisher.WaitWhenBlocked
+// options to apply back-pressure to publishers, instead of having them
+// hang forever:
+//
+//	tracker := blocking.Watch(conn, blocking.OnBlocked(func(reason string) {
+//		logger.Warn("connection blocked", "reason", reason)
+//	}))
+//
+//	pub, err := publisher.New(ch, publisher.WaitWhenBlocked(tracker))
+package blocking
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/streadway/amqp"
+)
+
+// ErrBlocked is returned when publishing on a connection blocked
+// by the AMQP broker.
+var ErrBlocked = errors.New("blocking: connection blocked by the broker")
+
+// waitError is returned by Tracker.Wait when the context is done before
+// the connection gets unblocked: it wraps the context error, and matches
+// ErrBlocked as well.
+type waitError struct {
+	reason string
+	err    error
+}
+
+func (err *waitError) Error() string {
+	return fmt.Sprintf("blocking.Tracker: failed to wait for the connection to be unblocked (%s), %s: %s",
+		err.reason, ErrBlocked, err.err)
+}
+
+func (err *waitError) Unwrap() error        { return err.err }
+func (err *waitError) Is(target error) bool { return target == ErrBlocked }
+
+// Connection is the connection interface the Tracker uses to receive
+// blocking notifications, implemented by *amqp.Connection.
+type Connection interface {
+	NotifyBlocked(chan amqp.Blocking) chan amqp.Blocking
+}
+
+// Tracker tracks whether a connection is blocked by the AMQP broker,
+// safe for concurrent use.
+//
+// Use Watch to create a new Tracker instance.
+type Tracker struct {
+	mx        sync.RWMutex
+	blocked   bool
+	reason    string
+	unblocked chan struct{}
+
+	onBlocked   []func(reason string)
+	onUnblocked []func()
+}
+
+// Watch starts tracking the blocking notifications of the provided connection,
+// until the connection gets closed.
+func Watch(conn Connection, options ...Option) *Tracker {
+	tracker := &Tracker{unblocked: make(chan struct{})}
+	close(tracker.unblocked)
+
+	for _, option := range options {
+		if option == nil {
+			continue
+		}
+
+		option(tracker)
+	}
+
+	go tracker.watch(conn.NotifyBlocked(make(chan amqp.Blocking, 1)))
+
+	return tracker
+}
+
+// Option is an optional functionality that can be added to the Tracker
+// that is being initialized by the Watch factory method.
+type Option func(*Tracker)
+
+// OnBlocked specifies a function called when the connection gets blocked,
+// with the reason reported by the AMQP broker.
+//
+// Multiple calls of this option are supported.
+func OnBlocked(fn func(reason string)) Option {
+	return func(tracker *Tracker) { tracker.onBlocked = append(tracker.onBlocked, fn) }
+}
+
+// OnUnblocked specifies a function called when the connection gets unblocked.
+//
+// Multiple calls of this option are supported.
+func OnUnblocked(fn func()) Option {
+	return func(tracker *Tracker) { tracker.onUnblocked = append(tracker.onUnblocked, fn) }
+}
+
+func (t *Tracker) watch(blocking <-chan amqp.Blocking) {
+	for notification := range blocking {
+		if !t.set(notification) {
+			continue
+		}
+
+		if notification.Active {
+			for _, fn := range t.onBlocked {
+				fn(notification.Reason)
+			}
+		} else {
+			for _, fn := range t.onUnblocked {
+				fn()
+			}
+		}
+	}
+
+	// Blocking notifications channel gets closed when the connection shuts
+	// down: publishing fails from now on, so there's no point in waiting.
+	t.set(amqp.Blocking{Active: false})
+}
+
+// set updates the state of the Tracker, and reports whether it changed.
+func (t *Tracker) set(notification amqp.Blocking) bool {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+
+	if t.blocked == notification.Active {
+		return false
+	}
+
+	t.blocked = notification.Active
+	t.reason = notification.Reason
+
+	if t.blocked {
+		t.unblocked = make(chan struct{})
+	} else {
+		close(t.unblocked)
+	}
+
+	return true
+}
+
+// Blocked reports whether the connection is currently blocked.
+func (t *Tracker) Blocked() bool {
+	t.mx.RLock()
+	defer t.mx.RUnlock()
+
+	return t.blocked
+}
+
+// Reason returns the reason reported by the AMQP broker for blocking
+// the connection, if currently blocked.
+func (t *Tracker) Reason() string {
+	t.mx.RLock()
+	defer t.mx.RUnlock()
+
+	return t.reason
+}
+
+// Check returns an error wrapping ErrBlocked if the connection
+// is currently blocked.
+func (t *Tracker) Check() error {
+	t.mx.RLock()
+	defer t.mx.RUnlock()
+
+	if t.blocked {
+		return fmt.Errorf("blocking.Tracker: %s, %w", t.reason, ErrBlocked)
+	}
+
+	return nil
+}
+
+// Wait waits until the connection is not blocked, returning immediately
+// if it's not currently blocked.
+//
+// An error wrapping both ErrBlocked and the context error is returned
+// if the context is done before the connection gets unblocked.
+func (t *Tracker) Wait(ctx context.Context) error {
+	t.mx.RLock()
+	unblocked, reason := t.unblocked, t.reason
+	t.mx.RUnlock()
+
+	select {
+	case <-unblocked:
+		return nil
+	case <-ctx.Done():
+		return &waitError{reason: reason, err: ctx.Err()}
+	}
+}
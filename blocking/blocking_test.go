@@ -0,0 +1,87 @@
+package blocking_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ar3s3ru/go-carrot/blocking"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type connection struct {
+	blocking chan amqp.Blocking
+}
+
+func (conn *connection) NotifyBlocked(ch chan amqp.Blocking) chan amqp.Blocking {
+	conn.blocking = ch
+	return ch
+}
+
+func TestTracker(t *testing.T) {
+	conn := new(connection)
+
+	var (
+		blocked   = make(chan string, 1)
+		unblocked = make(chan struct{}, 1)
+	)
+
+	tracker := blocking.Watch(conn,
+		blocking.OnBlocked(func(reason string) { blocked <- reason }),
+		blocking.OnUnblocked(func() { unblocked <- struct{}{} }),
+	)
+
+	assert.False(t, tracker.Blocked())
+	assert.NoError(t, tracker.Check())
+	assert.NoError(t, tracker.Wait(context.Background()))
+
+	conn.blocking <- amqp.Blocking{Active: true, Reason: "low on memory"}
+	assert.Equal(t, "low on memory", <-blocked)
+
+	assert.True(t, tracker.Blocked())
+	assert.Equal(t, "low on memory", tracker.Reason())
+	assert.True(t, errors.Is(tracker.Check(), blocking.ErrBlocked))
+
+	t.Run("wait fails when the context is done", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		err := tracker.Wait(ctx)
+		assert.True(t, errors.Is(err, blocking.ErrBlocked))
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	})
+
+	t.Run("wait returns once unblocked", func(t *testing.T) {
+		waited := make(chan error, 1)
+		go func() { waited <- tracker.Wait(context.Background()) }()
+
+		conn.blocking <- amqp.Blocking{Active: false}
+		<-unblocked
+
+		select {
+		case err := <-waited:
+			assert.NoError(t, err)
+		case <-time.After(time.Second):
+			require.Fail(t, "wait should return once unblocked")
+		}
+
+		assert.False(t, tracker.Blocked())
+		assert.NoError(t, tracker.Check())
+	})
+
+	t.Run("closing the connection releases waiters", func(t *testing.T) {
+		conn.blocking <- amqp.Blocking{Active: true, Reason: "low on disk"}
+		<-blocked
+
+		close(conn.blocking)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		assert.NoError(t, tracker.Wait(ctx))
+	})
+}
@@ -5,7 +5,9 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/ar3s3ru/go-carrot/blocking"
 	"github.com/ar3s3ru/go-carrot/handler"
+	"github.com/ar3s3ru/go-carrot/health"
 	"github.com/ar3s3ru/go-carrot/listener"
 	"github.com/ar3s3ru/go-carrot/topology"
 
@@ -28,38 +30,145 @@ var ErrNoListener = errors.New("carrot: no listener specified")
 // Closer allows to close the amqp.Connection provided and
 // any active Listener after Runner.Run has called.
 type Closer struct {
-	conn   listener.Connection
-	closer listener.Closer
+	connections *connections
+	closer      listener.Closer
+	workers     []listener.Closer
+	flow        *flowController
 }
 
-// Close closes both the amqp.Connection provided and the Listener
-// declared in the Runner.
+// Close closes the Listener declared in the Runner first, then all the
+// Workers started by the Runner, and finally all the connections used
+// by the Runner: the one provided, the ones specified with WithConnection
+// and the ones opened by the Dialer.
+//
+// The first error encountered is returned.
 func (closer Closer) Close(ctx context.Context) error {
-	defer closer.conn.Close()
-	return closer.closer.Close(ctx)
+	// Stops pausing and resuming the Listener, which is being closed.
+	closer.flow.close()
+
+	var err error
+
+	if closer.closer != nil {
+		err = closer.closer.Close(ctx)
+	}
+
+	if workersErr := closeAll(ctx, closer.workers); err == nil {
+		err = workersErr
+	}
+
+	if closer.connections != nil {
+		// nolint:errcheck
+		closer.connections.close()
+	}
+
+	return err
+}
+
+// Connection returns the named connection of the Runner, opening it
+// with the Dialer if needed, e.g. to create publishers using
+// the PublishConnection.
+//
+// The returned connection is closed by Close.
+func (closer Closer) Connection(name string) (listener.Connection, error) {
+	if closer.connections == nil {
+		return nil, ErrNoConnection
+	}
+
+	return closer.connections.get(name)
 }
 
 // Closed returns a channel that gets closed when the Listener gets closed.
 //
-// Useful to wait for consumers completion.
+// Useful to wait for consumers completion. If no Listener has been declared
+// in the Runner, the returned channel is nil.
 func (closer Closer) Closed() <-chan error {
+	if closer.closer == nil {
+		return nil
+	}
+
 	return closer.closer.Closed()
 }
 
+// Blocking returns the blocking.Tracker of the PublishConnection, if
+// WithFlowControl has been specified and the connection supports blocking
+// notifications, or nil otherwise.
+func (closer Closer) Blocking() *blocking.Tracker {
+	if closer.flow == nil {
+		return nil
+	}
+
+	return closer.flow.tracker
+}
+
+// Pause pauses the Listener declared in the Runner, if it supports it,
+// without closing neither the Workers nor the amqp.Connection.
+//
+// Returns listener.ErrNotPausable if the Listener cannot be paused.
+func (closer Closer) Pause(ctx context.Context) error {
+	pauser, ok := closer.closer.(listener.Pauser)
+	if !ok {
+		return listener.ErrNotPausable
+	}
+
+	return pauser.Pause(ctx)
+}
+
+// Resume resumes the Listener declared in the Runner, after it has been paused.
+//
+// Returns listener.ErrNotPausable if the Listener cannot be resumed.
+func (closer Closer) Resume(ctx context.Context) error {
+	pauser, ok := closer.closer.(listener.Pauser)
+	if !ok {
+		return listener.ErrNotPausable
+	}
+
+	return pauser.Resume(ctx)
+}
+
+func closeAll(ctx context.Context, closers []listener.Closer) error {
+	var err error
+
+	for _, closer := range closers {
+		if closeErr := closer.Close(ctx); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+
+	return err
+}
+
+// Worker is a background component, started by the Runner after the topology
+// has been declared, that uses the Runner AMQP connection.
+//
+// Returns a listener.Closer interface to stop the background component.
+type Worker interface {
+	Start(listener.Connection) (listener.Closer, error)
+}
+
 // Runner instruments all the different parts of the go-carrot library,
 // provided with a valid AMQP connection.
 type Runner struct {
-	conn     listener.Connection
-	declarer topology.Declarer
-	handler  handler.Handler
-	listener listener.Listener
+	conn        listener.Connection
+	connections map[string]listener.Connection
+	dialer      Dialer
+
+	declarer   topology.Declarer
+	validate   bool
+	validation []topology.ValidateOption
+	handler    handler.Handler
+	listener   listener.Listener
+	workers    []Worker
+	health     *health.Monitor
 
 	shutdown         *Shutdown
 	gracefulShutdown bool
+
+	flowControl *FlowControl
 }
 
 // Run starts all the different parts of the Runner instrumentator,
-// in the following order: topology declaration, delivery listener and messages listener.
+// in the following order: topology declaration, workers, delivery listener
+// and messages listener.
 //
 // Message listener uses the sink channel coming from the delivery listener,
 // and spawns a separate worker goroutine to run the message handler
@@ -68,30 +177,86 @@ type Runner struct {
 // An error is returned if the supplied parameters during configuration are not
 // valid, or if something happened on the AMQP connection.
 func (runner Runner) Run() (Closer, error) {
-	if runner.conn == nil {
+	if runner.conn == nil && runner.dialer == nil && len(runner.connections) == 0 {
 		return Closer{}, ErrNoConnection
 	}
 
+	conns := &connections{
+		fallback: runner.conn,
+		named:    runner.connections,
+		dialer:   runner.dialer,
+	}
+
+	flow, err := runner.watchBlocking(conns)
+	if err != nil {
+		// nolint:errcheck
+		conns.closeDialed()
+		return Closer{}, fmt.Errorf("carrot: failed to watch connection blocking, %w", err)
+	}
+
+	closer, err := runner.run(conns, flow)
+	if err != nil {
+		flow.close()
+		// nolint:errcheck
+		conns.closeDialed()
+		return Closer{}, err
+	}
+
+	return closer, nil
+}
+
+func (runner Runner) run(conns *connections, flow *flowController) (Closer, error) {
+	if err := runner.watchHealth(conns); err != nil {
+		return Closer{}, err
+	}
+
 	if runner.declarer != nil {
-		if err := runner.declareTopology(); err != nil {
+		if err := runner.validateTopology(); err != nil {
+			return Closer{}, fmt.Errorf("carrot: invalid topology, %w", err)
+		}
+
+		if err := runner.declareTopology(conns); err != nil {
 			return Closer{}, fmt.Errorf("carrot: failed to declare topology, %w", err)
 		}
 	}
 
+	workers, err := runner.startWorkers(conns)
+	if err != nil {
+		return Closer{}, fmt.Errorf("carrot: failed to start workers, %w", err)
+	}
+
+	runnerCloser := Closer{
+		connections: conns,
+		workers:     workers,
+		flow:        flow,
+	}
+
 	// No handler nor delivery listener is an acceptable scenario: it means
 	// the user is not leveraging carrot for message consumption.
 	if runner.handler == nil && runner.listener == nil {
-		return Closer{}, nil
-	}
+		if len(workers) == 0 {
+			flow.close()
+			// nolint:errcheck
+			conns.closeDialed()
+			return Closer{}, nil
+		}
+	} else {
+		closer, err := runner.listenAndServe(conns)
+		if err != nil {
+			// nolint:errcheck
+			closeAll(context.Background(), workers)
+			return Closer{}, fmt.Errorf("carrot: failed to listen and serve consumers, %w", err)
+		}
 
-	closer, err := runner.listenAndServe()
-	if err != nil {
-		return Closer{}, fmt.Errorf("carrot: failed to listen and serve consumers, %w", err)
-	}
+		if reporter, ok := closer.(listener.Reporter); ok && runner.health != nil {
+			runner.health.WatchListener(reporter)
+		}
 
-	runnerCloser := Closer{
-		conn:   runner.conn,
-		closer: closer,
+		if flow != nil && runner.flowControl.PauseListener {
+			flow.listen(closer)
+		}
+
+		runnerCloser.closer = closer
 	}
 
 	if runner.gracefulShutdown {
@@ -101,8 +266,40 @@ func (runner Runner) Run() (Closer, error) {
 	return runnerCloser, nil
 }
 
-func (runner Runner) declareTopology() error {
-	ch, err := runner.openChannel()
+// watchHealth makes the health.Monitor watch the connection used by
+// the Listener or, if not consuming messages, the one used by the Workers.
+func (runner Runner) watchHealth(conns *connections) error {
+	if runner.health == nil {
+		return nil
+	}
+
+	name := ConsumeConnection
+	if runner.handler == nil && runner.listener == nil {
+		name = PublishConnection
+	}
+
+	conn, err := conns.get(name)
+	if err != nil {
+		return err
+	}
+
+	if conn, ok := conn.(health.Connection); ok {
+		runner.health.WatchConnection(conn)
+	}
+
+	return nil
+}
+
+func (runner Runner) validateTopology() error {
+	if !runner.validate {
+		return nil
+	}
+
+	return topology.Validate(runner.declarer, runner.validation...)
+}
+
+func (runner Runner) declareTopology(conns *connections) error {
+	ch, err := runner.openChannel(conns, TopologyConnection)
 	if err != nil {
 		return err
 	}
@@ -112,7 +309,33 @@ func (runner Runner) declareTopology() error {
 	return runner.declarer.Declare(ch)
 }
 
-func (runner Runner) listenAndServe() (listener.Closer, error) {
+func (runner Runner) startWorkers(conns *connections) ([]listener.Closer, error) {
+	if len(runner.workers) == 0 {
+		return nil, nil
+	}
+
+	conn, err := conns.get(PublishConnection)
+	if err != nil {
+		return nil, err
+	}
+
+	closers := make([]listener.Closer, 0, len(runner.workers))
+
+	for _, worker := range runner.workers {
+		closer, err := worker.Start(conn)
+		if err != nil {
+			// nolint:errcheck
+			closeAll(context.Background(), closers)
+			return nil, err
+		}
+
+		closers = append(closers, closer)
+	}
+
+	return closers, nil
+}
+
+func (runner Runner) listenAndServe(conns *connections) (listener.Closer, error) {
 	if runner.handler == nil {
 		return nil, ErrNoHandler
 	}
@@ -121,12 +344,19 @@ func (runner Runner) listenAndServe() (listener.Closer, error) {
 		return nil, ErrNoListener
 	}
 
-	ch, err := runner.openChannel()
+	conn, err := conns.get(ConsumeConnection)
+	if err != nil {
+		return nil, err
+	}
+
+	ch, err := openChannel(conn)
 	if err != nil {
 		return nil, err
 	}
 
-	closer, err := runner.listener.Listen(runner.conn, ch, runner.handler)
+	// The Listener receives a connection giving access to the other
+	// named connections, used by UseConnection.
+	closer, err := runner.listener.Listen(runnerConnection{Connection: conn, connections: conns}, ch, runner.handler)
 	if err != nil {
 		return nil, fmt.Errorf("carrot: failed to listen, %w", err)
 	}
@@ -134,8 +364,17 @@ func (runner Runner) listenAndServe() (listener.Closer, error) {
 	return closer, nil
 }
 
-func (runner Runner) openChannel() (*amqp.Channel, error) {
-	ch, err := runner.conn.Channel()
+func (runner Runner) openChannel(conns *connections, name string) (*amqp.Channel, error) {
+	conn, err := conns.get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return openChannel(conn)
+}
+
+func openChannel(conn listener.Connection) (*amqp.Channel, error) {
+	ch, err := conn.Channel()
 	if err != nil {
 		return nil, fmt.Errorf("carrot: failed to create channel from connection, %w", err)
 	}
@@ -155,6 +394,9 @@ func Run(conn listener.Connection, options ...Option) (Closer, error) {
 // Required options are WithListener, to bind a channel to an amqp.Delivery sink
 // and start receiving messages, and WithHandler, to handle all the incoming
 // messages.
+//
+// The connection is used for everything, unless separate connections are
+// specified with WithConnection or WithDialer.
 func From(conn listener.Connection, options ...Option) Runner {
 	runner := Runner{conn: conn}
 
@@ -173,10 +415,29 @@ func From(conn listener.Connection, options ...Option) Runner {
 type Option func(*Runner)
 
 // WithTopology adds a topology declaration step to the new Runner instance.
+//
+// Use migrate.New from the topology/migrate package to apply changes
+// that can't be applied by declaring the topology again, such as changing
+// the arguments of a queue.
 func WithTopology(declarer topology.Declarer) Option {
 	return func(runner *Runner) { runner.declarer = declarer }
 }
 
+// WithTopologyValidation validates the topology declared with WithTopology
+// using topology.Validate, with the specified options, before declaring it,
+// so that mistakes are reported before communicating with the AMQP broker,
+// e.g. WithTopologyValidation(topology.Strict).
+//
+// Declarers not implementing topology.Describer, such as custom ones,
+// are described by calling their Declare method with a recording channel:
+// those relying on the actual AMQP channel should not be validated.
+func WithTopologyValidation(options ...topology.ValidateOption) Option {
+	return func(runner *Runner) {
+		runner.validate = true
+		runner.validation = append(runner.validation, options...)
+	}
+}
+
 // WithHandler specifies the component in charge of handling incoming messages
 // for the new Runner instance.
 func WithHandler(handler handler.Handler) Option {
@@ -189,6 +450,26 @@ func WithListener(listener listener.Listener) Option {
 	return func(runner *Runner) { runner.listener = listener }
 }
 
+// WithWorker adds a Worker to be started in the background by the new Runner instance.
+//
+// Multiple calls of this option are supported.
+func WithWorker(worker Worker) Option {
+	return func(runner *Runner) {
+		if worker != nil {
+			runner.workers = append(runner.workers, worker)
+		}
+	}
+}
+
+// WithHealth feeds the provided health.Monitor with the state of the
+// AMQP connection and of the listeners started by the new Runner instance.
+//
+// When using multiple connections, the Monitor watches the ConsumeConnection,
+// or the PublishConnection if the Runner doesn't consume messages.
+func WithHealth(monitor *health.Monitor) Option {
+	return func(runner *Runner) { runner.health = monitor }
+}
+
 // WithGracefulShutdown enables graceful shutdown after certain signals
 // are received by the process.
 //
@@ -2,6 +2,7 @@ package carrot_test
 
 import (
 	"context"
+	"errors"
 	"os"
 	"syscall"
 	"testing"
@@ -126,3 +127,43 @@ func TestWithGracefulShutdown(t *testing.T) {
 		}
 	}
 }
+
+func TestRun_InvalidTopology(t *testing.T) {
+	conn := new(mocks.Connection)
+
+	_, err := carrot.Run(conn,
+		carrot.WithTopology(queue.Declare("orders-created", queue.BindTo("orders", "created"))),
+		carrot.WithTopologyValidation(topology.Strict),
+	)
+
+	var validationErr *topology.ValidationError
+	assert.True(t, errors.As(err, &validationErr))
+
+	// The topology is not declared, since it's invalid.
+	conn.AssertNotCalled(t, "Channel")
+}
+
+func TestRun_TopologyValidationIsOptIn(t *testing.T) {
+	var declared int
+
+	// Custom Declarers are described by calling Declare on a recording channel.
+	declarer := declarerFunc(func(topology.Channel) error {
+		declared++
+		return nil
+	})
+
+	conn := new(mocks.Connection)
+	conn.On("Channel").Return(nil, errors.New("connection closed"))
+
+	_, err := carrot.Run(conn, carrot.WithTopology(declarer))
+	assert.Error(t, err)
+	assert.Equal(t, 0, declared)
+
+	_, err = carrot.Run(conn, carrot.WithTopology(declarer), carrot.WithTopologyValidation())
+	assert.Error(t, err)
+	assert.Equal(t, 1, declared)
+}
+
+type declarerFunc func(topology.Channel) error
+
+func (fn declarerFunc) Declare(ch topology.Channel) error { return fn(ch) }
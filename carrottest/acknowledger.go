@@ -0,0 +1,139 @@
+package carrottest
+
+import (
+	"sync"
+
+	"github.com/streadway/amqp"
+)
+
+// Method is an acknowledgement method of the amqp.Acknowledger interface.
+type Method string
+
+// Supported acknowledgement methods.
+const (
+	AckMethod    Method = "ack"
+	NackMethod   Method = "nack"
+	RejectMethod Method = "reject"
+)
+
+// Call is an acknowledgement recorded by the Acknowledger.
+type Call struct {
+	Method   Method
+	Tag      uint64
+	Multiple bool
+	Requeue  bool
+}
+
+// Outcome is what happened to a delivery, as a result of its acknowledgement.
+type Outcome string
+
+// Supported outcomes.
+const (
+	// Pending is the outcome of deliveries not acknowledged yet.
+	Pending Outcome = "pending"
+	// Acked is the outcome of acknowledged deliveries.
+	Acked Outcome = "acked"
+	// Requeued is the outcome of deliveries negatively acknowledged
+	// or rejected, and requeued.
+	Requeued Outcome = "requeued"
+	// Rejected is the outcome of deliveries negatively acknowledged
+	// or rejected without being requeued, hence dead-lettered or dropped.
+	Rejected Outcome = "rejected"
+)
+
+// Acknowledger is an amqp.Acknowledger recording all the acknowledgements,
+// safe for concurrent use.
+//
+// The zero value is ready to use.
+type Acknowledger struct {
+	// Err is returned by all the acknowledgement methods, if not nil.
+	Err error
+
+	mx    sync.Mutex
+	calls []Call
+}
+
+var _ amqp.Acknowledger = new(Acknowledger)
+
+// Ack records an acknowledgement.
+func (a *Acknowledger) Ack(tag uint64, multiple bool) error {
+	return a.record(Call{Method: AckMethod, Tag: tag, Multiple: multiple})
+}
+
+// Nack records a negative acknowledgement.
+func (a *Acknowledger) Nack(tag uint64, multiple, requeue bool) error {
+	return a.record(Call{Method: NackMethod, Tag: tag, Multiple: multiple, Requeue: requeue})
+}
+
+// Reject records a rejection.
+func (a *Acknowledger) Reject(tag uint64, requeue bool) error {
+	return a.record(Call{Method: RejectMethod, Tag: tag, Requeue: requeue})
+}
+
+func (a *Acknowledger) record(call Call) error {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+
+	a.calls = append(a.calls, call)
+
+	return a.Err
+}
+
+// Calls returns all the acknowledgements recorded, in order.
+func (a *Acknowledger) Calls() []Call {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+
+	return append([]Call(nil), a.calls...)
+}
+
+// Outcome returns the outcome of the first acknowledgement recorded,
+// since the following ones would be refused by the AMQP broker.
+func (a *Acknowledger) Outcome() Outcome {
+	calls := a.Calls()
+
+	switch {
+	case len(calls) == 0:
+		return Pending
+	case calls[0].Method == AckMethod:
+		return Acked
+	case calls[0].Requeue:
+		return Requeued
+	default:
+		return Rejected
+	}
+}
+
+// AcknowledgerOf returns the recording Acknowledger of the delivery,
+// or nil if the delivery doesn't use one.
+func AcknowledgerOf(delivery amqp.Delivery) *Acknowledger {
+	acknowledger, _ := delivery.Acknowledger.(*Acknowledger)
+	return acknowledger
+}
+
+// TestingT is the interface of *testing.T used by the assertions.
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// AssertOutcome asserts that the delivery, using a recording Acknowledger,
+// has the expected outcome, and reports whether the assertion succeeded.
+func AssertOutcome(t TestingT, delivery amqp.Delivery, expected Outcome) bool {
+	t.Helper()
+
+	acknowledger := AcknowledgerOf(delivery)
+	if acknowledger == nil {
+		t.Errorf("carrottest: delivery %d doesn't use a recording Acknowledger", delivery.DeliveryTag)
+		return false
+	}
+
+	if outcome := acknowledger.Outcome(); outcome != expected {
+		t.Errorf("carrottest: delivery %d outcome is %q, expected %q (calls: %+v)",
+			delivery.DeliveryTag, outcome, expected, acknowledger.Calls())
+
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,266 @@
+package carrottest_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ar3s3ru/go-carrot"
+	"github.com/ar3s3ru/go-carrot/carrottest"
+	"github.com/ar3s3ru/go-carrot/handler"
+	"github.com/ar3s3ru/go-carrot/handler/router"
+	"github.com/ar3s3ru/go-carrot/listener/consumer"
+	"github.com/ar3s3ru/go-carrot/recording"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDelivery(t *testing.T) {
+	timestamp := time.Date(2021, time.March, 1, 10, 0, 0, 0, time.UTC)
+
+	delivery := carrottest.NewDelivery(
+		carrottest.JSON(map[string]string{"id": "1"}),
+		carrottest.Header("tenant", "acme"),
+		carrottest.Headers(amqp.Table{"version": int32(2)}),
+		carrottest.MessageID("message-1"),
+		carrottest.CorrelationID("correlation-1"),
+		carrottest.Timestamp(timestamp),
+		carrottest.RoutingKey("order.created"),
+		carrottest.ConsumerTag("orders"),
+		carrottest.DeliveryTag(42),
+		carrottest.Persistent,
+		carrottest.Redelivered,
+		nil,
+	)
+
+	assert.Equal(t, []byte(`{"id":"1"}`), delivery.Body)
+	assert.Equal(t, "application/json", delivery.ContentType)
+	assert.Equal(t, amqp.Table{"tenant": "acme", "version": int32(2)}, delivery.Headers)
+	assert.Equal(t, "message-1", delivery.MessageId)
+	assert.Equal(t, "correlation-1", delivery.CorrelationId)
+	assert.Equal(t, timestamp, delivery.Timestamp)
+	assert.Equal(t, "order.created", delivery.RoutingKey)
+	assert.Equal(t, "orders", delivery.ConsumerTag)
+	assert.Equal(t, uint64(42), delivery.DeliveryTag)
+	assert.Equal(t, amqp.Persistent, delivery.DeliveryMode)
+	assert.True(t, delivery.Redelivered)
+	assert.NotNil(t, carrottest.AcknowledgerOf(delivery))
+}
+
+func TestAcknowledger(t *testing.T) {
+	testcases := []struct {
+		name     string
+		ack      func(amqp.Delivery) error
+		expected carrottest.Outcome
+	}{
+		{name: "no calls", ack: func(amqp.Delivery) error { return nil }, expected: carrottest.Pending},
+		{name: "ack", ack: func(d amqp.Delivery) error { return d.Ack(false) }, expected: carrottest.Acked},
+		{name: "nack requeue", ack: func(d amqp.Delivery) error { return d.Nack(false, true) }, expected: carrottest.Requeued},
+		{name: "nack", ack: func(d amqp.Delivery) error { return d.Nack(false, false) }, expected: carrottest.Rejected},
+		{name: "reject requeue", ack: func(d amqp.Delivery) error { return d.Reject(true) }, expected: carrottest.Requeued},
+		{name: "reject", ack: func(d amqp.Delivery) error { return d.Reject(false) }, expected: carrottest.Rejected},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			delivery := carrottest.NewDelivery()
+
+			require.NoError(t, tc.ack(delivery))
+			assert.Equal(t, tc.expected, carrottest.AcknowledgerOf(delivery).Outcome())
+			assert.True(t, carrottest.AssertOutcome(t, delivery, tc.expected))
+		})
+	}
+
+	t.Run("records all calls and returns the error", func(t *testing.T) {
+		ack := &carrottest.Acknowledger{Err: errors.New("channel closed")}
+		delivery := carrottest.NewDelivery(carrottest.WithAcknowledger(ack), carrottest.DeliveryTag(7))
+
+		assert.Error(t, delivery.Ack(false))
+		assert.Error(t, delivery.Nack(true, true))
+		assert.Equal(t, []carrottest.Call{
+			{Method: carrottest.AckMethod, Tag: 7},
+			{Method: carrottest.NackMethod, Tag: 7, Multiple: true, Requeue: true},
+		}, ack.Calls())
+	})
+}
+
+type fakeT struct {
+	errors []string
+}
+
+func (*fakeT) Helper() {}
+
+func (t *fakeT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertOutcome(t *testing.T) {
+	ft := new(fakeT)
+
+	assert.False(t, carrottest.AssertOutcome(ft, carrottest.NewDelivery(), carrottest.Acked))
+	assert.False(t, carrottest.AssertOutcome(ft, amqp.Delivery{}, carrottest.Acked))
+	assert.Len(t, ft.errors, 2)
+}
+
+func TestConsume(t *testing.T) {
+	var seen []string
+
+	logTags := func(h handler.Handler) handler.Handler {
+		return handler.Func(func(ctx context.Context, delivery amqp.Delivery) error {
+			seen = append(seen, delivery.ConsumerTag)
+			return h.Handle(ctx, delivery)
+		})
+	}
+
+	r := router.New()
+	r.Use(logTags)
+	r.Bind("orders", handler.Func(func(_ context.Context, delivery amqp.Delivery) error {
+		switch string(delivery.Body) {
+		case "malformed":
+			return handler.Reject(errors.New("malformed"))
+		case "failing":
+			return errors.New("failed")
+		default:
+			return nil
+		}
+	}))
+
+	results, err := carrottest.Consume("orders", r, []amqp.Delivery{
+		carrottest.NewDelivery(carrottest.Body([]byte("ok"))),
+		carrottest.NewDelivery(carrottest.Body([]byte("malformed"))),
+		carrottest.NewDelivery(carrottest.Body([]byte("failing"))),
+		{Body: []byte("no acknowledger")},
+		carrottest.NewDelivery(carrottest.ConsumerTag("unbound")),
+	})
+
+	require.NoError(t, err)
+	require.Len(t, results, 5)
+
+	assert.Equal(t, []string{"orders", "orders", "orders", "orders"}, seen)
+
+	expected := []carrottest.Outcome{
+		carrottest.Acked,
+		carrottest.Rejected,
+		carrottest.Requeued,
+		carrottest.Acked,
+		carrottest.Requeued,
+	}
+
+	for i, result := range results {
+		assert.Equal(t, expected[i], result.Outcome, "delivery %d", i)
+		carrottest.AssertOutcome(t, result.Delivery, expected[i])
+	}
+
+	assert.NoError(t, results[0].Err)
+	assert.True(t, handler.IsRejected(results[1].Err))
+	assert.True(t, errors.Is(results[4].Err, router.ErrNoHandler))
+}
+
+func TestHandle(t *testing.T) {
+	failing := handler.Func(func(context.Context, amqp.Delivery) error {
+		return errors.New("failed")
+	})
+
+	t.Run("uses the consumer options", func(t *testing.T) {
+		var failures int
+
+		result, err := carrottest.Handle("orders", failing, carrottest.NewDelivery(),
+			consumer.OnError(func(delivery amqp.Delivery, _ error) {
+				failures++
+				delivery.Reject(false) // nolint:errcheck
+			}),
+		)
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, failures)
+		assert.Equal(t, carrottest.Rejected, result.Outcome)
+	})
+
+	t.Run("fails with options requiring a dedicated channel", func(t *testing.T) {
+		_, err := carrottest.Handle("orders", failing, carrottest.NewDelivery(), consumer.Prefetch(1, 0))
+		assert.True(t, errors.Is(err, carrottest.ErrDedicatedChannel))
+	})
+}
+
+func recorded(start time.Time, gaps ...time.Duration) []recording.Message {
+	messages := make([]recording.Message, 0, len(gaps)+1)
+	receivedAt := start
+
+	for i := 0; i <= len(gaps); i++ {
+		if i > 0 {
+			receivedAt = receivedAt.Add(gaps[i-1])
+		}
+
+		at := receivedAt
+		messages = append(messages, recording.Message{
+			ReceivedAt: &at,
+			MessageID:  fmt.Sprint(i + 1),
+		})
+	}
+
+	return messages
+}
+
+func TestReplay(t *testing.T) {
+	messages := recorded(time.Now(), 100*time.Millisecond, 100*time.Millisecond)
+	messages[1].ConsumerTag = "unbound"
+
+	r := router.New()
+	r.Bind("orders", handler.Func(func(context.Context, amqp.Delivery) error { return nil }))
+
+	replayer := carrottest.Replay("orders", messages, carrottest.Speed(10))
+
+	start := time.Now()
+
+	closer, err := carrot.Run(carrottest.Connection{},
+		carrot.WithListener(replayer),
+		carrot.WithHandler(r),
+	)
+	require.NoError(t, err)
+
+	assert.NoError(t, <-closer.Closed())
+	assert.True(t, time.Since(start) >= 20*time.Millisecond, "messages should be replayed with compressed timing")
+
+	results := replayer.Results()
+	require.Len(t, results, 3)
+
+	assert.Equal(t, carrottest.Acked, results[0].Outcome)
+	assert.Equal(t, carrottest.Requeued, results[1].Outcome)
+	assert.True(t, errors.Is(results[1].Err, router.ErrNoHandler))
+	assert.Equal(t, carrottest.Acked, results[2].Outcome)
+	assert.Equal(t, "3", results[2].Delivery.MessageId)
+
+	assert.NoError(t, closer.Close(context.Background()))
+}
+
+func TestReplayer_Close(t *testing.T) {
+	messages := recorded(time.Now(), time.Hour)
+
+	replayer := carrottest.Replay("orders", messages,
+		carrottest.ConsumerOptions(consumer.Title("Orders replay")),
+	)
+
+	closer, err := replayer.Listen(nil, nil, handler.Func(func(context.Context, amqp.Delivery) error {
+		return nil
+	}))
+	require.NoError(t, err)
+
+	// Waits for the first message to be handled, then stops the replay
+	// while waiting for the second one.
+	require.Eventually(t, func() bool {
+		results := replayer.Results()
+		return len(results) == 1 && results[0].Outcome == carrottest.Acked
+	}, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	assert.NoError(t, closer.Close(ctx))
+	assert.NoError(t, <-closer.Closed())
+	assert.Len(t, replayer.Results(), 1)
+}
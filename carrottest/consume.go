@@ -0,0 +1,91 @@
+package carrottest
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ar3s3ru/go-carrot/handler"
+	"github.com/ar3s3ru/go-carrot/listener/consumer"
+
+	"github.com/streadway/amqp"
+)
+
+// ErrDedicatedChannel is returned by Consume and Handle when using consumer
+// options that require a dedicated channel, which can't be opened without
+// an AMQP broker, such as consumer.Prefetch, consumer.Tag or consumer.Batch.
+var ErrDedicatedChannel = errors.New("carrottest: dedicated channels are not supported")
+
+// Result is the result of handling a delivery through a consumer.
+type Result struct {
+	// Delivery is the delivery received by the handler.
+	Delivery amqp.Delivery
+	// Err is the error returned by the handler, if any.
+	Err error
+	// Outcome is the outcome of the delivery, after the consumer
+	// acknowledged it.
+	Outcome Outcome
+}
+
+// Consume handles the deliveries in order with the provided handler.Handler,
+// through a consumer.Listener consuming from the specified queue, and returns
+// the result of each delivery, once all of them have been handled.
+//
+// As the AMQP broker does, deliveries are received with the consumer tag set
+// to the one of the consumer, unless already specified, so that they can be
+// routed by a router.Mux; deliveries not using a recording Acknowledger
+// are given a new one.
+func Consume(queue string, h handler.Handler, deliveries []amqp.Delivery, options ...consumer.Option) ([]Result, error) {
+	replayer := &Replayer{
+		queue:      queue,
+		deliveries: deliveries,
+		delays:     make([]time.Duration, len(deliveries)),
+		options:    options,
+	}
+
+	closer, err := replayer.Listen(nil, nil, h)
+	if err != nil {
+		return nil, err
+	}
+
+	// The Replayer closes itself once all the deliveries have been handled.
+	if err := <-closer.Closed(); err != nil {
+		return nil, fmt.Errorf("carrottest.Consume: failed to close consumer, %w", err)
+	}
+
+	return replayer.Results(), nil
+}
+
+// Handle handles the delivery with the provided handler.Handler, through
+// a consumer.Listener consuming from the specified queue.
+//
+// For more information, see Consume.
+func Handle(queue string, h handler.Handler, delivery amqp.Delivery, options ...consumer.Option) (Result, error) {
+	results, err := Consume(queue, h, []amqp.Delivery{delivery}, options...)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return results[0], nil
+}
+
+// channel is a listener.Channel delivering the messages sent to the sink.
+type channel struct {
+	sink <-chan amqp.Delivery
+	tag  string
+}
+
+func (ch *channel) Qos(int, int, bool) error  { return nil }
+func (ch *channel) Cancel(string, bool) error { return nil }
+func (ch *channel) Close() error              { return nil }
+
+func (ch *channel) Consume(_, tag string, _, _, _, _ bool, _ amqp.Table) (<-chan amqp.Delivery, error) {
+	ch.tag = tag
+	return ch.sink, nil
+}
+
+// connection is a listener.Connection unable to open new channels.
+type connection struct{}
+
+func (connection) Close() error                    { return nil }
+func (connection) Channel() (*amqp.Channel, error) { return nil, ErrDedicatedChannel }
@@ -0,0 +1,174 @@
+// Package carrottest provides utilities to unit-test message handlers
+// and middlewares, without an AMQP broker.
+//
+// Deliveries can be built with NewDelivery, and use a recording Acknowledger
+// to capture acknowledgements; Consume and Handle run handlers through
+// a real consumer.Listener, so that the outcome of the messages is the same
+// as in production:
+//
+//	r := router.New()
+//	r.Use(middleware.Timeout(time.Second))
+//	r.Bind("orders", ordersHandler)
+//
+//	result, err := carrottest.Handle("orders", r, carrottest.NewDelivery(
+//		carrottest.JSON(order),
+//		carrottest.Header("tenant", "acme"),
+//	))
+//
+//	carrottest.AssertOutcome(t, result.Delivery, carrottest.Acked)
+//
+// Messages recorded with the recording package can be replayed with Replay,
+// to catch regressions in handlers against real traffic.
+package carrottest
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// NewDelivery returns a new amqp.Delivery, using a new recording Acknowledger
+// and delivery tag 1, unless otherwise specified with the options.
+func NewDelivery(options ...DeliveryOption) amqp.Delivery {
+	delivery := amqp.Delivery{
+		Acknowledger: new(Acknowledger),
+		DeliveryTag:  1,
+	}
+
+	for _, option := range options {
+		if option == nil {
+			continue
+		}
+
+		option(&delivery)
+	}
+
+	return delivery
+}
+
+// DeliveryOption is an optional functionality that can be added to
+// the amqp.Delivery that is being built by the NewDelivery factory method.
+type DeliveryOption func(*amqp.Delivery)
+
+// Body specifies the body of the delivery.
+func Body(body []byte) DeliveryOption {
+	return func(delivery *amqp.Delivery) { delivery.Body = body }
+}
+
+// JSON specifies the body of the delivery, as the JSON encoding of the value,
+// and sets the content type to "application/json".
+//
+// Panics if the value can't be encoded, since it's a programming error in tests.
+func JSON(v interface{}) DeliveryOption {
+	body, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("carrottest.JSON: failed to encode value, %s", err))
+	}
+
+	return func(delivery *amqp.Delivery) {
+		delivery.Body = body
+		delivery.ContentType = "application/json"
+	}
+}
+
+// Header adds a header to the delivery.
+// Multiple calls of this option are supported.
+func Header(key string, value interface{}) DeliveryOption {
+	return func(delivery *amqp.Delivery) {
+		if delivery.Headers == nil {
+			delivery.Headers = make(amqp.Table)
+		}
+
+		delivery.Headers[key] = value
+	}
+}
+
+// Headers adds all the headers to the delivery.
+// Multiple calls of this option are supported.
+func Headers(headers amqp.Table) DeliveryOption {
+	return func(delivery *amqp.Delivery) {
+		for key, value := range headers {
+			Header(key, value)(delivery)
+		}
+	}
+}
+
+// ContentType specifies the content type property of the delivery.
+func ContentType(contentType string) DeliveryOption {
+	return func(delivery *amqp.Delivery) { delivery.ContentType = contentType }
+}
+
+// MessageID specifies the message id property of the delivery.
+func MessageID(id string) DeliveryOption {
+	return func(delivery *amqp.Delivery) { delivery.MessageId = id }
+}
+
+// CorrelationID specifies the correlation id property of the delivery.
+func CorrelationID(id string) DeliveryOption {
+	return func(delivery *amqp.Delivery) { delivery.CorrelationId = id }
+}
+
+// ReplyTo specifies the reply-to property of the delivery.
+func ReplyTo(replyTo string) DeliveryOption {
+	return func(delivery *amqp.Delivery) { delivery.ReplyTo = replyTo }
+}
+
+// Type specifies the type property of the delivery.
+func Type(typ string) DeliveryOption {
+	return func(delivery *amqp.Delivery) { delivery.Type = typ }
+}
+
+// AppID specifies the application id property of the delivery.
+func AppID(id string) DeliveryOption {
+	return func(delivery *amqp.Delivery) { delivery.AppId = id }
+}
+
+// Timestamp specifies the timestamp property of the delivery.
+func Timestamp(timestamp time.Time) DeliveryOption {
+	return func(delivery *amqp.Delivery) { delivery.Timestamp = timestamp }
+}
+
+// Expiration specifies the expiration property of the delivery.
+func Expiration(expiration string) DeliveryOption {
+	return func(delivery *amqp.Delivery) { delivery.Expiration = expiration }
+}
+
+// Priority specifies the priority property of the delivery.
+func Priority(priority uint8) DeliveryOption {
+	return func(delivery *amqp.Delivery) { delivery.Priority = priority }
+}
+
+// Persistent marks the delivery as published with persistent delivery mode.
+func Persistent(delivery *amqp.Delivery) { delivery.DeliveryMode = amqp.Persistent }
+
+// Redelivered marks the delivery as redelivered by the AMQP broker.
+func Redelivered(delivery *amqp.Delivery) { delivery.Redelivered = true }
+
+// Exchange specifies the exchange the delivery has been published to.
+func Exchange(exchange string) DeliveryOption {
+	return func(delivery *amqp.Delivery) { delivery.Exchange = exchange }
+}
+
+// RoutingKey specifies the routing key the delivery has been published with.
+func RoutingKey(key string) DeliveryOption {
+	return func(delivery *amqp.Delivery) { delivery.RoutingKey = key }
+}
+
+// ConsumerTag specifies the tag of the consumer receiving the delivery,
+// used by router.Mux to route messages.
+func ConsumerTag(tag string) DeliveryOption {
+	return func(delivery *amqp.Delivery) { delivery.ConsumerTag = tag }
+}
+
+// DeliveryTag specifies the delivery tag of the delivery.
+func DeliveryTag(tag uint64) DeliveryOption {
+	return func(delivery *amqp.Delivery) { delivery.DeliveryTag = tag }
+}
+
+// WithAcknowledger specifies the Acknowledger of the delivery,
+// e.g. to share it between multiple deliveries.
+func WithAcknowledger(acknowledger amqp.Acknowledger) DeliveryOption {
+	return func(delivery *amqp.Delivery) { delivery.Acknowledger = acknowledger }
+}
@@ -0,0 +1,262 @@
+package carrottest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ar3s3ru/go-carrot/handler"
+	"github.com/ar3s3ru/go-carrot/listener"
+	"github.com/ar3s3ru/go-carrot/listener/consumer"
+	"github.com/ar3s3ru/go-carrot/recording"
+
+	"github.com/streadway/amqp"
+)
+
+// Replayer is a listener.Listener replaying recorded messages in order,
+// through a consumer.Listener, without an AMQP broker.
+//
+// Once all the messages have been replayed and handled, the Replayer closes
+// itself, so that the channel returned by Closed, and the one of the Runner
+// using it, can be used to wait for the replay completion:
+//
+//	replayer := carrottest.Replay("orders", messages, carrottest.Speed(10))
+//
+//	closer, err := carrot.Run(carrottest.Connection{},
+//		carrot.WithListener(replayer),
+//		carrot.WithHandler(r),
+//	)
+//
+//	<-closer.Closed()
+//
+//	for _, result := range replayer.Results() {
+//		carrottest.AssertOutcome(t, result.Delivery, carrottest.Acked)
+//	}
+//
+// Use a new Replayer for each Listen call.
+type Replayer struct {
+	queue      string
+	deliveries []amqp.Delivery
+	delays     []time.Duration
+	speed      float64
+	options    []consumer.Option
+
+	mx       sync.Mutex
+	results  []Result
+	replayed int
+	handled  int
+}
+
+var _ listener.Listener = new(Replayer)
+
+// Replay returns a new Replayer of the messages, consuming from
+// the specified queue.
+//
+// As the AMQP broker does, deliveries are received with the consumer tag set
+// to the one of the consumer, unless recorded, so that they can be routed
+// by a router.Mux; all the deliveries use a new recording Acknowledger.
+//
+// Messages are replayed with the same timing they have been recorded with,
+// unless otherwise specified with the Speed option.
+func Replay(queue string, messages []recording.Message, options ...ReplayOption) *Replayer {
+	replayer := &Replayer{queue: queue, speed: 1}
+
+	var previous *time.Time
+
+	for _, msg := range messages {
+		var delay time.Duration
+
+		if msg.ReceivedAt != nil {
+			if previous != nil {
+				delay = msg.ReceivedAt.Sub(*previous)
+			}
+
+			previous = msg.ReceivedAt
+		}
+
+		replayer.deliveries = append(replayer.deliveries, msg.Delivery())
+		replayer.delays = append(replayer.delays, delay)
+	}
+
+	for _, option := range options {
+		if option == nil {
+			continue
+		}
+
+		option(replayer)
+	}
+
+	return replayer
+}
+
+// ReplayOption is an optional functionality that can be added to
+// the Replayer that is being initialized by the Replay factory method.
+type ReplayOption func(*Replayer)
+
+// Speed compresses the time between the replayed messages by the specified
+// factor: with 10, messages are replayed ten times faster than recorded.
+//
+// Zero or a negative factor replays the messages without waiting.
+func Speed(factor float64) ReplayOption {
+	return func(replayer *Replayer) { replayer.speed = factor }
+}
+
+// ConsumerOptions specifies the options of the consumer.Listener used
+// to replay the messages.
+//
+// Options requiring a dedicated channel are not supported, and make
+// Listen fail with ErrDedicatedChannel.
+func ConsumerOptions(options ...consumer.Option) ReplayOption {
+	return func(replayer *Replayer) { replayer.options = append(replayer.options, options...) }
+}
+
+// Listen starts replaying the messages, handling them with the provided
+// handler.Handler. The provided Connection and Channel are not used.
+func (replayer *Replayer) Listen(_ listener.Connection, _ listener.Channel, h handler.Handler) (listener.Closer, error) {
+	replayer.mx.Lock()
+	replayer.results = make([]Result, len(replayer.deliveries))
+	replayer.replayed = 0
+	replayer.handled = 0
+	replayer.mx.Unlock()
+
+	// Handler errors are recorded in order, since the consumer handles
+	// deliveries sequentially.
+	recorder := handler.Func(func(ctx context.Context, delivery amqp.Delivery) error {
+		err := h.Handle(ctx, delivery)
+
+		replayer.mx.Lock()
+		replayer.results[replayer.handled].Err = err
+		replayer.handled++
+		replayer.mx.Unlock()
+
+		return err
+	})
+
+	sink := make(chan amqp.Delivery)
+	ch := &channel{sink: sink}
+
+	closer, err := consumer.Listen(replayer.queue, replayer.options...).Listen(connection{}, ch, recorder)
+	if err != nil {
+		return nil, fmt.Errorf("carrottest.Replayer: failed to start consumer, %w", err)
+	}
+
+	rc := &replayCloser{
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+		closed: make(chan error, 1),
+	}
+
+	go func() {
+		replayer.replay(sink, ch.tag, rc.stop)
+		close(sink)
+
+		// The delivery channel has been closed, so the consumer
+		// only waits for the deliveries in-flight to be handled.
+		rc.err = closer.Close(context.Background())
+
+		rc.closed <- rc.err
+		close(rc.closed)
+		close(rc.done)
+	}()
+
+	return rc, nil
+}
+
+func (replayer *Replayer) replay(sink chan<- amqp.Delivery, tag string, stop <-chan struct{}) {
+	for i, delivery := range replayer.deliveries {
+		if delay := replayer.delay(i); delay > 0 {
+			timer := time.NewTimer(delay)
+
+			select {
+			case <-timer.C:
+			case <-stop:
+				timer.Stop()
+				return
+			}
+		}
+
+		if delivery.ConsumerTag == "" {
+			delivery.ConsumerTag = tag
+		}
+
+		if AcknowledgerOf(delivery) == nil {
+			delivery.Acknowledger = new(Acknowledger)
+		}
+
+		replayer.mx.Lock()
+		replayer.results[i].Delivery = delivery
+		replayer.replayed++
+		replayer.mx.Unlock()
+
+		select {
+		case sink <- delivery:
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (replayer *Replayer) delay(i int) time.Duration {
+	if replayer.speed <= 0 {
+		return 0
+	}
+
+	return time.Duration(float64(replayer.delays[i]) / replayer.speed)
+}
+
+// Results returns the results of the messages replayed so far, in order.
+//
+// Wait for the Replayer to be closed to get the results of all the messages.
+func (replayer *Replayer) Results() []Result {
+	replayer.mx.Lock()
+	results := append([]Result(nil), replayer.results[:replayer.replayed]...)
+	replayer.mx.Unlock()
+
+	for i, result := range results {
+		results[i].Outcome = AcknowledgerOf(result.Delivery).Outcome()
+	}
+
+	return results
+}
+
+// replayCloser stops replaying messages, and closes the consumer
+// once the messages in-flight have been handled.
+type replayCloser struct {
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+	closed   chan error
+	err      error
+}
+
+func (rc *replayCloser) Close(ctx context.Context) error {
+	rc.stopOnce.Do(func() { close(rc.stop) })
+
+	select {
+	case <-rc.done:
+		return rc.err
+	case <-ctx.Done():
+		return fmt.Errorf("carrottest.Replayer: failed to close, %w", ctx.Err())
+	}
+}
+
+func (rc *replayCloser) Closed() <-chan error {
+	return rc.closed
+}
+
+// Connection is a listener.Connection to run a carrot.Runner without
+// an AMQP broker, using Listeners that don't need a Channel, such as
+// the Replayer.
+//
+// Since no channel is opened, the Runner must not declare a topology
+// nor start Workers using the Connection.
+type Connection struct{}
+
+var _ listener.Connection = Connection{}
+
+// Close does nothing.
+func (Connection) Close() error { return nil }
+
+// Channel returns a nil channel.
+func (Connection) Channel() (*amqp.Channel, error) { return nil, nil }
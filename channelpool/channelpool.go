@@ -0,0 +1,434 @@
+// Package channelpool contains a pool of AMQP channels, to share a capped
+// number of channels between publishers and listeners, instead of opening
+// a new channel for each operation.
+//
+// A Pool implements the listener.Connection interface, so it can be passed
+// to carrot.From or carrot.WithConnection to have listeners and workers draw
+// their channels from it, and the publisher.Interface used by the deadletter,
+// rpc and middleware packages, publishing each message on a pooled channel:
+//
+//	pool := channelpool.New(conn, channelpool.MaxChannels(8), channelpool.Confirm)
+//	defer pool.Close()
+//
+//	err := pool.Publish(ctx, "orders", "order.created", msg)
+package channelpool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/ar3s3ru/go-carrot/listener"
+	"github.com/ar3s3ru/go-carrot/publisher"
+
+	"github.com/streadway/amqp"
+)
+
+// DefaultMaxChannels is the default maximum number of channels
+// opened by the Pool.
+const DefaultMaxChannels = 16
+
+// ErrClosed is returned when acquiring channels from a closed Pool.
+var ErrClosed = errors.New("channelpool: pool closed")
+
+// ErrUnsupportedChannel is returned by Pool.Channel when the pooled channels
+// are not *amqp.Channel instances.
+var ErrUnsupportedChannel = errors.New("channelpool: pooled channel is not an *amqp.Channel")
+
+// ErrExhausted is returned by Pool.Channel when the maximum number
+// of channels is in use.
+var ErrExhausted = errors.New("channelpool: all channels in use")
+
+// Channel is the channel interface managed by the Pool,
+// implemented by *amqp.Channel.
+type Channel interface {
+	io.Closer
+	publisher.Channel
+
+	NotifyClose(chan *amqp.Error) chan *amqp.Error
+}
+
+// Pool keeps a capped number of AMQP channels, opened from a connection
+// when first needed, safe for concurrent use.
+//
+// Channels are acquired with Acquire, and must be given back with Release
+// once done; acquiring blocks while the maximum number of channels
+// is in use. Channels closed by the AMQP broker, e.g. after a channel
+// exception, or failing the health check, are discarded automatically.
+//
+// Use New function to create a new Pool instance.
+type Pool struct {
+	conn        io.Closer
+	open        func() (Channel, error)
+	healthCheck func(Channel) error
+	confirm     bool
+	publishing  []publisher.Option
+	maxChannels int
+
+	slots chan struct{}
+
+	mx     sync.Mutex
+	idle   []*entry
+	leased map[Channel]*entry
+	closed bool
+}
+
+var (
+	_ listener.Connection = new(Pool)
+	_ publisher.Interface = new(Pool)
+	_ Channel             = (*amqp.Channel)(nil)
+)
+
+// entry is a channel opened by the Pool.
+type entry struct {
+	ch        Channel
+	closing   chan *amqp.Error
+	publisher *publisher.Publisher
+}
+
+// New returns a new Pool, opening channels from the provided connection.
+//
+// The Pool owns the connection, which is closed by Pool.Close.
+func New(conn listener.Connection, options ...Option) *Pool {
+	return newPool(conn, func() (Channel, error) {
+		ch, err := conn.Channel()
+		if err != nil {
+			return nil, err
+		}
+
+		return ch, nil
+	}, options...)
+}
+
+func newPool(conn io.Closer, open func() (Channel, error), options ...Option) *Pool {
+	pool := &Pool{
+		conn:        conn,
+		open:        open,
+		maxChannels: DefaultMaxChannels,
+		leased:      make(map[Channel]*entry),
+	}
+
+	for _, option := range options {
+		if option == nil {
+			continue
+		}
+
+		option(pool)
+	}
+
+	pool.slots = make(chan struct{}, pool.maxChannels)
+
+	return pool
+}
+
+// Option is an optional functionality that can be added to the Pool
+// that is being initialized by the New factory method.
+type Option func(*Pool)
+
+// MaxChannels specifies the maximum number of channels opened by the Pool,
+// which defaults to DefaultMaxChannels.
+func MaxChannels(max int) Option {
+	return func(pool *Pool) {
+		if max > 0 {
+			pool.maxChannels = max
+		}
+	}
+}
+
+// HealthCheck specifies a function to check idle channels before handing
+// them out: channels failing the check are closed and discarded.
+//
+// Channels closed by the AMQP broker are always discarded.
+func HealthCheck(fn func(Channel) error) Option {
+	return func(pool *Pool) { pool.healthCheck = fn }
+}
+
+// Confirm puts the pooled channels in confirm mode, so that Pool.Publish
+// waits for the broker to confirm the published messages.
+func Confirm(pool *Pool) { pool.confirm = true }
+
+// PublisherOptions specifies the options of the publisher.Publisher used
+// by Pool.Publish on each channel, e.g. publisher.WaitWhenBlocked.
+//
+// Multiple calls of this option are supported.
+func PublisherOptions(options ...publisher.Option) Option {
+	return func(pool *Pool) { pool.publishing = append(pool.publishing, options...) }
+}
+
+// Acquire returns an idle channel, or opens a new one, blocking until
+// a channel is available or the context is done.
+//
+// Channels must be given back with Release.
+func (p *Pool) Acquire(ctx context.Context) (Channel, error) {
+	e, err := p.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.ch, nil
+}
+
+func (p *Pool) acquire(ctx context.Context) (*entry, error) {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("channelpool: failed to acquire channel, %w", ctx.Err())
+	}
+
+	e, err := p.idleOrOpen()
+	if err != nil {
+		<-p.slots
+		return nil, err
+	}
+
+	return e, nil
+}
+
+func (p *Pool) idleOrOpen() (*entry, error) {
+	for {
+		e, err := p.popIdle()
+		if err != nil {
+			return nil, err
+		}
+
+		if e == nil {
+			break
+		}
+
+		if p.healthy(e) {
+			return e, p.lease(e)
+		}
+
+		// nolint:errcheck
+		e.ch.Close()
+	}
+
+	e, err := p.openEntry()
+	if err != nil {
+		return nil, err
+	}
+
+	return e, p.lease(e)
+}
+
+func (p *Pool) popIdle() (*entry, error) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	if p.closed {
+		return nil, ErrClosed
+	}
+
+	if len(p.idle) == 0 {
+		return nil, nil
+	}
+
+	e := p.idle[len(p.idle)-1]
+	p.idle = p.idle[:len(p.idle)-1]
+
+	return e, nil
+}
+
+// lease marks the channel as in use, unless the Pool has been closed
+// in the meantime.
+func (p *Pool) lease(e *entry) error {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	if p.closed {
+		// nolint:errcheck
+		e.ch.Close()
+		return ErrClosed
+	}
+
+	p.leased[e.ch] = e
+
+	return nil
+}
+
+func (p *Pool) openEntry() (*entry, error) {
+	e, err := p.openPlain()
+	if err != nil {
+		return nil, err
+	}
+
+	options := append([]publisher.Option(nil), p.publishing...)
+	if p.confirm {
+		options = append(options, publisher.Confirm)
+	}
+
+	if e.publisher, err = publisher.New(e.ch, options...); err != nil {
+		// nolint:errcheck
+		e.ch.Close()
+		return nil, fmt.Errorf("channelpool: failed to create publisher, %w", err)
+	}
+
+	return e, nil
+}
+
+// openPlain opens a new channel, without a publisher.
+func (p *Pool) openPlain() (*entry, error) {
+	ch, err := p.open()
+	if err != nil {
+		return nil, fmt.Errorf("channelpool: failed to open channel, %w", err)
+	}
+
+	return &entry{
+		ch: ch,
+		// Needs buffer, since the channel notifies closing before
+		// the Pool checks for it.
+		closing: ch.NotifyClose(make(chan *amqp.Error, 1)),
+	}, nil
+}
+
+// healthy reports whether the channel hasn't been closed and passes
+// the health check, if any.
+func (p *Pool) healthy(e *entry) bool {
+	return !e.isClosed() && (p.healthCheck == nil || p.healthCheck(e.ch) == nil)
+}
+
+// isClosed reports whether the channel has been closed.
+func (e *entry) isClosed() bool {
+	select {
+	case <-e.closing:
+		return true
+	default:
+		return false
+	}
+}
+
+// Release gives the channel back to the Pool, so that it can be acquired
+// again; closed channels are discarded.
+//
+// Releasing a channel not acquired from the Pool does nothing.
+func (p *Pool) Release(ch Channel) {
+	p.release(ch, false)
+}
+
+// Discard closes the channel and removes it from the Pool, e.g. after
+// having put it in a state not suitable for other users.
+//
+// Discarding a channel not acquired from the Pool does nothing.
+func (p *Pool) Discard(ch Channel) {
+	p.release(ch, true)
+}
+
+func (p *Pool) release(ch Channel, discard bool) {
+	p.mx.Lock()
+
+	e, ok := p.leased[ch]
+	if !ok {
+		p.mx.Unlock()
+		return
+	}
+
+	delete(p.leased, ch)
+
+	if discard || p.closed || e.isClosed() {
+		// nolint:errcheck
+		e.ch.Close()
+	} else {
+		p.idle = append(p.idle, e)
+	}
+
+	p.mx.Unlock()
+
+	<-p.slots
+}
+
+// Publish publishes the message on the specified exchange and routing key,
+// using a pooled channel.
+//
+// If the Pool uses publisher confirms, Publish waits until the broker
+// confirms the message or the context is done, whichever comes first.
+func (p *Pool) Publish(ctx context.Context, exchange, key string, msg amqp.Publishing) error {
+	e, err := p.acquire(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer p.Release(e.ch)
+
+	return e.publisher.Publish(ctx, exchange, key, msg)
+}
+
+// Channel opens a new channel counting towards the maximum number
+// of channels of the Pool, and returns it as an *amqp.Channel, so that
+// the Pool can be used as a listener.Connection.
+//
+// The channel is neither taken from the idle ones nor put in confirm mode,
+// since listeners don't drain publisher confirmations. ErrExhausted is
+// returned, instead of blocking, if the maximum number of channels is in use.
+//
+// The channel is not meant to be released: it's removed from the Pool
+// as soon as it gets closed, e.g. by the Listener using it.
+func (p *Pool) Channel() (*amqp.Channel, error) {
+	select {
+	case p.slots <- struct{}{}:
+	default:
+		return nil, ErrExhausted
+	}
+
+	e, err := p.openPlain()
+	if err != nil {
+		<-p.slots
+		return nil, err
+	}
+
+	ch, ok := e.ch.(*amqp.Channel)
+	if !ok {
+		// nolint:errcheck
+		e.ch.Close()
+		<-p.slots
+
+		return nil, ErrUnsupportedChannel
+	}
+
+	if err := p.lease(e); err != nil {
+		<-p.slots
+		return nil, err
+	}
+
+	go func() {
+		<-e.closing
+		p.Discard(e.ch)
+	}()
+
+	return ch, nil
+}
+
+// Close closes all the idle channels and the connection of the Pool:
+// channels in use are closed as soon as they're released.
+//
+// Acquiring channels from a closed Pool returns ErrClosed.
+func (p *Pool) Close() error {
+	p.mx.Lock()
+
+	p.closed = true
+
+	for _, e := range p.idle {
+		// nolint:errcheck
+		e.ch.Close()
+	}
+
+	p.idle = nil
+
+	p.mx.Unlock()
+
+	if p.conn == nil {
+		return nil
+	}
+
+	return p.conn.Close()
+}
+
+// Len returns the number of channels opened by the Pool,
+// both idle and in use.
+func (p *Pool) Len() int {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	return len(p.idle) + len(p.leased)
+}
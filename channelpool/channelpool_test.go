@@ -0,0 +1,253 @@
+package channelpool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type channel struct {
+	mx        sync.Mutex
+	closing   chan *amqp.Error
+	closed    bool
+	published []string
+	confirm   bool
+	confirms  chan amqp.Confirmation
+}
+
+func (ch *channel) Publish(exchange, key string, _, _ bool, _ amqp.Publishing) error {
+	ch.mx.Lock()
+	defer ch.mx.Unlock()
+
+	ch.published = append(ch.published, exchange+"/"+key)
+
+	if ch.confirm {
+		ch.confirms <- amqp.Confirmation{DeliveryTag: uint64(len(ch.published)), Ack: true}
+	}
+
+	return nil
+}
+
+func (ch *channel) Confirm(bool) error {
+	ch.confirm = true
+	return nil
+}
+
+func (ch *channel) NotifyPublish(confirms chan amqp.Confirmation) chan amqp.Confirmation {
+	ch.confirms = confirms
+	return confirms
+}
+
+func (ch *channel) NotifyClose(closing chan *amqp.Error) chan *amqp.Error {
+	ch.closing = closing
+	return closing
+}
+
+func (ch *channel) Close() error {
+	ch.mx.Lock()
+	defer ch.mx.Unlock()
+
+	if !ch.closed {
+		ch.closed = true
+		close(ch.closing)
+	}
+
+	return nil
+}
+
+// closeByBroker simulates a channel exception.
+func (ch *channel) closeByBroker() {
+	ch.mx.Lock()
+	defer ch.mx.Unlock()
+
+	ch.closed = true
+	ch.closing <- amqp.ErrClosed
+	close(ch.closing)
+}
+
+type connection struct {
+	opened []*channel
+	err    error
+	closed bool
+}
+
+func (conn *connection) open() (Channel, error) {
+	if conn.err != nil {
+		return nil, conn.err
+	}
+
+	ch := new(channel)
+	conn.opened = append(conn.opened, ch)
+
+	return ch, nil
+}
+
+func (conn *connection) Close() error {
+	conn.closed = true
+	return nil
+}
+
+func TestPool_AcquireRelease(t *testing.T) {
+	ctx := context.Background()
+	conn := new(connection)
+	pool := newPool(conn, conn.open, MaxChannels(2))
+
+	first, err := pool.Acquire(ctx)
+	require.NoError(t, err)
+
+	second, err := pool.Acquire(ctx)
+	require.NoError(t, err)
+
+	assert.NotSame(t, first, second)
+	assert.Equal(t, 2, pool.Len())
+
+	t.Run("blocks when all channels are in use", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+
+		_, err := pool.Acquire(ctx)
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	})
+
+	t.Run("reuses released channels", func(t *testing.T) {
+		pool.Release(first)
+
+		ch, err := pool.Acquire(ctx)
+		require.NoError(t, err)
+		assert.Same(t, first, ch)
+		assert.Len(t, conn.opened, 2)
+
+		pool.Release(ch)
+	})
+
+	t.Run("discards channels closed by the broker", func(t *testing.T) {
+		second.(*channel).closeByBroker()
+		pool.Release(second)
+
+		assert.Equal(t, 1, pool.Len())
+
+		ch, err := pool.Acquire(ctx)
+		require.NoError(t, err)
+		assert.Same(t, first, ch)
+
+		ch, err = pool.Acquire(ctx)
+		require.NoError(t, err)
+		assert.Len(t, conn.opened, 3)
+
+		pool.Discard(ch)
+		pool.Release(first)
+
+		assert.True(t, conn.opened[2].closed)
+		assert.Equal(t, 1, pool.Len())
+	})
+
+	t.Run("ignores channels not acquired from the pool", func(t *testing.T) {
+		pool.Release(new(channel))
+		assert.Equal(t, 1, pool.Len())
+	})
+
+	t.Run("closes idle channels and the connection", func(t *testing.T) {
+		ch, err := pool.Acquire(ctx)
+		require.NoError(t, err)
+
+		assert.NoError(t, pool.Close())
+		assert.True(t, conn.closed)
+
+		_, err = pool.Acquire(ctx)
+		assert.True(t, errors.Is(err, ErrClosed))
+
+		// Channels in use are closed once released.
+		assert.False(t, ch.(*channel).closed)
+		pool.Release(ch)
+		assert.True(t, ch.(*channel).closed)
+		assert.Equal(t, 0, pool.Len())
+	})
+}
+
+func TestPool_HealthCheck(t *testing.T) {
+	ctx := context.Background()
+	conn := new(connection)
+
+	unhealthy := true
+	pool := newPool(conn, conn.open, HealthCheck(func(Channel) error {
+		if unhealthy {
+			return errors.New("unhealthy")
+		}
+
+		return nil
+	}))
+
+	ch, err := pool.Acquire(ctx)
+	require.NoError(t, err)
+	pool.Release(ch)
+
+	// The idle channel fails the check on acquire, so a new one is opened.
+	ch, err = pool.Acquire(ctx)
+	require.NoError(t, err)
+	assert.Len(t, conn.opened, 2)
+	assert.True(t, conn.opened[0].closed)
+
+	unhealthy = false
+	pool.Release(ch)
+
+	again, err := pool.Acquire(ctx)
+	require.NoError(t, err)
+	assert.Same(t, ch, again)
+}
+
+func TestPool_OpenFailure(t *testing.T) {
+	conn := &connection{err: errors.New("connection closed")}
+	pool := newPool(conn, conn.open, MaxChannels(1))
+
+	for i := 0; i < 2; i++ {
+		// The slot is given back when failing to open a channel,
+		// so that the second attempt doesn't block.
+		_, err := pool.Acquire(context.Background())
+		assert.Error(t, err)
+	}
+}
+
+func TestPool_Publish(t *testing.T) {
+	ctx := context.Background()
+	conn := new(connection)
+	pool := newPool(conn, conn.open, MaxChannels(1), Confirm)
+
+	require.NoError(t, pool.Publish(ctx, "orders", "order.created", amqp.Publishing{}))
+	require.NoError(t, pool.Publish(ctx, "orders", "order.deleted", amqp.Publishing{}))
+
+	require.Len(t, conn.opened, 1)
+	assert.True(t, conn.opened[0].confirm)
+	assert.Equal(t, []string{"orders/order.created", "orders/order.deleted"}, conn.opened[0].published)
+}
+
+func TestPool_Channel(t *testing.T) {
+	conn := new(connection)
+	pool := newPool(conn, conn.open, MaxChannels(1), Confirm)
+
+	// Pooled channels in tests are not *amqp.Channel instances.
+	_, err := pool.Channel()
+	assert.True(t, errors.Is(err, ErrUnsupportedChannel))
+
+	// Channels for listeners are not put in confirm mode.
+	require.Len(t, conn.opened, 1)
+	assert.False(t, conn.opened[0].confirm)
+	assert.Nil(t, conn.opened[0].confirms)
+	assert.True(t, conn.opened[0].closed)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_, err = pool.Acquire(ctx)
+	assert.NoError(t, err)
+
+	t.Run("fails instead of blocking when all channels are in use", func(t *testing.T) {
+		_, err := pool.Channel()
+		assert.True(t, errors.Is(err, ErrExhausted))
+	})
+}
@@ -0,0 +1,353 @@
+package cli_test
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ar3s3ru/go-carrot/cli"
+	"github.com/ar3s3ru/go-carrot/topology"
+	"github.com/ar3s3ru/go-carrot/topology/exchange"
+	"github.com/ar3s3ru/go-carrot/topology/queue"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var ordersTopology = topology.All(
+	exchange.Declare("orders", exchange.Durable),
+	queue.Declare("orders.created",
+		queue.Durable,
+		queue.BindTo("orders", "order.created"),
+	),
+)
+
+func TestCLI_Run(t *testing.T) {
+	t.Run("fails with unknown commands", func(t *testing.T) {
+		var output bytes.Buffer
+
+		err := cli.New(cli.Output(&output)).Run([]string{"unknown"})
+		assert.Error(t, err)
+		assert.Contains(t, output.String(), "Usage: carrot <command> [flags]")
+	})
+
+	t.Run("prints usage with no command", func(t *testing.T) {
+		var output bytes.Buffer
+
+		err := cli.New(cli.Output(&output), cli.Topology("orders", ordersTopology)).Run(nil)
+		assert.True(t, errors.Is(err, flag.ErrHelp))
+		assert.Contains(t, output.String(), "Registered topologies: orders")
+	})
+
+	t.Run("fails without a topology", func(t *testing.T) {
+		err := cli.New(cli.Output(ioutil.Discard)).Run([]string{"apply"})
+		assert.True(t, errors.Is(err, cli.ErrNoTopology))
+	})
+
+	t.Run("fails with an unknown topology", func(t *testing.T) {
+		err := cli.New(cli.Output(ioutil.Discard)).Run([]string{"apply", "-topology", "unknown"})
+		assert.Error(t, err)
+	})
+}
+
+func TestApply(t *testing.T) {
+	t.Run("dry run prints the declarations without connecting", func(t *testing.T) {
+		var output bytes.Buffer
+
+		c := cli.New(
+			cli.Output(&output),
+			cli.Topology("orders", ordersTopology),
+			cli.Dialer(func(string) (cli.Connection, error) {
+				return nil, errors.New("should not connect")
+			}),
+		)
+
+		require.NoError(t, c.Run([]string{"apply", "-topology", "orders", "-dry-run"}))
+		assert.Equal(t, `declare exchange "orders" (topic, durable)
+declare queue "orders.created" (durable)
+declare binding exchange "orders" -> queue "orders.created" (key "order.created")
+`, output.String())
+	})
+
+	t.Run("declares the topology on the broker", func(t *testing.T) {
+		broker := newBroker()
+
+		c := cli.New(
+			cli.Output(ioutil.Discard),
+			cli.Topology("orders", ordersTopology),
+			cli.Dialer(broker.dial),
+		)
+
+		require.NoError(t, c.Run([]string{"apply", "-topology", "orders", "-url", "amqp://broker"}))
+		assert.Equal(t, "amqp://broker", broker.url)
+		assert.True(t, broker.exchanges["orders"])
+		assert.Contains(t, broker.queues, "orders.created")
+		assert.True(t, broker.closed)
+	})
+}
+
+func TestDiffAndVerify(t *testing.T) {
+	broker := newBroker()
+	broker.exchanges["orders"] = true
+
+	c := func(output *bytes.Buffer) *cli.CLI {
+		return cli.New(
+			cli.Output(output),
+			cli.Topology("orders", ordersTopology),
+			cli.Dialer(broker.dial),
+		)
+	}
+
+	var output bytes.Buffer
+
+	require.NoError(t, c(&output).Run([]string{"diff", "-topology", "orders"}))
+	assert.Equal(t, `  exchange "orders" (topic, durable)
++ queue "orders.created" (durable)
+`, output.String())
+
+	err := c(&output).Run([]string{"verify", "-topology", "orders"})
+	assert.True(t, errors.Is(err, cli.ErrTopologyMismatch))
+
+	broker.queues["orders.created"] = 3
+	output.Reset()
+
+	require.NoError(t, c(&output).Run([]string{"verify", "-topology", "orders"}))
+	assert.Equal(t, `  exchange "orders" (topic, durable)
+  queue "orders.created" (durable): 3 messages, 0 consumers
+`, output.String())
+}
+
+func TestTeardown(t *testing.T) {
+	broker := newBroker()
+	broker.exchanges["orders"] = true
+	broker.queues["orders.created"] = 2
+
+	var output bytes.Buffer
+
+	c := cli.New(
+		cli.Output(&output),
+		cli.Topology("orders", ordersTopology),
+		cli.Dialer(broker.dial),
+	)
+
+	require.NoError(t, c.Run([]string{"teardown", "-topology", "orders", "-dry-run"}))
+	assert.Contains(t, broker.queues, "orders.created")
+
+	output.Reset()
+
+	require.NoError(t, c.Run([]string{"teardown", "-topology", "orders"}))
+	assert.Equal(t, `delete queue "orders.created" (durable): 2 messages purged
+delete exchange "orders" (topic, durable)
+`, output.String())
+	assert.Empty(t, broker.queues)
+	assert.Empty(t, broker.exchanges)
+}
+
+func TestLoadFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "carrot-cli")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "topology.json")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{
+		"exchanges": [{"name": "orders", "kind": "direct", "durable": true}],
+		"queues": [{
+			"name": "orders.created",
+			"durable": true,
+			"arguments": {"x-max-length": 10, "x-queue-type": "quorum"},
+			"deadLetter": {"exchange": "orders.dlx", "routingKey": "created"},
+			"bindings": [{"exchange": "orders", "routingKey": "order.created"}]
+		}]
+	}`), 0600))
+
+	declarer, err := cli.LoadFile(path)
+	require.NoError(t, err)
+
+	spec, err := topology.Describe(declarer)
+	require.NoError(t, err)
+
+	assert.Equal(t, topology.Spec{
+		Exchanges: []topology.ExchangeSpec{{Name: "orders", Kind: "direct", Durable: true}},
+		Queues: []topology.QueueSpec{{
+			Name:    "orders.created",
+			Durable: true,
+			Arguments: amqp.Table{
+				"x-max-length":              int64(10),
+				"x-queue-type":              "quorum",
+				"x-dead-letter-exchange":    "orders.dlx",
+				"x-dead-letter-routing-key": "created",
+			},
+		}},
+		Bindings: []topology.BindingSpec{{
+			Source:          "orders",
+			Destination:     "orders.created",
+			DestinationType: topology.QueueDestination,
+			RoutingKey:      "order.created",
+		}},
+	}, spec)
+
+	t.Run("fails with unknown fields", func(t *testing.T) {
+		require.NoError(t, ioutil.WriteFile(path, []byte(`{"exchange": []}`), 0600))
+
+		_, err := cli.LoadFile(path)
+		assert.Error(t, err)
+	})
+}
+
+// broker is an in-memory implementation of cli.Connection and cli.Channel,
+// storing the declared exchanges, the number of messages of each queue
+// and the messages that can be received from each queue.
+type broker struct {
+	url       string
+	closed    bool
+	exchanges map[string]bool
+	queues    map[string]int
+	messages  map[string][]amqp.Delivery
+	published []published
+	confirms  chan amqp.Confirmation
+	returns   chan amqp.Return
+}
+
+type published struct {
+	exchange   string
+	routingKey string
+	msg        amqp.Publishing
+}
+
+func newBroker() *broker {
+	return &broker{
+		exchanges: make(map[string]bool),
+		queues:    make(map[string]int),
+		messages:  make(map[string][]amqp.Delivery),
+	}
+}
+
+func (b *broker) dial(url string) (cli.Connection, error) {
+	b.url = url
+	return b, nil
+}
+
+func (b *broker) Channel() (cli.Channel, error) { return b, nil }
+
+func (b *broker) Close() error {
+	b.closed = true
+	return nil
+}
+
+func (b *broker) Tx() error         { return nil }
+func (b *broker) TxCommit() error   { return nil }
+func (b *broker) TxRollback() error { return nil }
+
+func (b *broker) ExchangeDeclare(name, _ string, _, _, _, _ bool, _ amqp.Table) error {
+	b.exchanges[name] = true
+	return nil
+}
+
+func (b *broker) ExchangeDeclarePassive(name, _ string, _, _, _, _ bool, _ amqp.Table) error {
+	if !b.exchanges[name] {
+		return &amqp.Error{Code: amqp.NotFound, Reason: "NOT_FOUND"}
+	}
+
+	return nil
+}
+
+func (b *broker) QueueDeclare(name string, _, _, _, _ bool, _ amqp.Table) (amqp.Queue, error) {
+	b.queues[name] += 0
+	return amqp.Queue{Name: name, Messages: b.queues[name]}, nil
+}
+
+func (b *broker) QueueDeclarePassive(name string, _, _, _, _ bool, _ amqp.Table) (amqp.Queue, error) {
+	messages, ok := b.queues[name]
+	if !ok {
+		return amqp.Queue{}, &amqp.Error{Code: amqp.NotFound, Reason: "NOT_FOUND"}
+	}
+
+	return amqp.Queue{Name: name, Messages: messages}, nil
+}
+
+func (b *broker) QueueDelete(name string, _, _, _ bool) (int, error) {
+	messages := b.queues[name]
+	delete(b.queues, name)
+
+	return messages, nil
+}
+
+func (b *broker) ExchangeDelete(name string, _, _ bool) error {
+	delete(b.exchanges, name)
+	return nil
+}
+
+func (b *broker) QueueBind(string, string, string, bool, amqp.Table) error      { return nil }
+func (b *broker) QueueUnbind(string, string, string, amqp.Table) error          { return nil }
+func (b *broker) ExchangeBind(string, string, string, bool, amqp.Table) error   { return nil }
+func (b *broker) ExchangeUnbind(string, string, string, bool, amqp.Table) error { return nil }
+
+// Get returns the first message of the queue, which is put back
+// at the end of the queue if requeued.
+func (b *broker) Get(queue string, _ bool) (amqp.Delivery, bool, error) {
+	messages := b.messages[queue]
+	if len(messages) == 0 {
+		return amqp.Delivery{}, false, nil
+	}
+
+	delivery := messages[0]
+	delivery.MessageCount = uint32(len(messages) - 1)
+	delivery.Acknowledger = &acknowledger{broker: b, queue: queue, delivery: delivery}
+	b.messages[queue] = messages[1:]
+
+	return delivery, true, nil
+}
+
+func (b *broker) Confirm(bool) error { return nil }
+
+func (b *broker) NotifyPublish(confirms chan amqp.Confirmation) chan amqp.Confirmation {
+	b.confirms = make(chan amqp.Confirmation, 10)
+	return b.confirms
+}
+
+func (b *broker) NotifyReturn(returns chan amqp.Return) chan amqp.Return {
+	b.returns = make(chan amqp.Return, 10)
+	return b.returns
+}
+
+// Publish returns mandatory messages published on the default exchange
+// to unknown queues, and confirms all messages when in confirm mode.
+func (b *broker) Publish(exchange, key string, mandatory, _ bool, msg amqp.Publishing) error {
+	b.published = append(b.published, published{exchange: exchange, routingKey: key, msg: msg})
+
+	if _, ok := b.queues[key]; mandatory && exchange == "" && !ok && b.returns != nil {
+		b.returns <- amqp.Return{ReplyCode: amqp.NoRoute, ReplyText: "NO_ROUTE", RoutingKey: key}
+	}
+
+	if b.confirms != nil {
+		b.confirms <- amqp.Confirmation{DeliveryTag: uint64(len(b.published)), Ack: true}
+	}
+
+	return nil
+}
+
+type acknowledger struct {
+	broker   *broker
+	queue    string
+	delivery amqp.Delivery
+}
+
+func (*acknowledger) Ack(uint64, bool) error { return nil }
+
+func (ack *acknowledger) Nack(_ uint64, _ bool, requeue bool) error {
+	if requeue {
+		ack.broker.messages[ack.queue] = append(ack.broker.messages[ack.queue], ack.delivery)
+	}
+
+	return nil
+}
+
+func (ack *acknowledger) Reject(tag uint64, requeue bool) error {
+	return ack.Nack(tag, false, requeue)
+}
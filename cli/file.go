@@ -0,0 +1,146 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/ar3s3ru/go-carrot/internal/amqptable"
+	"github.com/ar3s3ru/go-carrot/topology"
+	"github.com/ar3s3ru/go-carrot/topology/exchange"
+	"github.com/ar3s3ru/go-carrot/topology/exchange/kind"
+	"github.com/ar3s3ru/go-carrot/topology/queue"
+)
+
+// File is the JSON format of topology files, such as:
+//
+//	{
+//		"exchanges": [
+//			{"name": "orders", "kind": "topic", "durable": true}
+//		],
+//		"queues": [
+//			{
+//				"name": "orders.created",
+//				"durable": true,
+//				"arguments": {"x-queue-type": "quorum"},
+//				"deadLetter": {"exchange": "orders.dlx", "routingKey": "created"},
+//				"bindings": [{"exchange": "orders", "routingKey": "order.created"}]
+//			}
+//		]
+//	}
+type File struct {
+	Exchanges []FileExchange `json:"exchanges"`
+	Queues    []FileQueue    `json:"queues"`
+}
+
+// FileExchange describes an exchange in a topology File.
+type FileExchange struct {
+	Name       string                 `json:"name"`
+	Kind       string                 `json:"kind"`
+	Durable    bool                   `json:"durable"`
+	AutoDelete bool                   `json:"autoDelete"`
+	Internal   bool                   `json:"internal"`
+	Arguments  map[string]interface{} `json:"arguments"`
+	Bindings   []FileBinding          `json:"bindings"`
+}
+
+// FileQueue describes a queue in a topology File.
+type FileQueue struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Durable     bool                   `json:"durable"`
+	AutoDelete  bool                   `json:"autoDelete"`
+	Exclusive   bool                   `json:"exclusive"`
+	Arguments   map[string]interface{} `json:"arguments"`
+	DeadLetter  *FileBinding           `json:"deadLetter"`
+	Bindings    []FileBinding          `json:"bindings"`
+}
+
+// FileBinding describes a binding to the source exchange in a topology File.
+type FileBinding struct {
+	Exchange   string `json:"exchange"`
+	RoutingKey string `json:"routingKey"`
+}
+
+// LoadFile loads the topology File at the specified path.
+func LoadFile(path string) (topology.Declarer, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cli.LoadFile: failed to read file, %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	decoder.UseNumber()
+
+	var file File
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("cli.LoadFile: failed to decode %s, %w", path, err)
+	}
+
+	return file.Declarer(), nil
+}
+
+// Declarer returns the topology.Declarer of all the exchanges and queues
+// in the File, declared in a single transaction.
+func (file File) Declarer() topology.Declarer {
+	declarers := make([]topology.Declarer, 0, len(file.Exchanges)+len(file.Queues))
+
+	for _, e := range file.Exchanges {
+		options := []exchange.Option{exchange.Arguments(amqptable.FromJSON(e.Arguments))}
+
+		if e.Kind != "" {
+			options = append(options, exchange.Kind(kind.Kind(e.Kind)))
+		}
+
+		if e.Durable {
+			options = append(options, exchange.Durable)
+		}
+
+		if e.AutoDelete {
+			options = append(options, exchange.AutoDelete)
+		}
+
+		if e.Internal {
+			options = append(options, exchange.Exclusive)
+		}
+
+		for _, binding := range e.Bindings {
+			options = append(options, exchange.BindTo(binding.Exchange, binding.RoutingKey))
+		}
+
+		declarers = append(declarers, exchange.Declare(e.Name, options...))
+	}
+
+	for _, q := range file.Queues {
+		options := []queue.Option{
+			queue.Description(q.Description),
+			queue.Arguments(amqptable.FromJSON(q.Arguments)),
+		}
+
+		if q.Durable {
+			options = append(options, queue.Durable)
+		}
+
+		if q.AutoDelete {
+			options = append(options, queue.AutoDelete)
+		}
+
+		if q.Exclusive {
+			options = append(options, queue.Exclusive)
+		}
+
+		if q.DeadLetter != nil {
+			options = append(options, queue.DeadLetter(q.DeadLetter.Exchange, q.DeadLetter.RoutingKey))
+		}
+
+		for _, binding := range q.Bindings {
+			options = append(options, queue.BindTo(binding.Exchange, binding.RoutingKey))
+		}
+
+		declarers = append(declarers, queue.Declare(q.Name, options...))
+	}
+
+	return topology.All(declarers...)
+}
@@ -0,0 +1,116 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ar3s3ru/go-carrot/deadletter"
+
+	"github.com/streadway/amqp"
+)
+
+// filter selects the messages the queue commands operate on.
+type filter struct {
+	headers    headerFlags
+	routingKey string
+	olderThan  time.Duration
+	newerThan  time.Duration
+
+	now func() time.Time
+}
+
+// filter registers the flags used to filter messages.
+func (f *flags) filter() *filter {
+	flt := &filter{headers: make(headerFlags), now: time.Now}
+
+	f.Var(flt.headers, "header", "only messages with the header, as key=value (repeatable)")
+	f.StringVar(&flt.routingKey, "routing-key", "", "only messages with a routing key matching the glob pattern")
+	f.DurationVar(&flt.olderThan, "older-than", 0, "only messages older than the duration")
+	f.DurationVar(&flt.newerThan, "newer-than", 0, "only messages newer than the duration")
+
+	return flt
+}
+
+// match reports whether the delivery is selected by the filter.
+//
+// The routing key pattern is matched against both the routing key of the
+// delivery and the one of the original message, if dead-lettered.
+// The age of a message is computed from its timestamp property or,
+// if missing, from the time it was dead-lettered.
+func (flt *filter) match(delivery amqp.Delivery) bool {
+	for key, value := range flt.headers {
+		header, ok := delivery.Headers[key]
+		if !ok || fmt.Sprint(header) != value {
+			return false
+		}
+	}
+
+	if flt.routingKey != "" && !flt.matchRoutingKey(delivery) {
+		return false
+	}
+
+	if flt.olderThan == 0 && flt.newerThan == 0 {
+		return true
+	}
+
+	published := delivery.Timestamp
+	if death, ok := deadletter.Last(delivery.Headers); ok && published.IsZero() {
+		published = death.Time
+	}
+
+	if published.IsZero() {
+		return false
+	}
+
+	age := flt.now().Sub(published)
+
+	return (flt.olderThan == 0 || age > flt.olderThan) &&
+		(flt.newerThan == 0 || age < flt.newerThan)
+}
+
+func (flt *filter) matchRoutingKey(delivery amqp.Delivery) bool {
+	keys := []string{delivery.RoutingKey}
+
+	if death, ok := deadletter.Last(delivery.Headers); ok {
+		keys = append(keys, death.RoutingKey())
+	}
+
+	for _, key := range keys {
+		if matched, _ := path.Match(flt.routingKey, key); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// headerFlags is a repeatable flag of key=value pairs.
+type headerFlags map[string]string
+
+var _ flag.Value = headerFlags(nil)
+
+func (h headerFlags) String() string {
+	pairs := make([]string, 0, len(h))
+	for key, value := range h {
+		pairs = append(pairs, key+"="+value)
+	}
+
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, ",")
+}
+
+func (h headerFlags) Set(pair string) error {
+	i := strings.Index(pair, "=")
+	if i < 0 {
+		return fmt.Errorf("invalid header %q, must be key=value", pair)
+	}
+
+	h[pair[:i]] = pair[i+1:]
+
+	return nil
+}
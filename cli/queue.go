@@ -0,0 +1,378 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ar3s3ru/go-carrot/deadletter"
+	"github.com/ar3s3ru/go-carrot/internal/republish"
+	"github.com/ar3s3ru/go-carrot/publisher"
+	"github.com/ar3s3ru/go-carrot/recording"
+
+	"github.com/streadway/amqp"
+)
+
+// ErrNoQueue is returned by the queue commands when the -queue flag
+// has not been specified.
+var ErrNoQueue = errors.New("cli: no queue specified, use -queue")
+
+var queueCommands = map[string]command{
+	"peek":   {usage: "prints the messages of the queue, leaving them in the queue", run: (*CLI).peek},
+	"dump":   {usage: "writes the messages of the queue to a JSON lines file", run: (*CLI).dump},
+	"replay": {usage: "publishes dumped or dead-lettered messages to their original exchange", run: (*CLI).replay},
+	"move":   {usage: "moves the messages of the queue to another exchange or queue", run: (*CLI).move},
+}
+
+func (cli *CLI) queue(args []string) error {
+	return cli.dispatch("carrot queue", queueCommands, args)
+}
+
+func (cli *CLI) peek(args []string) error {
+	f := cli.flags("queue peek")
+	queue := f.String("queue", "", "name of the queue")
+	count := f.Int("count", 10, "maximum number of messages, 0 for all")
+	filter := f.filter()
+
+	if err := f.Parse(args); err != nil {
+		return err
+	}
+
+	w := recording.NewWriter(cli.output)
+
+	return cli.withQueue(f, *queue, func(ch Channel) error {
+		return each(ch, *queue, *count, filter, func(delivery amqp.Delivery) (bool, error) {
+			return false, w.Write(recording.NewMessage(delivery))
+		})
+	})
+}
+
+func (cli *CLI) dump(args []string) error {
+	f := cli.flags("queue dump")
+	queue := f.String("queue", "", "name of the queue")
+	count := f.Int("count", 0, "maximum number of messages, 0 for all")
+	output := f.String("output", "-", "path of the JSON lines file, - for the standard output")
+	remove := f.Bool("remove", false, "remove the dumped messages from the queue")
+	filter := f.filter()
+
+	if err := f.Parse(args); err != nil {
+		return err
+	}
+
+	out := cli.output
+
+	if *output != "-" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("cli: failed to create dump file, %w", err)
+		}
+
+		defer file.Close()
+
+		out = file
+	}
+
+	w := recording.NewWriter(out)
+
+	return cli.withQueue(f, *queue, func(ch Channel) error {
+		return each(ch, *queue, *count, filter, func(delivery amqp.Delivery) (bool, error) {
+			return *remove, w.Write(recording.NewMessage(delivery))
+		})
+	})
+}
+
+func (cli *CLI) replay(args []string) error {
+	f := cli.flags("queue replay")
+	queue := f.String("queue", "", "name of the dead-letter queue to replay messages from")
+	input := f.String("input", "", "path of the JSON lines file to replay messages from, - for the standard input")
+	count := f.Int("count", 0, "maximum number of messages, 0 for all")
+	dest := f.destination()
+	dryRun := f.Bool("dry-run", false, "print where messages would be published, without publishing them")
+	filter := f.filter()
+
+	if err := f.Parse(args); err != nil {
+		return err
+	}
+
+	if (*queue == "") == (*input == "") {
+		return errors.New("cli: either -queue or -input must be specified")
+	}
+
+	// Messages are published to the exchange and routing key they had been
+	// published with before being dead-lettered, if available, otherwise
+	// to the ones they have been received from.
+	route := func(delivery amqp.Delivery) (string, string) {
+		if death, ok := deadletter.Last(delivery.Headers); ok {
+			return dest.route(death.Exchange, death.RoutingKey())
+		}
+
+		return dest.route(delivery.Exchange, delivery.RoutingKey)
+	}
+
+	if *queue != "" {
+		return cli.withQueue(f, *queue, func(ch Channel) error {
+			pub, err := confirmed(ch, *dryRun)
+			if err != nil {
+				return err
+			}
+
+			return each(ch, *queue, *count, filter, func(delivery amqp.Delivery) (bool, error) {
+				return cli.publish(pub, delivery, "replay", *dryRun, route)
+			})
+		})
+	}
+
+	var r io.Reader = os.Stdin
+
+	if *input != "-" {
+		file, err := os.Open(*input)
+		if err != nil {
+			return fmt.Errorf("cli: failed to open input file, %w", err)
+		}
+
+		defer file.Close()
+
+		r = file
+	}
+
+	var pub publisher.Interface
+
+	if !*dryRun {
+		conn, err := cli.connect(f)
+		if err != nil {
+			return err
+		}
+
+		defer conn.Close()
+
+		ch, err := conn.Channel()
+		if err != nil {
+			return fmt.Errorf("cli: failed to open channel, %w", err)
+		}
+
+		defer ch.Close()
+
+		if pub, err = confirmed(ch, false); err != nil {
+			return err
+		}
+	}
+
+	var replayed int
+
+	return recording.Read(r, func(msg recording.Message) error {
+		delivery := msg.Delivery()
+
+		if !filter.match(delivery) || (*count > 0 && replayed >= *count) {
+			return nil
+		}
+
+		replayed++
+		_, err := cli.publish(pub, delivery, "replay", *dryRun, route)
+
+		return err
+	})
+}
+
+func (cli *CLI) move(args []string) error {
+	f := cli.flags("queue move")
+	queue := f.String("queue", "", "name of the queue to move messages from")
+	count := f.Int("count", 0, "maximum number of messages, 0 for all")
+	dest := f.destination()
+	toQueue := f.String("to-queue", "", "name of the queue to move messages to, using the default exchange")
+	dryRun := f.Bool("dry-run", false, "print where messages would be moved, without moving them")
+	filter := f.filter()
+
+	if err := f.Parse(args); err != nil {
+		return err
+	}
+
+	if *toQueue != "" {
+		dest.exchange, dest.routingKey = "", *toQueue
+	}
+
+	if dest.exchange == "" && dest.routingKey == "" {
+		return errors.New("cli: either -to-exchange or -to-queue must be specified")
+	}
+
+	route := func(delivery amqp.Delivery) (string, string) {
+		return dest.route(delivery.Exchange, delivery.RoutingKey)
+	}
+
+	return cli.withQueue(f, *queue, func(ch Channel) error {
+		pub, err := confirmed(ch, *dryRun)
+		if err != nil {
+			return err
+		}
+
+		return each(ch, *queue, *count, filter, func(delivery amqp.Delivery) (bool, error) {
+			return cli.publish(pub, delivery, "move", *dryRun, route)
+		})
+	})
+}
+
+// destination overrides the exchange and routing key messages
+// are published to.
+type destination struct {
+	exchange   string
+	routingKey string
+}
+
+// destination registers the flags used to specify where messages
+// are published to.
+func (f *flags) destination() *destination {
+	dest := new(destination)
+
+	f.StringVar(&dest.exchange, "to-exchange", "", "exchange to publish messages to")
+	f.StringVar(&dest.routingKey, "to-routing-key", "", "routing key to publish messages with")
+
+	return dest
+}
+
+func (dest *destination) route(exchange, routingKey string) (string, string) {
+	if dest.exchange != "" {
+		exchange = dest.exchange
+	}
+
+	if dest.routingKey != "" {
+		routingKey = dest.routingKey
+	}
+
+	return exchange, routingKey
+}
+
+// confirmed returns a publisher.Publisher publishing messages on the channel
+// with publisher confirms and the mandatory flag, so that messages are
+// acknowledged only once the AMQP broker routed them to a queue.
+//
+// No publisher is returned on dry runs, leaving the channel as it is.
+func confirmed(ch Channel, dryRun bool) (publisher.Interface, error) {
+	if dryRun {
+		return nil, nil
+	}
+
+	pub, err := publisher.New(ch, publisher.Mandatory)
+	if err != nil {
+		return nil, fmt.Errorf("cli: failed to create publisher, %w", err)
+	}
+
+	return pub, nil
+}
+
+// publish publishes the delivery to the exchange and routing key returned
+// by route, keeping all its properties and headers, and reports whether
+// the delivery has been confirmed by the AMQP broker and should be acknowledged.
+//
+// Messages that couldn't be routed to any queue, or that have been nacked
+// by the AMQP broker, are reported as errors and put back in their queue.
+func (cli *CLI) publish(
+	pub publisher.Interface,
+	delivery amqp.Delivery,
+	action string,
+	dryRun bool,
+	route func(amqp.Delivery) (string, string),
+) (bool, error) {
+	exchange, routingKey := route(delivery)
+
+	if dryRun {
+		cli.printf("%s message %q to exchange %q with routing key %q (dry run)",
+			action, delivery.MessageId, exchange, routingKey)
+
+		return false, nil
+	}
+
+	if err := pub.Publish(context.Background(), exchange, routingKey, republish.Publishing(delivery)); err != nil {
+		return false, fmt.Errorf("cli: failed to publish message to %q, %w", exchange, err)
+	}
+
+	cli.printf("%s message %q to exchange %q with routing key %q", action, delivery.MessageId, exchange, routingKey)
+
+	return true, nil
+}
+
+// withQueue connects to the AMQP broker and calls fn with a new channel.
+func (cli *CLI) withQueue(f *flags, queue string, fn func(Channel) error) error {
+	if queue == "" {
+		return ErrNoQueue
+	}
+
+	conn, err := cli.connect(f)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("cli: failed to open channel, %w", err)
+	}
+
+	defer ch.Close()
+
+	return fn(ch)
+}
+
+// each calls fn for the messages in the queue matching the filter, up to limit
+// if greater than zero, acknowledging the ones fn returns true for.
+// All the other messages are put back in the queue once done.
+//
+// Only the messages in the queue when each is called are considered,
+// so that messages published back to the same queue are not processed twice.
+func each(
+	ch Channel,
+	queue string,
+	limit int,
+	flt *filter,
+	fn func(amqp.Delivery) (bool, error),
+) (err error) {
+	var unacked []amqp.Delivery
+
+	defer func() {
+		for _, delivery := range unacked {
+			if nackErr := delivery.Nack(false, true); nackErr != nil && err == nil {
+				err = fmt.Errorf("cli: failed to requeue message, %w", nackErr)
+			}
+		}
+	}()
+
+	for got, remaining, matched := 0, 1, 0; got < remaining && (limit <= 0 || matched < limit); got++ {
+		delivery, ok, err := ch.Get(queue, false)
+		if err != nil {
+			return fmt.Errorf("cli: failed to get message from %q, %w", queue, err)
+		}
+
+		if !ok {
+			return nil
+		}
+
+		if got == 0 {
+			remaining = int(delivery.MessageCount) + 1
+		}
+
+		if !flt.match(delivery) {
+			unacked = append(unacked, delivery)
+			continue
+		}
+
+		matched++
+
+		ack, err := fn(delivery)
+		if err != nil || !ack {
+			unacked = append(unacked, delivery)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if ack {
+			if err := delivery.Ack(false); err != nil {
+				return fmt.Errorf("cli: failed to acknowledge message, %w", err)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,180 @@
+package cli_test
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ar3s3ru/go-carrot/cli"
+	"github.com/ar3s3ru/go-carrot/publisher"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func deadLettered(id, routingKey string, age time.Duration) amqp.Delivery {
+	return amqp.Delivery{
+		Exchange:   "orders.dlx",
+		RoutingKey: "dead",
+		MessageId:  id,
+		Headers: amqp.Table{
+			"tenant": "acme",
+			"x-death": []interface{}{
+				amqp.Table{
+					"count":        int64(1),
+					"reason":       "rejected",
+					"queue":        "orders.created",
+					"exchange":     "orders",
+					"routing-keys": []interface{}{routingKey},
+					"time":         time.Now().Add(-age),
+				},
+			},
+		},
+		Body: []byte(id),
+	}
+}
+
+func TestQueuePeek(t *testing.T) {
+	broker := newBroker()
+	broker.messages["orders.dlq"] = []amqp.Delivery{
+		deadLettered("1", "order.created", time.Hour),
+		deadLettered("2", "order.deleted", time.Hour),
+		deadLettered("3", "order.created", time.Minute),
+	}
+
+	var output bytes.Buffer
+
+	c := cli.New(cli.Output(&output), cli.Dialer(broker.dial))
+
+	require.NoError(t, c.Run([]string{"queue", "peek",
+		"-queue", "orders.dlq",
+		"-routing-key", "order.created",
+		"-older-than", "30m",
+	}))
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	require.Len(t, lines, 1)
+	assert.Contains(t, lines[0], `"messageId":"1"`)
+
+	// All messages are left in the queue.
+	assert.Len(t, broker.messages["orders.dlq"], 3)
+
+	t.Run("fails without a queue", func(t *testing.T) {
+		err := c.Run([]string{"queue", "peek"})
+		assert.True(t, errors.Is(err, cli.ErrNoQueue))
+	})
+}
+
+func TestQueueDumpAndReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "carrot-cli")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "dump.jsonl")
+
+	broker := newBroker()
+	broker.messages["orders.dlq"] = []amqp.Delivery{
+		deadLettered("1", "order.created", time.Hour),
+		deadLettered("2", "order.deleted", time.Hour),
+	}
+
+	c := cli.New(cli.Output(ioutil.Discard), cli.Dialer(broker.dial))
+
+	require.NoError(t, c.Run([]string{"queue", "dump",
+		"-queue", "orders.dlq",
+		"-output", path,
+		"-header", "tenant=acme",
+		"-remove",
+	}))
+
+	assert.Empty(t, broker.messages["orders.dlq"])
+
+	require.NoError(t, c.Run([]string{"queue", "replay", "-input", path, "-routing-key", "order.deleted"}))
+	require.Len(t, broker.published, 1)
+
+	replayed := broker.published[0]
+	assert.Equal(t, "orders", replayed.exchange)
+	assert.Equal(t, "order.deleted", replayed.routingKey)
+	assert.Equal(t, "2", replayed.msg.MessageId)
+	assert.Equal(t, []byte("2"), replayed.msg.Body)
+	assert.Equal(t, "acme", replayed.msg.Headers["tenant"])
+}
+
+func TestQueueReplay(t *testing.T) {
+	broker := newBroker()
+	broker.messages["orders.dlq"] = []amqp.Delivery{
+		deadLettered("1", "order.created", time.Hour),
+		deadLettered("2", "order.created", time.Hour),
+		{MessageId: "3", Exchange: "other", RoutingKey: "other.key"},
+	}
+
+	c := cli.New(cli.Output(ioutil.Discard), cli.Dialer(broker.dial))
+
+	t.Run("dry run leaves messages in the queue", func(t *testing.T) {
+		require.NoError(t, c.Run([]string{"queue", "replay", "-queue", "orders.dlq", "-dry-run"}))
+		assert.Empty(t, broker.published)
+		assert.Len(t, broker.messages["orders.dlq"], 3)
+	})
+
+	t.Run("publishes messages to their origin", func(t *testing.T) {
+		require.NoError(t, c.Run([]string{"queue", "replay", "-queue", "orders.dlq", "-count", "2"}))
+		require.Len(t, broker.published, 2)
+
+		for _, published := range broker.published {
+			assert.Equal(t, "orders", published.exchange)
+			assert.Equal(t, "order.created", published.routingKey)
+		}
+
+		remaining := broker.messages["orders.dlq"]
+		require.Len(t, remaining, 1)
+		assert.Equal(t, "3", remaining[0].MessageId)
+	})
+}
+
+func TestQueueMove(t *testing.T) {
+	broker := newBroker()
+	broker.messages["orders"] = []amqp.Delivery{
+		{MessageId: "1", RoutingKey: "order.created"},
+		{MessageId: "2", RoutingKey: "order.deleted"},
+	}
+	broker.queues["orders.v2"] = 0
+
+	c := cli.New(cli.Output(ioutil.Discard), cli.Dialer(broker.dial))
+
+	require.NoError(t, c.Run([]string{"queue", "move",
+		"-queue", "orders",
+		"-to-queue", "orders.v2",
+		"-routing-key", "*.created",
+	}))
+
+	require.Len(t, broker.published, 1)
+	assert.Equal(t, published{
+		exchange:   "",
+		routingKey: "orders.v2",
+		msg:        amqp.Publishing{MessageId: "1"},
+	}, broker.published[0])
+
+	remaining := broker.messages["orders"]
+	require.Len(t, remaining, 1)
+	assert.Equal(t, "2", remaining[0].MessageId)
+
+	t.Run("fails without a destination", func(t *testing.T) {
+		assert.Error(t, c.Run([]string{"queue", "move", "-queue", "orders"}))
+	})
+
+	t.Run("keeps messages that can't be routed", func(t *testing.T) {
+		err := c.Run([]string{"queue", "move", "-queue", "orders", "-to-queue", "unknown"})
+		assert.True(t, errors.Is(err, publisher.ErrReturned))
+
+		remaining := broker.messages["orders"]
+		require.Len(t, remaining, 1)
+		assert.Equal(t, "2", remaining[0].MessageId)
+	})
+}
@@ -0,0 +1,355 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ar3s3ru/go-carrot/topology"
+
+	"github.com/streadway/amqp"
+)
+
+func (cli *CLI) apply(args []string) error {
+	f := cli.topologyFlags("apply")
+	dryRun := f.Bool("dry-run", false, "print the declarations without applying them")
+
+	if err := f.Parse(args); err != nil {
+		return err
+	}
+
+	declarer, spec, err := cli.spec(f)
+	if err != nil {
+		return err
+	}
+
+	if err := topology.Validate(declarer); err != nil {
+		return err
+	}
+
+	cli.printSpec("declare", spec)
+
+	if *dryRun {
+		return nil
+	}
+
+	conn, err := cli.connect(f)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("cli: failed to open channel, %w", err)
+	}
+
+	defer ch.Close()
+
+	if err := declarer.Declare(ch); err != nil {
+		return fmt.Errorf("cli: failed to apply topology, %w", err)
+	}
+
+	cli.printf("topology applied")
+
+	return nil
+}
+
+func (cli *CLI) diff(args []string) error {
+	f := cli.topologyFlags("diff")
+
+	if err := f.Parse(args); err != nil {
+		return err
+	}
+
+	_, spec, err := cli.spec(f)
+	if err != nil {
+		return err
+	}
+
+	_, err = cli.compare(f, spec)
+
+	return err
+}
+
+func (cli *CLI) verify(args []string) error {
+	f := cli.topologyFlags("verify")
+	strict := f.Bool("strict", false, "fail on bindings referencing undeclared exchanges or queues")
+
+	if err := f.Parse(args); err != nil {
+		return err
+	}
+
+	declarer, spec, err := cli.spec(f)
+	if err != nil {
+		return err
+	}
+
+	var options []topology.ValidateOption
+	if *strict {
+		options = append(options, topology.Strict)
+	}
+
+	if err := topology.Validate(declarer, options...); err != nil {
+		return err
+	}
+
+	missing, err := cli.compare(f, spec)
+	if err != nil {
+		return err
+	}
+
+	if missing > 0 {
+		return fmt.Errorf("%w, %d exchanges or queues missing", ErrTopologyMismatch, missing)
+	}
+
+	return nil
+}
+
+// compare prints the exchanges and queues of the Spec, marking the ones
+// missing from the AMQP broker with "+", and returns the number of missing ones.
+//
+// Exchanges and queues are checked with passive declarations, which only
+// report whether they exist: bindings and arguments are not compared.
+func (cli *CLI) compare(f *flags, spec topology.Spec) (int, error) {
+	conn, err := cli.connect(f)
+	if err != nil {
+		return 0, err
+	}
+
+	defer conn.Close()
+
+	var missing int
+
+	// Passive declarations of missing exchanges and queues close the channel,
+	// so every check uses a new one.
+	check := func(declare func(Channel) (string, error)) (string, bool, error) {
+		ch, err := conn.Channel()
+		if err != nil {
+			return "", false, fmt.Errorf("cli: failed to open channel, %w", err)
+		}
+
+		defer ch.Close()
+
+		details, err := declare(ch)
+
+		var amqpErr *amqp.Error
+		if errors.As(err, &amqpErr) && amqpErr.Code == amqp.NotFound {
+			return "", false, nil
+		}
+
+		return details, err == nil, err
+	}
+
+	for _, exchange := range uniqueExchanges(spec) {
+		_, exists, err := check(func(ch Channel) (string, error) {
+			return "", ch.ExchangeDeclarePassive(exchange.Name, exchange.Kind,
+				exchange.Durable, exchange.AutoDelete, exchange.Internal, false, exchange.Arguments)
+		})
+
+		if err != nil {
+			return missing, fmt.Errorf("cli: failed to check exchange %q, %w", exchange.Name, err)
+		}
+
+		if !exists {
+			missing++
+			cli.printf("+ %s", describeExchange(exchange))
+		} else {
+			cli.printf("  %s", describeExchange(exchange))
+		}
+	}
+
+	for _, queue := range uniqueQueues(spec) {
+		details, exists, err := check(func(ch Channel) (string, error) {
+			q, err := ch.QueueDeclarePassive(queue.Name, queue.Durable,
+				queue.AutoDelete, queue.Exclusive, false, queue.Arguments)
+
+			return fmt.Sprintf("%d messages, %d consumers", q.Messages, q.Consumers), err
+		})
+
+		if err != nil {
+			return missing, fmt.Errorf("cli: failed to check queue %q, %w", queue.Name, err)
+		}
+
+		if !exists {
+			missing++
+			cli.printf("+ %s", describeQueue(queue))
+		} else {
+			cli.printf("  %s: %s", describeQueue(queue), details)
+		}
+	}
+
+	return missing, nil
+}
+
+func (cli *CLI) teardown(args []string) error {
+	f := cli.topologyFlags("teardown")
+	dryRun := f.Bool("dry-run", false, "print the deletions without applying them")
+	ifUnused := f.Bool("if-unused", false, "delete only exchanges and queues not in use")
+	ifEmpty := f.Bool("if-empty", false, "delete only empty queues")
+
+	if err := f.Parse(args); err != nil {
+		return err
+	}
+
+	_, spec, err := cli.spec(f)
+	if err != nil {
+		return err
+	}
+
+	queues, exchanges := uniqueQueues(spec), uniqueExchanges(spec)
+
+	if *dryRun {
+		cli.printSpec("delete", topology.Spec{Queues: queues, Exchanges: exchanges})
+		return nil
+	}
+
+	conn, err := cli.connect(f)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("cli: failed to open channel, %w", err)
+	}
+
+	defer ch.Close()
+
+	for _, queue := range queues {
+		purged, err := ch.QueueDelete(queue.Name, *ifUnused, *ifEmpty, false)
+		if err != nil {
+			return fmt.Errorf("cli: failed to delete queue %q, %w", queue.Name, err)
+		}
+
+		cli.printf("delete %s: %d messages purged", describeQueue(queue), purged)
+	}
+
+	for _, exchange := range exchanges {
+		if err := ch.ExchangeDelete(exchange.Name, *ifUnused, false); err != nil {
+			return fmt.Errorf("cli: failed to delete exchange %q, %w", exchange.Name, err)
+		}
+
+		cli.printf("delete %s", describeExchange(exchange))
+	}
+
+	return nil
+}
+
+// printSpec prints all the exchanges, queues and bindings of the Spec,
+// prefixed by the specified action.
+func (cli *CLI) printSpec(action string, spec topology.Spec) {
+	for _, exchange := range spec.Exchanges {
+		cli.printf("%s %s", action, describeExchange(exchange))
+	}
+
+	for _, queue := range spec.Queues {
+		cli.printf("%s %s", action, describeQueue(queue))
+	}
+
+	for _, binding := range spec.Bindings {
+		cli.printf("%s %s", action, describeBinding(binding))
+	}
+}
+
+// uniqueExchanges returns the exchanges of the Spec, without duplicates
+// and the exchanges predeclared by the AMQP broker.
+func uniqueExchanges(spec topology.Spec) []topology.ExchangeSpec {
+	var exchanges []topology.ExchangeSpec
+
+	seen := make(map[string]bool)
+
+	for _, exchange := range spec.Exchanges {
+		if seen[exchange.Name] || exchange.Name == "" || strings.HasPrefix(exchange.Name, "amq.") {
+			continue
+		}
+
+		seen[exchange.Name] = true
+		exchanges = append(exchanges, exchange)
+	}
+
+	return exchanges
+}
+
+// uniqueQueues returns the queues of the Spec, without duplicates.
+func uniqueQueues(spec topology.Spec) []topology.QueueSpec {
+	var queues []topology.QueueSpec
+
+	seen := make(map[string]bool)
+
+	for _, queue := range spec.Queues {
+		if seen[queue.Name] {
+			continue
+		}
+
+		seen[queue.Name] = true
+		queues = append(queues, queue)
+	}
+
+	return queues
+}
+
+func describeExchange(exchange topology.ExchangeSpec) string {
+	details := []string{exchange.Kind}
+
+	if exchange.Durable {
+		details = append(details, "durable")
+	}
+
+	if exchange.AutoDelete {
+		details = append(details, "auto-delete")
+	}
+
+	if exchange.Internal {
+		details = append(details, "internal")
+	}
+
+	details = append(details, describeArguments(exchange.Arguments)...)
+
+	return fmt.Sprintf("exchange %q (%s)", exchange.Name, strings.Join(details, ", "))
+}
+
+func describeQueue(queue topology.QueueSpec) string {
+	var details []string
+
+	if queue.Durable {
+		details = append(details, "durable")
+	}
+
+	if queue.AutoDelete {
+		details = append(details, "auto-delete")
+	}
+
+	if queue.Exclusive {
+		details = append(details, "exclusive")
+	}
+
+	details = append(details, describeArguments(queue.Arguments)...)
+
+	if len(details) == 0 {
+		return fmt.Sprintf("queue %q", queue.Name)
+	}
+
+	return fmt.Sprintf("queue %q (%s)", queue.Name, strings.Join(details, ", "))
+}
+
+func describeBinding(binding topology.BindingSpec) string {
+	return fmt.Sprintf("binding exchange %q -> %s %q (key %q)",
+		binding.Source, binding.DestinationType, binding.Destination, binding.RoutingKey)
+}
+
+// describeArguments returns the arguments as key=value pairs, sorted by key.
+func describeArguments(args amqp.Table) []string {
+	described := make([]string, 0, len(args))
+	for key, value := range args {
+		described = append(described, fmt.Sprintf("%s=%v", key, value))
+	}
+
+	sort.Strings(described)
+
+	return described
+}
@@ -0,0 +1,12 @@
+// Command carrot manages the AMQP topology of an application,
+// loaded from a topology file.
+//
+// Applications can build their own version of this command, sharing the
+// topology they declare: for more information, see the cli package.
+package main
+
+import "github.com/ar3s3ru/go-carrot/cli"
+
+func main() {
+	cli.Main()
+}
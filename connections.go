@@ -0,0 +1,302 @@
+package carrot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+
+	"github.com/ar3s3ru/go-carrot/handler"
+	"github.com/ar3s3ru/go-carrot/listener"
+
+	"github.com/streadway/amqp"
+)
+
+// Names of the connections used by the Runner, which can be specified
+// with WithConnection, or opened by the Dialer specified with WithDialer.
+const (
+	// TopologyConnection is used to declare the topology.
+	TopologyConnection = "topology"
+	// ConsumeConnection is used by the Listener, unless otherwise
+	// specified with UseConnection.
+	ConsumeConnection = "consume"
+	// PublishConnection is used by the Workers, such as outbox relays,
+	// and by publishers using Closer.Connection.
+	PublishConnection = "publish"
+)
+
+// ErrNoNamedConnections is returned by the Listeners wrapped with
+// UseConnection, when they're not started by a Runner.
+var ErrNoNamedConnections = errors.New("carrot: named connections are only available in the Runner")
+
+// Dialer opens a new AMQP connection, given its name, e.g. using it
+// as the "connection_name" client property to tell connections apart
+// in the AMQP broker management interface:
+//
+//	carrot.WithDialer(func(name string) (listener.Connection, error) {
+//		return amqp.DialConfig(url, amqp.Config{
+//			Properties: amqp.Table{"connection_name": "my-service." + name},
+//		})
+//	})
+type Dialer func(name string) (listener.Connection, error)
+
+// WithConnection specifies the connection to use for the provided name,
+// instead of the one passed to From.
+//
+// RabbitMQ recommends using separate connections for publishing and
+// consuming, so that publishers being throttled by the AMQP broker
+// flow control don't block consumers:
+//
+//	carrot.From(consumeConn,
+//		carrot.WithConnection(carrot.PublishConnection, publishConn),
+//	)
+//
+// Multiple calls of this option are supported.
+func WithConnection(name string, conn listener.Connection) Option {
+	return func(runner *Runner) {
+		if runner.connections == nil {
+			runner.connections = make(map[string]listener.Connection)
+		}
+
+		runner.connections[name] = conn
+	}
+}
+
+// WithDialer specifies the Dialer used to open a new connection for each name
+// not specified with WithConnection, the first time the connection is needed.
+//
+// When a Dialer is specified, the connection passed to From is not used,
+// and can be nil; if not nil, it's still closed by Closer.Close.
+func WithDialer(dialer Dialer) Option {
+	return func(runner *Runner) { runner.dialer = dialer }
+}
+
+// UseConnection makes the wrapped Listener use the named connection
+// of the Runner, and a new channel opened from it, instead of the connection
+// used by the Runner Listener. The channel is closed together with
+// the Listener.
+//
+// Use it to group consumers on separate connections:
+//
+//	carrot.WithListener(listener.Sink(
+//		consumer.Listen("orders"),
+//		carrot.UseConnection("payments", listener.Sink(
+//			consumer.Listen("payments.authorized"),
+//			consumer.Listen("payments.refunded"),
+//		)),
+//	))
+func UseConnection(name string, l listener.Listener) listener.Listener {
+	if l == nil {
+		return nil
+	}
+
+	return connected{name: name, listener: l}
+}
+
+type connected struct {
+	name     string
+	listener listener.Listener
+}
+
+// Listeners returns the wrapped Listener.
+func (c connected) Listeners() []listener.Listener { return []listener.Listener{c.listener} }
+
+func (c connected) Listen(conn listener.Connection, _ listener.Channel, h handler.Handler) (listener.Closer, error) {
+	runnerConn, ok := conn.(runnerConnection)
+	if !ok {
+		return nil, ErrNoNamedConnections
+	}
+
+	named, err := runnerConn.connections.get(c.name)
+	if err != nil {
+		return nil, fmt.Errorf("carrot.UseConnection: failed to get connection, %w", err)
+	}
+
+	ch, err := named.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("carrot.UseConnection: failed to open new channel, %w", err)
+	}
+
+	closer, err := c.listener.Listen(runnerConnection{Connection: named, connections: runnerConn.connections}, ch, h)
+	if err != nil {
+		// nolint:errcheck
+		ch.Close()
+		return nil, err
+	}
+
+	// Connections might return no channel, e.g. mocks.Connection.
+	if ch == nil {
+		return closer, nil
+	}
+
+	return channelCloser{Closer: closer, ch: ch}, nil
+}
+
+// channelCloser closes the channel opened by UseConnection, after closing
+// the wrapped Closer.
+type channelCloser struct {
+	listener.Closer
+	ch io.Closer
+}
+
+func (c channelCloser) Close(ctx context.Context) error {
+	err := c.Closer.Close(ctx)
+
+	// The wrapped Listener might have closed the channel already.
+	if chErr := c.ch.Close(); chErr != nil && !errors.Is(chErr, amqp.ErrClosed) && err == nil {
+		err = fmt.Errorf("carrot.UseConnection: failed to close channel, %w", chErr)
+	}
+
+	return err
+}
+
+// Status returns the status of the wrapped Closer, if it's a listener.Reporter.
+func (c channelCloser) Status() []listener.Status {
+	if reporter, ok := c.Closer.(listener.Reporter); ok {
+		return reporter.Status()
+	}
+
+	return nil
+}
+
+// Pause pauses the wrapped Closer, if it's a listener.Pauser.
+func (c channelCloser) Pause(ctx context.Context) error {
+	if pauser, ok := c.Closer.(listener.Pauser); ok {
+		return pauser.Pause(ctx)
+	}
+
+	return listener.ErrNotPausable
+}
+
+// Resume resumes the wrapped Closer, if it's a listener.Pauser.
+func (c channelCloser) Resume(ctx context.Context) error {
+	if pauser, ok := c.Closer.(listener.Pauser); ok {
+		return pauser.Resume(ctx)
+	}
+
+	return listener.ErrNotPausable
+}
+
+// runnerConnection is the connection passed to the Runner Listener,
+// which gives access to the other named connections of the Runner.
+type runnerConnection struct {
+	listener.Connection
+	connections *connections
+}
+
+// connections keeps track of the connections used by the Runner,
+// dialing them when first needed.
+type connections struct {
+	fallback listener.Connection
+	named    map[string]listener.Connection
+	dialer   Dialer
+
+	mx     sync.Mutex
+	dialed map[string]listener.Connection
+}
+
+func (c *connections) get(name string) (listener.Connection, error) {
+	if conn, ok := c.named[name]; ok {
+		return conn, nil
+	}
+
+	if c.dialer == nil {
+		if c.fallback == nil {
+			return nil, ErrNoConnection
+		}
+
+		return c.fallback, nil
+	}
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if conn, ok := c.dialed[name]; ok {
+		return conn, nil
+	}
+
+	conn, err := c.dialer(name)
+	if err != nil {
+		return nil, fmt.Errorf("carrot: failed to dial %q connection, %w", name, err)
+	}
+
+	if c.dialed == nil {
+		c.dialed = make(map[string]listener.Connection)
+	}
+
+	c.dialed[name] = conn
+
+	return conn, nil
+}
+
+// closeDialed closes only the connections opened by the Dialer, which
+// are not accessible to the user of the library.
+func (c *connections) closeDialed() error {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	return closeConnections(c.dialed)
+}
+
+// close closes all the connections, each one only once, even if used
+// with multiple names.
+func (c *connections) close() error {
+	all := make(map[string]listener.Connection, len(c.named)+1)
+
+	for name, conn := range c.named {
+		all[name] = conn
+	}
+
+	if c.fallback != nil {
+		all[""] = c.fallback
+	}
+
+	err := closeConnections(all)
+
+	if dialedErr := c.closeDialed(); err == nil {
+		err = dialedErr
+	}
+
+	return err
+}
+
+func closeConnections(conns map[string]listener.Connection) error {
+	var (
+		err    error
+		closed []listener.Connection
+	)
+
+	for _, conn := range conns {
+		if conn == nil || containsConnection(closed, conn) {
+			continue
+		}
+
+		closed = append(closed, conn)
+
+		if closeErr := conn.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+
+	return err
+}
+
+// containsConnection reports whether conn is in conns, comparing pointers
+// only: other connection types might not be comparable, and are never
+// considered the same.
+func containsConnection(conns []listener.Connection, conn listener.Connection) bool {
+	v := reflect.ValueOf(conn)
+	if v.Kind() != reflect.Ptr {
+		return false
+	}
+
+	for _, c := range conns {
+		if other := reflect.ValueOf(c); other.Type() == v.Type() && other.Pointer() == v.Pointer() {
+			return true
+		}
+	}
+
+	return false
+}
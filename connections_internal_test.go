@@ -0,0 +1,91 @@
+package carrot
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ar3s3ru/go-carrot/listener"
+	"github.com/ar3s3ru/go-carrot/listener/mocks"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+// funcConnection is a connection type that is not comparable.
+type funcConnection struct {
+	close func() error
+}
+
+func (conn funcConnection) Channel() (*amqp.Channel, error) { return nil, nil }
+func (conn funcConnection) Close() error                    { return conn.close() }
+
+func TestCloseConnections(t *testing.T) {
+	var closed int
+
+	conn := funcConnection{close: func() error { closed++; return nil }}
+	shared := new(mocks.Connection)
+	shared.On("Close").Return(nil).Once()
+
+	assert.NotPanics(t, func() {
+		assert.NoError(t, closeConnections(map[string]listener.Connection{
+			"consume":  conn,
+			"publish":  conn,
+			"topology": shared,
+			"audit":    shared,
+		}))
+	})
+
+	// Connections that are not pointers can't be told apart.
+	assert.Equal(t, 2, closed)
+	shared.AssertExpectations(t)
+}
+
+type channel struct {
+	closed int
+	err    error
+}
+
+func (ch *channel) Close() error {
+	ch.closed++
+	return ch.err
+}
+
+func TestChannelCloser(t *testing.T) {
+	t.Run("closes the channel after the listener", func(t *testing.T) {
+		ch := new(channel)
+
+		closer := new(mocks.Closer)
+		closer.On("Close", context.Background()).Return(nil).Once()
+
+		assert.NoError(t, channelCloser{Closer: closer, ch: ch}.Close(context.Background()))
+		assert.Equal(t, 1, ch.closed)
+		closer.AssertExpectations(t)
+	})
+
+	t.Run("ignores channels closed by the listener", func(t *testing.T) {
+		ch := &channel{err: amqp.ErrClosed}
+
+		closer := new(mocks.Closer)
+		closer.On("Close", context.Background()).Return(nil).Once()
+
+		assert.NoError(t, channelCloser{Closer: closer, ch: ch}.Close(context.Background()))
+	})
+
+	t.Run("reports the listener error first", func(t *testing.T) {
+		listenerErr := errors.New("listener error")
+		ch := &channel{err: errors.New("channel error")}
+
+		closer := new(mocks.Closer)
+		closer.On("Close", context.Background()).Return(listenerErr).Once()
+
+		assert.Equal(t, listenerErr, channelCloser{Closer: closer, ch: ch}.Close(context.Background()))
+		assert.Equal(t, 1, ch.closed)
+	})
+
+	t.Run("can't pause listeners that can't be paused", func(t *testing.T) {
+		c := channelCloser{Closer: new(mocks.Closer), ch: new(channel)}
+		assert.Equal(t, listener.ErrNotPausable, c.Pause(context.Background()))
+		assert.Equal(t, listener.ErrNotPausable, c.Resume(context.Background()))
+	})
+}
@@ -0,0 +1,173 @@
+package carrot_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ar3s3ru/go-carrot"
+	"github.com/ar3s3ru/go-carrot/handler"
+	"github.com/ar3s3ru/go-carrot/listener"
+	"github.com/ar3s3ru/go-carrot/listener/mocks"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newConnection() *mocks.Connection {
+	conn := new(mocks.Connection)
+	conn.On("Channel").Return(nil, nil).Maybe()
+	conn.On("Close").Return(nil).Once()
+
+	return conn
+}
+
+// recordingListener records the connection it has been started with.
+func recordingListener(conns *[]listener.Connection) listener.Listener {
+	return listener.Func(func(conn listener.Connection, _ listener.Channel, _ handler.Handler) (listener.Closer, error) {
+		*conns = append(*conns, conn)
+
+		closer := new(mocks.Closer)
+		closer.On("Close", context.Background()).Return(nil)
+
+		return closer, nil
+	})
+}
+
+type worker struct {
+	conn listener.Connection
+}
+
+func (w *worker) Start(conn listener.Connection) (listener.Closer, error) {
+	w.conn = conn
+
+	closer := new(mocks.Closer)
+	closer.On("Close", context.Background()).Return(nil)
+
+	return closer, nil
+}
+
+var noop = handler.Func(func(context.Context, amqp.Delivery) error { return nil })
+
+func TestRun_WithDialer(t *testing.T) {
+	dialed := make(map[string]*mocks.Connection)
+
+	dialer := func(name string) (listener.Connection, error) {
+		conn := newConnection()
+		dialed[name] = conn
+
+		return conn, nil
+	}
+
+	var (
+		consumers []listener.Connection
+		payments  []listener.Connection
+		w         = new(worker)
+	)
+
+	closer, err := carrot.Run(nil,
+		carrot.WithDialer(dialer),
+		carrot.WithWorker(w),
+		carrot.WithHandler(noop),
+		carrot.WithListener(listener.Func(
+			func(conn listener.Connection, ch listener.Channel, h handler.Handler) (listener.Closer, error) {
+				if _, err := recordingListener(&consumers).Listen(conn, ch, h); err != nil {
+					return nil, err
+				}
+
+				return carrot.UseConnection("payments", recordingListener(&payments)).Listen(conn, ch, h)
+			},
+		)),
+	)
+	require.NoError(t, err)
+
+	assert.Len(t, dialed, 3)
+	assert.Equal(t, dialed[carrot.PublishConnection], w.conn)
+
+	require.Len(t, consumers, 1)
+	consumers[0].Channel() // nolint:errcheck
+	dialed[carrot.ConsumeConnection].AssertNumberOfCalls(t, "Channel", 2)
+
+	require.Len(t, payments, 1)
+	payments[0].Channel() // nolint:errcheck
+	dialed["payments"].AssertNumberOfCalls(t, "Channel", 2)
+
+	publish, err := closer.Connection(carrot.PublishConnection)
+	require.NoError(t, err)
+	assert.Equal(t, dialed[carrot.PublishConnection], publish)
+
+	// Connections are dialed once, when first needed.
+	_, err = closer.Connection("audit")
+	require.NoError(t, err)
+	assert.Len(t, dialed, 4)
+
+	assert.NoError(t, closer.Close(context.Background()))
+
+	for _, conn := range dialed {
+		conn.AssertExpectations(t)
+	}
+}
+
+func TestRun_WithConnection(t *testing.T) {
+	conn := newConnection()
+	publish := newConnection()
+
+	w := new(worker)
+
+	closer, err := carrot.Run(conn,
+		carrot.WithConnection(carrot.PublishConnection, publish),
+		carrot.WithConnection(carrot.TopologyConnection, publish),
+		carrot.WithWorker(w),
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, publish, w.conn)
+
+	// Connections are closed once, even if used with multiple names.
+	assert.NoError(t, closer.Close(context.Background()))
+	conn.AssertExpectations(t)
+	publish.AssertExpectations(t)
+}
+
+func TestRun_DialerFailure(t *testing.T) {
+	var dialed []*mocks.Connection
+
+	dialer := func(name string) (listener.Connection, error) {
+		if name == carrot.ConsumeConnection {
+			return nil, errors.New("connection refused")
+		}
+
+		conn := newConnection()
+		dialed = append(dialed, conn)
+
+		return conn, nil
+	}
+
+	_, err := carrot.Run(nil,
+		carrot.WithDialer(dialer),
+		carrot.WithWorker(new(worker)),
+		carrot.WithHandler(noop),
+		carrot.WithListener(carrot.UseConnection("payments", listener.Func(
+			func(listener.Connection, listener.Channel, handler.Handler) (listener.Closer, error) {
+				return nil, nil
+			},
+		))),
+	)
+	assert.Error(t, err)
+
+	// Connections opened by the Dialer are closed when the Runner fails.
+	require.Len(t, dialed, 1)
+	dialed[0].AssertExpectations(t)
+}
+
+func TestUseConnection_OutsideRunner(t *testing.T) {
+	l := carrot.UseConnection("payments", listener.Func(
+		func(listener.Connection, listener.Channel, handler.Handler) (listener.Closer, error) {
+			return nil, nil
+		},
+	))
+
+	_, err := l.Listen(new(mocks.Connection), nil, noop)
+	assert.True(t, errors.Is(err, carrot.ErrNoNamedConnections))
+}
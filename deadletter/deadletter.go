@@ -0,0 +1,170 @@
+// Package deadletter helps reprocessing dead-lettered messages, such as
+// the ones in the dead-letter queues declared with queue.DeadLetterWithQueue.
+//
+// The AMQP broker records the history of a dead-lettered message in its
+// "x-death" header, which can be parsed with Parse and Last; the Republish
+// and Compensate handlers use it to publish messages back to their origin,
+// or to hand them to a compensating action.
+//
+// For more information about dead-lettering,
+// please visit https://www.rabbitmq.com/dlx.html.
+package deadletter
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// Header is the header containing the dead-lettering history of a message.
+const Header = "x-death"
+
+// Reason is the reason a message has been dead-lettered for.
+type Reason string
+
+// Supported dead-lettering reasons.
+const (
+	// Rejected is used for messages rejected or negatively acknowledged
+	// without being requeued.
+	Rejected Reason = "rejected"
+	// Expired is used for messages expired because of their TTL.
+	Expired Reason = "expired"
+	// MaxLength is used for messages dropped because the queue
+	// exceeded its maximum length.
+	MaxLength Reason = "maxlen"
+	// DeliveryLimit is used for messages requeued more times than
+	// the delivery limit of a quorum queue.
+	DeliveryLimit Reason = "delivery_limit"
+)
+
+// ErrNotDeadLettered is returned when a message has no dead-lettering history.
+var ErrNotDeadLettered = errors.New("deadletter: message has not been dead-lettered")
+
+// Death is an entry of the dead-lettering history of a message, describing
+// the queue the message has been dead-lettered from.
+type Death struct {
+	Reason Reason
+
+	// Queue is the queue the message has been dead-lettered from.
+	Queue string
+
+	// Exchange and RoutingKeys are the exchange and routing keys the message
+	// had been published with, including the CC and BCC ones.
+	Exchange    string
+	RoutingKeys []string
+
+	// Count is the number of times the message has been dead-lettered
+	// from the queue, for the same reason.
+	Count int64
+
+	// Time is when the message has been dead-lettered for the first time
+	// from the queue, for the same reason.
+	Time time.Time
+
+	// OriginalExpiration is the expiration property of the message,
+	// removed when dead-lettered.
+	OriginalExpiration string
+}
+
+// RoutingKey returns the routing key the message had been published with.
+func (death Death) RoutingKey() string {
+	if len(death.RoutingKeys) == 0 {
+		return ""
+	}
+
+	return death.RoutingKeys[0]
+}
+
+// Parse parses the dead-lettering history in the headers, from the most
+// recent entry to the oldest one.
+//
+// Returns ErrNotDeadLettered if the headers contain no history.
+func Parse(headers amqp.Table) ([]Death, error) {
+	value, ok := headers[Header]
+	if !ok {
+		return nil, ErrNotDeadLettered
+	}
+
+	entries, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("deadletter.Parse: invalid %s header, %T", Header, value)
+	}
+
+	if len(entries) == 0 {
+		return nil, ErrNotDeadLettered
+	}
+
+	deaths := make([]Death, 0, len(entries))
+
+	for i, entry := range entries {
+		death, err := parseDeath(entry)
+		if err != nil {
+			return nil, fmt.Errorf("deadletter.Parse: invalid entry %d, %w", i, err)
+		}
+
+		deaths = append(deaths, death)
+	}
+
+	return deaths, nil
+}
+
+// Last returns the most recent entry of the dead-lettering history
+// in the headers, if any.
+func Last(headers amqp.Table) (Death, bool) {
+	deaths, err := Parse(headers)
+	if err != nil {
+		return Death{}, false
+	}
+
+	return deaths[0], true
+}
+
+func parseDeath(entry interface{}) (Death, error) {
+	table, ok := entry.(amqp.Table)
+	if !ok {
+		return Death{}, fmt.Errorf("unexpected type %T", entry)
+	}
+
+	var death Death
+
+	reason, _ := table["reason"].(string)
+	death.Reason = Reason(reason)
+	death.Queue, _ = table["queue"].(string)
+	death.Exchange, _ = table["exchange"].(string)
+	death.OriginalExpiration, _ = table["original-expiration"].(string)
+
+	if keys, ok := table["routing-keys"].([]interface{}); ok {
+		for _, key := range keys {
+			if key, ok := key.(string); ok {
+				death.RoutingKeys = append(death.RoutingKeys, key)
+			}
+		}
+	}
+
+	switch count := table["count"].(type) {
+	case int64:
+		death.Count = count
+	case int32:
+		death.Count = int64(count)
+	case int:
+		death.Count = int64(count)
+	}
+
+	// Timestamps are strings when the headers have been encoded as JSON,
+	// such as the ones dumped by the carrot command line tool.
+	switch t := table["time"].(type) {
+	case time.Time:
+		death.Time = t
+	case string:
+		parsed, err := time.Parse(time.RFC3339, t)
+		if err != nil {
+			return Death{}, fmt.Errorf("invalid time, %w", err)
+		}
+
+		death.Time = parsed
+	}
+
+	return death, nil
+}
@@ -0,0 +1,218 @@
+package deadletter_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ar3s3ru/go-carrot/deadletter"
+	"github.com/ar3s3ru/go-carrot/handler"
+	"github.com/ar3s3ru/go-carrot/listener"
+	"github.com/ar3s3ru/go-carrot/listener/mocks"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var deathTime = time.Date(2021, time.March, 1, 10, 0, 0, 0, time.UTC)
+
+func deadLettered(reason string, count int64) amqp.Delivery {
+	return amqp.Delivery{
+		Exchange:   "orders.dlx",
+		RoutingKey: "dead",
+		MessageId:  "1",
+		Headers: amqp.Table{
+			"x-death": []interface{}{
+				amqp.Table{
+					"count":        count,
+					"reason":       reason,
+					"queue":        "orders.created",
+					"exchange":     "orders",
+					"routing-keys": []interface{}{"order.created", "audit"},
+					"time":         deathTime,
+				},
+				amqp.Table{
+					"count":               int32(1),
+					"reason":              "expired",
+					"queue":               "orders.delayed",
+					"exchange":            "",
+					"routing-keys":        []interface{}{"orders.delayed"},
+					"time":                deathTime.Add(-time.Hour).Format(time.RFC3339),
+					"original-expiration": "60000",
+				},
+			},
+		},
+	}
+}
+
+func TestParse(t *testing.T) {
+	testcases := []struct {
+		name     string
+		headers  amqp.Table
+		expected []deadletter.Death
+		err      error
+	}{
+		{
+			name: "no headers",
+			err:  deadletter.ErrNotDeadLettered,
+		},
+		{
+			name:    "empty history",
+			headers: amqp.Table{"x-death": []interface{}{}},
+			err:     deadletter.ErrNotDeadLettered,
+		},
+		{
+			name:    "dead-lettering history",
+			headers: deadLettered("rejected", 2).Headers,
+			expected: []deadletter.Death{
+				{
+					Reason:      deadletter.Rejected,
+					Queue:       "orders.created",
+					Exchange:    "orders",
+					RoutingKeys: []string{"order.created", "audit"},
+					Count:       2,
+					Time:        deathTime,
+				},
+				{
+					Reason:             deadletter.Expired,
+					Queue:              "orders.delayed",
+					RoutingKeys:        []string{"orders.delayed"},
+					Count:              1,
+					Time:               deathTime.Add(-time.Hour),
+					OriginalExpiration: "60000",
+				},
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			deaths, err := deadletter.Parse(tc.headers)
+			assert.True(t, errors.Is(err, tc.err))
+			assert.Equal(t, tc.expected, deaths)
+		})
+	}
+
+	t.Run("invalid header", func(t *testing.T) {
+		_, err := deadletter.Parse(amqp.Table{"x-death": "invalid"})
+		assert.Error(t, err)
+	})
+}
+
+type publisher struct {
+	exchange, key string
+	msg           amqp.Publishing
+	err           error
+}
+
+func (p *publisher) Publish(_ context.Context, exchange, key string, msg amqp.Publishing) error {
+	p.exchange, p.key, p.msg = exchange, key, msg
+	return p.err
+}
+
+func TestRepublish(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("republishes messages to their origin", func(t *testing.T) {
+		p := new(publisher)
+		delivery := deadLettered("rejected", 1)
+
+		require.NoError(t, deadletter.Republish(p).Handle(ctx, delivery))
+		assert.Equal(t, "orders", p.exchange)
+		assert.Equal(t, "order.created", p.key)
+		assert.Equal(t, "1", p.msg.MessageId)
+		assert.Equal(t, delivery.Headers["x-death"], p.msg.Headers["x-death"])
+	})
+
+	t.Run("requeues messages if publishing fails", func(t *testing.T) {
+		p := &publisher{err: errors.New("failed")}
+
+		err := deadletter.Republish(p).Handle(ctx, deadLettered("rejected", 1))
+		assert.Error(t, err)
+		assert.False(t, handler.IsRejected(err))
+	})
+
+	t.Run("rejects messages not dead-lettered", func(t *testing.T) {
+		err := deadletter.Republish(new(publisher)).Handle(ctx, amqp.Delivery{})
+		assert.True(t, handler.IsRejected(err))
+		assert.True(t, errors.Is(err, deadletter.ErrNotDeadLettered))
+	})
+
+	t.Run("requeues messages over the retries limit", func(t *testing.T) {
+		p := new(publisher)
+		h := deadletter.Republish(p, deadletter.MaxRetries(3))
+
+		assert.NoError(t, h.Handle(ctx, deadLettered("rejected", 3)))
+
+		err := h.Handle(ctx, deadLettered("rejected", 4))
+		assert.False(t, handler.IsRejected(err))
+		assert.True(t, errors.Is(err, deadletter.ErrNotRepublished))
+	})
+
+	t.Run("hands filtered messages to the otherwise handler", func(t *testing.T) {
+		var compensated []deadletter.Death
+
+		p := new(publisher)
+		h := deadletter.Republish(p,
+			deadletter.Reasons(deadletter.Rejected, deadletter.DeliveryLimit),
+			deadletter.Filter(func(_ amqp.Delivery, death deadletter.Death) bool {
+				return death.Queue == "orders.created"
+			}),
+			deadletter.Otherwise(deadletter.Compensate(
+				func(_ context.Context, _ amqp.Delivery, death deadletter.Death) error {
+					compensated = append(compensated, death)
+					return nil
+				},
+			)),
+		)
+
+		assert.NoError(t, h.Handle(ctx, deadLettered("expired", 1)))
+		assert.NoError(t, h.Handle(ctx, deadLettered("rejected", 1)))
+
+		require.Len(t, compensated, 1)
+		assert.Equal(t, deadletter.Expired, compensated[0].Reason)
+		assert.Equal(t, "orders", p.exchange)
+	})
+}
+
+func TestListen(t *testing.T) {
+	handled := make(chan amqp.Delivery, 1)
+
+	h := handler.Func(func(_ context.Context, delivery amqp.Delivery) error {
+		handled <- delivery
+		return nil
+	})
+
+	deliveries := make(chan amqp.Delivery, 1)
+	deliveries <- amqp.Delivery{MessageId: "1", Acknowledger: new(acknowledger)}
+
+	ch := new(mocks.Channel)
+	ch.On("Consume", "orders.dlq", "orders.dlq", false, false, false, false, amqp.Table(nil)).
+		Return((<-chan amqp.Delivery)(deliveries), nil).
+		Once()
+
+	l := deadletter.Listen("orders.dlq", h)
+
+	// The handler of the Runner is ignored.
+	closer, err := l.Listen(nil, ch, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, "1", (<-handled).MessageId)
+
+	ch.On("Close").Return(nil).Once()
+	close(deliveries)
+	assert.NoError(t, closer.Close(context.Background()))
+
+	var listeners []listener.Listener
+	listener.Walk(l, func(l listener.Listener) { listeners = append(listeners, l) })
+	assert.Len(t, listeners, 2)
+}
+
+type acknowledger struct{}
+
+func (acknowledger) Ack(uint64, bool) error        { return nil }
+func (acknowledger) Nack(uint64, bool, bool) error { return nil }
+func (acknowledger) Reject(uint64, bool) error     { return nil }
@@ -0,0 +1,180 @@
+package deadletter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ar3s3ru/go-carrot/handler"
+	"github.com/ar3s3ru/go-carrot/internal/republish"
+	"github.com/ar3s3ru/go-carrot/listener"
+	"github.com/ar3s3ru/go-carrot/listener/consumer"
+	"github.com/ar3s3ru/go-carrot/publisher"
+
+	"github.com/streadway/amqp"
+)
+
+// ErrNotRepublished is returned by the Republish handler for messages
+// not republished because of the limits or filters specified,
+// when no Otherwise handler has been specified, so that they're
+// negatively acknowledged and requeued.
+var ErrNotRepublished = errors.New("deadletter.Republish: message not republished")
+
+// Publisher is the interface used to publish messages again,
+// such as publisher.Publisher or channelpool.Pool.
+type Publisher = publisher.Interface
+
+type republisher struct {
+	publisher  Publisher
+	maxRetries int64
+	reasons    map[Reason]bool
+	filters    []func(amqp.Delivery, Death) bool
+	otherwise  handler.Handler
+}
+
+// Republish returns a handler.Handler publishing dead-lettered messages back to
+// the exchange and routing key they had been published with, keeping their
+// properties and headers, so that the dead-lettering history is preserved.
+//
+// Messages excluded by the limits and filters specified with the options
+// are handed to the Otherwise handler, if specified, or requeued otherwise,
+// so that they're not lost: since they'd be delivered again right away,
+// specify an Otherwise handler, such as the one returned by Compensate,
+// when using any limit or filter. Messages not dead-lettered are handed
+// to the Otherwise handler too, if specified, or rejected without being
+// requeued, since they can't be republished.
+//
+// Messages are acknowledged once republished, so the Republish handler
+// should be used with a dead-letter queue consumer, such as the one
+// returned by Listen.
+func Republish(publisher Publisher, options ...RepublishOption) handler.Handler {
+	r := republisher{publisher: publisher}
+
+	for _, option := range options {
+		if option == nil {
+			continue
+		}
+
+		option(&r)
+	}
+
+	return r
+}
+
+func (r republisher) Handle(ctx context.Context, delivery amqp.Delivery) error {
+	death, ok := Last(delivery.Headers)
+	if !ok || !r.republish(delivery, death) {
+		if r.otherwise != nil {
+			return r.otherwise.Handle(ctx, delivery)
+		}
+
+		if !ok {
+			return handler.Reject(ErrNotDeadLettered)
+		}
+
+		return ErrNotRepublished
+	}
+
+	err := r.publisher.Publish(ctx, death.Exchange, death.RoutingKey(), republish.Publishing(delivery))
+	if err != nil {
+		return fmt.Errorf("deadletter.Republish: failed to republish message, %w", err)
+	}
+
+	return nil
+}
+
+func (r republisher) republish(delivery amqp.Delivery, death Death) bool {
+	if r.maxRetries > 0 && death.Count > r.maxRetries {
+		return false
+	}
+
+	if len(r.reasons) > 0 && !r.reasons[death.Reason] {
+		return false
+	}
+
+	for _, filter := range r.filters {
+		if !filter(delivery, death) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// RepublishOption is an optional functionality that can be added to
+// the handler returned by Republish.
+type RepublishOption func(*republisher)
+
+// MaxRetries limits the number of times a message is republished,
+// using the number of times it has been dead-lettered from the same queue.
+func MaxRetries(retries int64) RepublishOption {
+	return func(r *republisher) { r.maxRetries = retries }
+}
+
+// Reasons republishes only the messages dead-lettered for
+// one of the specified reasons.
+func Reasons(reasons ...Reason) RepublishOption {
+	return func(r *republisher) {
+		if r.reasons == nil {
+			r.reasons = make(map[Reason]bool)
+		}
+
+		for _, reason := range reasons {
+			r.reasons[reason] = true
+		}
+	}
+}
+
+// Filter republishes only the messages the function returns true for.
+// Multiple calls of this option are supported.
+func Filter(filter func(amqp.Delivery, Death) bool) RepublishOption {
+	return func(r *republisher) { r.filters = append(r.filters, filter) }
+}
+
+// Otherwise specifies the handler.Handler of the messages not republished,
+// such as the one returned by Compensate.
+//
+// If not specified, messages not republished are requeued.
+func Otherwise(h handler.Handler) RepublishOption {
+	return func(r *republisher) { r.otherwise = h }
+}
+
+// CompensateFunc is a compensating action for a dead-lettered message.
+type CompensateFunc func(context.Context, amqp.Delivery, Death) error
+
+// Compensate returns a handler.Handler calling the compensating action
+// with the most recent entry of the dead-lettering history of the message.
+//
+// Messages not dead-lettered are rejected without being requeued.
+func Compensate(fn CompensateFunc) handler.Handler {
+	return handler.Func(func(ctx context.Context, delivery amqp.Delivery) error {
+		death, ok := Last(delivery.Headers)
+		if !ok {
+			return handler.Reject(ErrNotDeadLettered)
+		}
+
+		return fn(ctx, delivery, death)
+	})
+}
+
+// Listen returns a consumer.Listener consuming from the dead-letter queue,
+// handling messages with the provided handler.Handler, such as the ones
+// returned by Republish and Compensate, instead of the one of the Runner.
+func Listen(queue string, h handler.Handler, options ...consumer.Option) listener.Listener {
+	return dedicatedHandler{
+		listener: consumer.Listen(queue, options...),
+		handler:  h,
+	}
+}
+
+type dedicatedHandler struct {
+	listener listener.Listener
+	handler  handler.Handler
+}
+
+// Listeners returns the wrapped Listener.
+func (d dedicatedHandler) Listeners() []listener.Listener { return []listener.Listener{d.listener} }
+
+func (d dedicatedHandler) Listen(conn listener.Connection, ch listener.Channel, _ handler.Handler) (listener.Closer, error) {
+	return d.listener.Listen(conn, ch, d.handler)
+}
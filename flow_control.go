@@ -0,0 +1,196 @@
+package carrot
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ar3s3ru/go-carrot/blocking"
+	"github.com/ar3s3ru/go-carrot/listener"
+)
+
+// FlowControl contains the options used to handle the connection.blocked
+// notifications sent by the AMQP broker when it hits a resource alarm,
+// during which publishing on the blocked connection hangs.
+type FlowControl struct {
+	// PauseListener pauses the Listener while the connection is blocked,
+	// so that handlers publishing messages don't hang, and resumes it once
+	// the connection gets unblocked.
+	PauseListener bool
+	// OnBlocked is called when the connection gets blocked, with the reason
+	// reported by the AMQP broker.
+	OnBlocked func(reason string)
+	// OnUnblocked is called when the connection gets unblocked.
+	OnUnblocked func()
+	// OnError is called when the Listener can't be paused or resumed.
+	OnError func(error)
+}
+
+// WithFlowControl tracks whether the PublishConnection is blocked by
+// the AMQP broker, if the connection supports blocking notifications,
+// such as *amqp.Connection.
+//
+// The blocking.Tracker is available through Closer.Blocking, to have
+// publishers fail fast or wait while the connection is blocked, e.g. with
+// the publisher.FailWhenBlocked and publisher.WaitWhenBlocked options,
+// and is watched by the health.Monitor specified with WithHealth.
+//
+// Use WithFlowControl(nil) to only track the blocked state.
+func WithFlowControl(options *FlowControl) Option {
+	return func(runner *Runner) {
+		if options == nil {
+			options = new(FlowControl)
+		}
+
+		runner.flowControl = options
+	}
+}
+
+func (runner Runner) watchBlocking(conns *connections) (*flowController, error) {
+	if runner.flowControl == nil {
+		return nil, nil
+	}
+
+	conn, err := conns.get(PublishConnection)
+	if err != nil {
+		return nil, err
+	}
+
+	notifier, ok := conn.(blocking.Connection)
+	if !ok {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	fc := &flowController{
+		onError: runner.flowControl.OnError,
+		notify:  make(chan struct{}, 1),
+		ctx:     ctx,
+		stop:    cancel,
+	}
+
+	options := []blocking.Option{
+		blocking.OnBlocked(func(string) { fc.changed() }),
+		blocking.OnUnblocked(fc.changed),
+	}
+
+	if fn := runner.flowControl.OnBlocked; fn != nil {
+		options = append(options, blocking.OnBlocked(fn))
+	}
+
+	if fn := runner.flowControl.OnUnblocked; fn != nil {
+		options = append(options, blocking.OnUnblocked(fn))
+	}
+
+	fc.tracker = blocking.Watch(notifier, options...)
+
+	if runner.health != nil {
+		runner.health.WatchPublishing(fc.tracker)
+	}
+
+	if runner.flowControl.PauseListener {
+		go fc.run()
+	}
+
+	return fc, nil
+}
+
+// flowController pauses and resumes the Listener of the Runner, following
+// the blocked state of the connection.
+type flowController struct {
+	tracker *blocking.Tracker
+	onError func(error)
+
+	notify chan struct{}
+	// ctx is cancelled when the controller is stopped, interrupting
+	// pending Pause and Resume calls.
+	ctx  context.Context
+	stop context.CancelFunc
+
+	mx     sync.Mutex
+	pauser listener.Pauser
+	// paused is only used by the controller goroutine.
+	paused bool
+}
+
+// listen sets the Listener to pause, as soon as it has been started.
+func (fc *flowController) listen(closer listener.Closer) {
+	pauser, ok := closer.(listener.Pauser)
+	if !ok {
+		fc.error(listener.ErrNotPausable)
+		return
+	}
+
+	fc.mx.Lock()
+	fc.pauser = pauser
+	fc.mx.Unlock()
+
+	// The connection might have been blocked before the Listener started.
+	fc.changed()
+}
+
+// changed notifies the controller goroutine of a change of the blocked
+// state, without blocking: pending notifications are coalesced.
+func (fc *flowController) changed() {
+	select {
+	case fc.notify <- struct{}{}:
+	default:
+	}
+}
+
+// run pauses and resumes the Listener sequentially, so that quick
+// blocked and unblocked notifications can't be applied out of order.
+func (fc *flowController) run() {
+	for {
+		select {
+		case <-fc.ctx.Done():
+			return
+		case <-fc.notify:
+			fc.apply()
+		}
+	}
+}
+
+// apply pauses or resumes the Listener, if its state doesn't match
+// the blocked state of the connection.
+//
+// The lock is not held while pausing or resuming, which might take a while.
+func (fc *flowController) apply() {
+	fc.mx.Lock()
+	pauser := fc.pauser
+	fc.mx.Unlock()
+
+	blocked := fc.tracker.Blocked()
+	if pauser == nil || blocked == fc.paused {
+		return
+	}
+
+	pauseOrResume := pauser.Resume
+	if blocked {
+		pauseOrResume = pauser.Pause
+	}
+
+	if err := pauseOrResume(fc.ctx); err != nil {
+		if fc.ctx.Err() == nil {
+			fc.error(err)
+		}
+
+		return
+	}
+
+	fc.paused = blocked
+}
+
+func (fc *flowController) error(err error) {
+	if fc.onError != nil {
+		fc.onError(err)
+	}
+}
+
+func (fc *flowController) close() {
+	if fc == nil {
+		return
+	}
+
+	fc.stop()
+}
@@ -0,0 +1,154 @@
+package carrot_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ar3s3ru/go-carrot"
+	"github.com/ar3s3ru/go-carrot/handler"
+	"github.com/ar3s3ru/go-carrot/listener"
+	"github.com/ar3s3ru/go-carrot/listener/mocks"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingConnection is a connection sending blocking notifications.
+type blockingConnection struct {
+	*mocks.Connection
+	blocking chan amqp.Blocking
+}
+
+func (conn *blockingConnection) NotifyBlocked(ch chan amqp.Blocking) chan amqp.Blocking {
+	conn.blocking = ch
+	return ch
+}
+
+// pausableCloser records the Pause and Resume calls, in order.
+type pausableCloser struct {
+	mx    sync.Mutex
+	calls []string
+	// hang makes Pause wait until its context is done.
+	hang chan error
+}
+
+func (c *pausableCloser) record(call string) error {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	c.calls = append(c.calls, call)
+
+	return nil
+}
+
+func (c *pausableCloser) Calls() []string {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	return append([]string(nil), c.calls...)
+}
+
+func (c *pausableCloser) Pause(ctx context.Context) error {
+	// nolint:errcheck
+	c.record("pause")
+
+	if c.hang == nil {
+		return nil
+	}
+
+	<-ctx.Done()
+	c.hang <- ctx.Err()
+
+	return ctx.Err()
+}
+
+func (c *pausableCloser) Resume(context.Context) error { return c.record("resume") }
+func (c *pausableCloser) Close(context.Context) error  { return nil }
+func (c *pausableCloser) Closed() <-chan error         { return nil }
+
+func TestRun_WithFlowControl(t *testing.T) {
+	conn := &blockingConnection{Connection: newConnection()}
+	closer := new(pausableCloser)
+
+	blocked := make(chan string, 1)
+
+	runnerCloser, err := carrot.Run(conn,
+		carrot.WithHandler(noop),
+		carrot.WithListener(listener.Func(func(listener.Connection, listener.Channel, handler.Handler) (listener.Closer, error) {
+			return closer, nil
+		})),
+		carrot.WithFlowControl(&carrot.FlowControl{
+			PauseListener: true,
+			OnBlocked:     func(reason string) { blocked <- reason },
+		}),
+	)
+	require.NoError(t, err)
+
+	tracker := runnerCloser.Blocking()
+	require.NotNil(t, tracker)
+
+	conn.blocking <- amqp.Blocking{Active: true, Reason: "low on memory"}
+	assert.Equal(t, "low on memory", <-blocked)
+
+	assert.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual([]string{"pause"}, closer.Calls())
+	}, time.Second, time.Millisecond)
+
+	conn.blocking <- amqp.Blocking{Active: false}
+
+	assert.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual([]string{"pause", "resume"}, closer.Calls())
+	}, time.Second, time.Millisecond)
+
+	assert.False(t, tracker.Blocked())
+	assert.NoError(t, runnerCloser.Close(context.Background()))
+	conn.AssertExpectations(t)
+}
+
+func TestRun_WithFlowControl_Close(t *testing.T) {
+	conn := &blockingConnection{Connection: newConnection()}
+	closer := &pausableCloser{hang: make(chan error, 1)}
+
+	runnerCloser, err := carrot.Run(conn,
+		carrot.WithHandler(noop),
+		carrot.WithListener(listener.Func(func(listener.Connection, listener.Channel, handler.Handler) (listener.Closer, error) {
+			return closer, nil
+		})),
+		carrot.WithFlowControl(&carrot.FlowControl{
+			PauseListener: true,
+			OnError:       func(err error) { assert.Fail(t, "unexpected error", err) },
+		}),
+	)
+	require.NoError(t, err)
+
+	conn.blocking <- amqp.Blocking{Active: true, Reason: "low on memory"}
+
+	assert.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual([]string{"pause"}, closer.Calls())
+	}, time.Second, time.Millisecond)
+
+	// Closing the Runner interrupts the pending Pause call.
+	assert.NoError(t, runnerCloser.Close(context.Background()))
+
+	select {
+	case err := <-closer.hang:
+		assert.Equal(t, context.Canceled, err)
+	case <-time.After(time.Second):
+		require.Fail(t, "pause should be interrupted when closing")
+	}
+}
+
+func TestRun_WithFlowControl_Unsupported(t *testing.T) {
+	closer, err := carrot.Run(newConnection(),
+		carrot.WithWorker(new(worker)),
+		carrot.WithFlowControl(nil),
+	)
+	require.NoError(t, err)
+
+	// mocks.Connection doesn't support blocking notifications.
+	assert.Nil(t, closer.Blocking())
+	assert.NoError(t, closer.Close(context.Background()))
+}
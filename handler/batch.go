@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/streadway/amqp"
+)
+
+// BatchHandler handles incoming messages in batches, useful to amortize
+// the cost of expensive operations, such as bulk database inserts.
+//
+// A BatchHandler is fallible: if the whole batch failed, all the messages
+// in the batch should be negatively-acknowledged. Use BatchError to report
+// failures of single messages inside the batch instead.
+type BatchHandler interface {
+	Handle(context.Context, []amqp.Delivery) error
+}
+
+// BatchFunc is a function that implements the BatchHandler interface, useful
+// to specify BatchHandlers inline as simple functions.
+type BatchFunc func(context.Context, []amqp.Delivery) error
+
+// Handle calls the underlying function to handle the incoming messages.
+func (fn BatchFunc) Handle(ctx context.Context, deliveries []amqp.Delivery) error {
+	return fn(ctx, deliveries)
+}
+
+// BatchError is returned by a BatchHandler to report which messages
+// of the batch failed, identified by their index in the batch.
+//
+// Messages not reported in Failures are considered successfully handled.
+type BatchError struct {
+	Failures map[int]error
+}
+
+func (err *BatchError) Error() string {
+	indexes := make([]int, 0, len(err.Failures))
+	for i := range err.Failures {
+		indexes = append(indexes, i)
+	}
+
+	sort.Ints(indexes)
+
+	messages := make([]string, 0, len(indexes))
+	for _, i := range indexes {
+		messages = append(messages, fmt.Sprintf("#%d: %s", i, err.Failures[i]))
+	}
+
+	return fmt.Sprintf("handler: %d messages in batch failed (%s)", len(indexes), strings.Join(messages, ", "))
+}
@@ -0,0 +1,31 @@
+package handler
+
+import "errors"
+
+type rejectError struct {
+	err error
+}
+
+func (err *rejectError) Error() string { return err.err.Error() }
+func (err *rejectError) Unwrap() error { return err.err }
+
+// Reject wraps an error returned by a Handler, to signal that the message
+// should be rejected without being requeued, e.g. because it is malformed
+// and would never be handled successfully.
+//
+// Rejected messages are dead-lettered by the AMQP broker, if the queue
+// has a dead-letter exchange.
+func Reject(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &rejectError{err: err}
+}
+
+// IsRejected reports whether the error, or any error it wraps,
+// has been returned by Reject.
+func IsRejected(err error) bool {
+	var rejected *rejectError
+	return errors.As(err, &rejected)
+}
@@ -0,0 +1,394 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ar3s3ru/go-carrot/handler"
+	"github.com/ar3s3ru/go-carrot/listener"
+
+	"github.com/streadway/amqp"
+)
+
+// ErrCircuitOpen is returned by the CircuitBreaker middleware, without calling
+// the message handler, while the circuit is open.
+var ErrCircuitOpen = errors.New("middleware.CircuitBreaker: circuit open")
+
+// CircuitState is the state of the circuit controlled by the CircuitBreaker
+// middleware.
+type CircuitState string
+
+// Supported circuit states.
+const (
+	// CircuitClosed means messages are passed to the message handler,
+	// while keeping track of its failure rate.
+	CircuitClosed CircuitState = "closed"
+	// CircuitOpen means the message handler failure rate exceeded the threshold,
+	// and messages are rejected without calling the message handler.
+	CircuitOpen CircuitState = "open"
+	// CircuitHalfOpen means some probe messages are passed to the message handler,
+	// to decide whether the circuit should be closed or opened again.
+	CircuitHalfOpen CircuitState = "half-open"
+)
+
+func (state CircuitState) String() string { return string(state) }
+
+// Default values used by the CircuitBreaker middleware.
+const (
+	DefaultCircuitWindow      = 1 * time.Minute
+	DefaultCircuitFailureRate = 0.5
+	DefaultCircuitMinRequests = 10
+	DefaultCircuitOpenTimeout = 30 * time.Second
+	DefaultCircuitProbes      = 3
+	DefaultCircuitRetryDelay  = 1 * time.Second
+)
+
+// circuitBuckets is the number of buckets the sliding window is divided into.
+const circuitBuckets = 10
+
+// minCircuitWindow is the shortest sliding window supported, so that
+// each bucket lasts at least one millisecond.
+const minCircuitWindow = circuitBuckets * time.Millisecond
+
+type circuitBucket struct {
+	start    time.Time
+	total    int
+	failures int
+}
+
+type circuitBreaker struct {
+	mx sync.Mutex
+
+	state    CircuitState
+	openedAt time.Time
+	probing  int
+	probed   int
+	buckets  [circuitBuckets]circuitBucket
+
+	window      time.Duration
+	failureRate float64
+	minRequests int
+	openTimeout time.Duration
+	probes      int
+	retryDelay  time.Duration
+
+	pauser        func() listener.Pauser
+	onStateChange func(from, to CircuitState)
+	onError       func(error)
+
+	// callbacks are run once the lock is released.
+	callbacks []func()
+}
+
+// CircuitBreaker stops calling the message handler when it fails consistently,
+// e.g. because a dependency like a database is down, to avoid hammering
+// both the broker and the dependency with messages bound to fail.
+//
+// The circuit opens when the failure rate of the message handler, computed over
+// a sliding window, exceeds the threshold specified with CircuitFailureRate.
+// While the circuit is open, messages are rejected with ErrCircuitOpen after
+// the delay specified with CircuitRetryDelay, so that the consumer requeues them.
+//
+// After the timeout specified with CircuitOpenTimeout, the circuit is half-open:
+// a limited number of probe messages are passed to the message handler, and the
+// circuit closes if all of them succeed, or opens again on the first failure.
+//
+// Use CircuitPause to pause the Listener while the circuit is open,
+// instead of rejecting messages.
+func CircuitBreaker(options ...CircuitBreakerOption) func(handler.Handler) handler.Handler {
+	breaker := &circuitBreaker{
+		state:       CircuitClosed,
+		window:      DefaultCircuitWindow,
+		failureRate: DefaultCircuitFailureRate,
+		minRequests: DefaultCircuitMinRequests,
+		openTimeout: DefaultCircuitOpenTimeout,
+		probes:      DefaultCircuitProbes,
+		retryDelay:  DefaultCircuitRetryDelay,
+	}
+
+	for _, option := range options {
+		if option == nil {
+			continue
+		}
+
+		option(breaker)
+	}
+
+	return func(next handler.Handler) handler.Handler {
+		return handler.Func(func(ctx context.Context, delivery amqp.Delivery) error {
+			probe, ok := breaker.allow(time.Now())
+			if !ok {
+				return breaker.reject(ctx)
+			}
+
+			err := next.Handle(ctx, delivery)
+			breaker.record(time.Now(), probe, err != nil)
+
+			return err
+		})
+	}
+}
+
+// unlock releases the lock, then runs the callbacks of the state changes
+// happened while holding it, so that they can't block the other messages.
+func (breaker *circuitBreaker) unlock() {
+	callbacks := breaker.callbacks
+	breaker.callbacks = nil
+	breaker.mx.Unlock()
+
+	for _, fn := range callbacks {
+		fn()
+	}
+}
+
+// allow reports whether the message should be passed to the message handler,
+// and whether it is a probe message.
+func (breaker *circuitBreaker) allow(now time.Time) (probe, ok bool) {
+	breaker.mx.Lock()
+	defer breaker.unlock()
+
+	if breaker.state == CircuitOpen && now.Sub(breaker.openedAt) >= breaker.openTimeout {
+		breaker.transition(CircuitHalfOpen)
+	}
+
+	switch breaker.state {
+	case CircuitClosed:
+		return false, true
+
+	case CircuitHalfOpen:
+		if breaker.probing+breaker.probed >= breaker.probes {
+			return false, false
+		}
+
+		breaker.probing++
+
+		return true, true
+
+	default:
+		return false, false
+	}
+}
+
+func (breaker *circuitBreaker) reject(ctx context.Context) error {
+	timer := time.NewTimer(breaker.retryDelay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	return ErrCircuitOpen
+}
+
+func (breaker *circuitBreaker) record(now time.Time, probe, failed bool) {
+	breaker.mx.Lock()
+	defer breaker.unlock()
+
+	if probe {
+		breaker.recordProbe(now, failed)
+		return
+	}
+
+	// Messages handled while the state changed are not relevant anymore.
+	if breaker.state != CircuitClosed {
+		return
+	}
+
+	bucket := breaker.bucket(now)
+	bucket.total++
+
+	if failed {
+		bucket.failures++
+	}
+
+	total, failures := breaker.count(now)
+	if total >= breaker.minRequests && float64(failures)/float64(total) >= breaker.failureRate {
+		breaker.open(now)
+	}
+}
+
+func (breaker *circuitBreaker) recordProbe(now time.Time, failed bool) {
+	if breaker.state != CircuitHalfOpen {
+		return
+	}
+
+	breaker.probing--
+
+	if failed {
+		breaker.open(now)
+		return
+	}
+
+	breaker.probed++
+
+	if breaker.probed >= breaker.probes {
+		breaker.buckets = [circuitBuckets]circuitBucket{}
+		breaker.transition(CircuitClosed)
+	}
+}
+
+// bucket returns the sliding window bucket for the specified time,
+// resetting it if it belongs to a previous window.
+func (breaker *circuitBreaker) bucket(now time.Time) *circuitBucket {
+	size := breaker.window / circuitBuckets
+	start := now.Truncate(size)
+	bucket := &breaker.buckets[(start.UnixNano()/int64(size))%circuitBuckets]
+
+	if !bucket.start.Equal(start) {
+		*bucket = circuitBucket{start: start}
+	}
+
+	return bucket
+}
+
+func (breaker *circuitBreaker) count(now time.Time) (total, failures int) {
+	since := now.Add(-breaker.window)
+
+	for _, bucket := range breaker.buckets {
+		if bucket.start.After(since) {
+			total += bucket.total
+			failures += bucket.failures
+		}
+	}
+
+	return total, failures
+}
+
+func (breaker *circuitBreaker) open(now time.Time) {
+	breaker.openedAt = now
+	breaker.transition(CircuitOpen)
+
+	if breaker.pauser == nil {
+		return
+	}
+
+	breaker.callbacks = append(breaker.callbacks, breaker.pause)
+}
+
+// pause pauses the Listener while the circuit is open: if it can't be paused,
+// messages are rejected instead.
+func (breaker *circuitBreaker) pause() {
+	pauser := breaker.pauser()
+	if pauser == nil {
+		return
+	}
+
+	// Pausing waits for in-flight messages to be handled, including the one
+	// that opened the circuit, hence it must happen asynchronously.
+	go func() {
+		if err := pauser.Pause(context.Background()); err != nil {
+			breaker.error(fmt.Errorf("middleware.CircuitBreaker: failed to pause listener, %w", err))
+			return
+		}
+
+		time.Sleep(breaker.openTimeout)
+
+		breaker.mx.Lock()
+		if breaker.state == CircuitOpen {
+			breaker.transition(CircuitHalfOpen)
+		}
+		breaker.unlock()
+
+		if err := pauser.Resume(context.Background()); err != nil {
+			breaker.error(fmt.Errorf("middleware.CircuitBreaker: failed to resume listener, %w", err))
+		}
+	}()
+}
+
+func (breaker *circuitBreaker) error(err error) {
+	if breaker.onError != nil {
+		breaker.onError(err)
+	}
+}
+
+func (breaker *circuitBreaker) transition(state CircuitState) {
+	from := breaker.state
+	breaker.state = state
+	breaker.probing, breaker.probed = 0, 0
+
+	if breaker.onStateChange != nil && from != state {
+		breaker.callbacks = append(breaker.callbacks, func() { breaker.onStateChange(from, state) })
+	}
+}
+
+// CircuitBreakerOption is an optional functionality that can be added to the
+// CircuitBreaker middleware.
+type CircuitBreakerOption func(*circuitBreaker)
+
+// CircuitWindow specifies the duration of the sliding window used to compute
+// the failure rate of the message handler, of at least 10 milliseconds:
+// shorter windows are raised to this minimum.
+// If not specified, DefaultCircuitWindow is used.
+func CircuitWindow(window time.Duration) CircuitBreakerOption {
+	return func(breaker *circuitBreaker) {
+		if window < minCircuitWindow {
+			window = minCircuitWindow
+		}
+
+		breaker.window = window
+	}
+}
+
+// CircuitFailureRate specifies the failure rate, between 0 and 1, that opens
+// the circuit. If not specified, DefaultCircuitFailureRate is used.
+func CircuitFailureRate(rate float64) CircuitBreakerOption {
+	return func(breaker *circuitBreaker) { breaker.failureRate = rate }
+}
+
+// CircuitMinRequests specifies the minimum number of messages handled in the
+// sliding window before the failure rate is taken into account.
+// If not specified, DefaultCircuitMinRequests is used.
+func CircuitMinRequests(n int) CircuitBreakerOption {
+	return func(breaker *circuitBreaker) { breaker.minRequests = n }
+}
+
+// CircuitOpenTimeout specifies how long the circuit stays open before
+// becoming half-open. If not specified, DefaultCircuitOpenTimeout is used.
+func CircuitOpenTimeout(timeout time.Duration) CircuitBreakerOption {
+	return func(breaker *circuitBreaker) { breaker.openTimeout = timeout }
+}
+
+// CircuitProbes specifies the number of probe messages that must succeed
+// in the half-open state to close the circuit.
+// If not specified, DefaultCircuitProbes is used.
+func CircuitProbes(n int) CircuitBreakerOption {
+	return func(breaker *circuitBreaker) { breaker.probes = n }
+}
+
+// CircuitRetryDelay specifies how long to wait before rejecting a message
+// while the circuit is open, to avoid redelivering it immediately.
+// If not specified, DefaultCircuitRetryDelay is used.
+func CircuitRetryDelay(delay time.Duration) CircuitBreakerOption {
+	return func(breaker *circuitBreaker) { breaker.retryDelay = delay }
+}
+
+// CircuitPause specifies a function returning the listener.Pauser to pause
+// when the circuit opens, such as the carrot.Closer returned by the Runner.
+//
+// The Listener is resumed after the timeout specified with CircuitOpenTimeout,
+// when the circuit becomes half-open. Messages received while the Listener
+// is being paused are still rejected, as well as all the messages received
+// while the circuit is open if the Listener can't be paused: use
+// CircuitOnError to be notified of such failures.
+func CircuitPause(pauser func() listener.Pauser) CircuitBreakerOption {
+	return func(breaker *circuitBreaker) { breaker.pauser = pauser }
+}
+
+// CircuitOnStateChange specifies a callback function to execute
+// every time the circuit changes state.
+//
+// The callback is called without blocking the handling of other messages,
+// hence it might observe quick state changes out of order.
+func CircuitOnStateChange(fn func(from, to CircuitState)) CircuitBreakerOption {
+	return func(breaker *circuitBreaker) { breaker.onStateChange = fn }
+}
+
+// CircuitOnError specifies a callback function to execute when the Listener
+// specified with CircuitPause can't be paused or resumed.
+func CircuitOnError(fn func(error)) CircuitBreakerOption {
+	return func(breaker *circuitBreaker) { breaker.onError = fn }
+}
@@ -0,0 +1,162 @@
+package middleware_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ar3s3ru/go-carrot/handler"
+	"github.com/ar3s3ru/go-carrot/handler/router/middleware"
+	"github.com/ar3s3ru/go-carrot/listener"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+type pauser struct {
+	mx      sync.Mutex
+	paused  int
+	resumed int
+	err     error
+}
+
+func (p *pauser) Pause(context.Context) error {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	p.paused++
+	return p.err
+}
+
+func (p *pauser) Resume(context.Context) error {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	p.resumed++
+	return nil
+}
+
+func (p *pauser) counts() (int, int) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	return p.paused, p.resumed
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	ctx := context.Background()
+	failure := errors.New("dependency down")
+
+	t.Run("it opens after failures, and closes after successful probes", func(t *testing.T) {
+		var (
+			calls       int
+			fail        = true
+			transitions []middleware.CircuitState
+		)
+
+		h := middleware.CircuitBreaker(
+			middleware.CircuitMinRequests(4),
+			middleware.CircuitFailureRate(0.5),
+			middleware.CircuitOpenTimeout(50*time.Millisecond),
+			middleware.CircuitProbes(2),
+			middleware.CircuitRetryDelay(time.Millisecond),
+			middleware.CircuitOnStateChange(func(_, to middleware.CircuitState) {
+				transitions = append(transitions, to)
+			}),
+		)(handler.Func(func(context.Context, amqp.Delivery) error {
+			calls++
+			if fail {
+				return failure
+			}
+			return nil
+		}))
+
+		assert.NoError(t, func() error { fail = false; return h.Handle(ctx, amqp.Delivery{}) }())
+		fail = true
+
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, failure, h.Handle(ctx, amqp.Delivery{}))
+		}
+
+		// Circuit is open: the handler is not called anymore.
+		assert.Equal(t, middleware.ErrCircuitOpen, h.Handle(ctx, amqp.Delivery{}))
+		assert.Equal(t, 4, calls)
+
+		time.Sleep(60 * time.Millisecond)
+
+		// First probe fails, opening the circuit again.
+		assert.Equal(t, failure, h.Handle(ctx, amqp.Delivery{}))
+		assert.Equal(t, middleware.ErrCircuitOpen, h.Handle(ctx, amqp.Delivery{}))
+
+		time.Sleep(60 * time.Millisecond)
+
+		fail = false
+		assert.NoError(t, h.Handle(ctx, amqp.Delivery{}))
+		assert.NoError(t, h.Handle(ctx, amqp.Delivery{}))
+		assert.NoError(t, h.Handle(ctx, amqp.Delivery{}))
+		assert.Equal(t, 8, calls)
+
+		assert.Equal(t, []middleware.CircuitState{
+			middleware.CircuitOpen,
+			middleware.CircuitHalfOpen,
+			middleware.CircuitOpen,
+			middleware.CircuitHalfOpen,
+			middleware.CircuitClosed,
+		}, transitions)
+	})
+
+	t.Run("it supports windows shorter than the bucket resolution", func(t *testing.T) {
+		h := middleware.CircuitBreaker(
+			middleware.CircuitWindow(time.Nanosecond),
+			middleware.CircuitMinRequests(1),
+		)(handler.Func(func(context.Context, amqp.Delivery) error {
+			return failure
+		}))
+
+		assert.NotPanics(t, func() {
+			assert.Equal(t, failure, h.Handle(ctx, amqp.Delivery{}))
+		})
+	})
+
+	t.Run("it pauses the listener while the circuit is open", func(t *testing.T) {
+		p := new(pauser)
+
+		h := middleware.CircuitBreaker(
+			middleware.CircuitMinRequests(1),
+			middleware.CircuitOpenTimeout(20*time.Millisecond),
+			middleware.CircuitPause(func() listener.Pauser { return p }),
+		)(handler.Func(func(context.Context, amqp.Delivery) error {
+			return failure
+		}))
+
+		assert.Equal(t, failure, h.Handle(ctx, amqp.Delivery{}))
+
+		assert.Eventually(t, func() bool {
+			paused, resumed := p.counts()
+			return paused == 1 && resumed == 1
+		}, time.Second, 5*time.Millisecond)
+	})
+	t.Run("it reports listeners that can't be paused, and rejects messages instead", func(t *testing.T) {
+		p := &pauser{err: listener.ErrNotPausable}
+		errs := make(chan error, 1)
+
+		h := middleware.CircuitBreaker(
+			middleware.CircuitMinRequests(1),
+			middleware.CircuitRetryDelay(time.Millisecond),
+			middleware.CircuitPause(func() listener.Pauser { return p }),
+			middleware.CircuitOnError(func(err error) { errs <- err }),
+		)(handler.Func(func(context.Context, amqp.Delivery) error {
+			return failure
+		}))
+
+		assert.Equal(t, failure, h.Handle(ctx, amqp.Delivery{}))
+
+		select {
+		case err := <-errs:
+			assert.True(t, errors.Is(err, listener.ErrNotPausable))
+		case <-time.After(time.Second):
+			assert.Fail(t, "pause failure should be reported")
+		}
+
+		assert.Equal(t, middleware.ErrCircuitOpen, h.Handle(ctx, amqp.Delivery{}))
+	})
+}
@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/ar3s3ru/go-carrot/handler"
+
+	"github.com/streadway/amqp"
+)
+
+// DeduplicationStore keeps track of the messages that have already been
+// processed by a message handler.
+type DeduplicationStore interface {
+	// Seen reports whether the message identified by the key
+	// has already been processed.
+	Seen(ctx context.Context, key string) (bool, error)
+
+	// Mark marks the message identified by the key as processed.
+	Mark(ctx context.Context, key string) error
+}
+
+// DeduplicationStats exposes the number of duplicated messages (hits)
+// and new messages (misses) seen by the Deduplicate middleware.
+//
+// Use the DeduplicateStats option to have a DeduplicationStats instance
+// updated by the middleware.
+type DeduplicationStats struct {
+	hits   uint64
+	misses uint64
+}
+
+// Hits returns the number of duplicated messages acknowledged
+// without calling the message handler.
+func (stats *DeduplicationStats) Hits() uint64 { return atomic.LoadUint64(&stats.hits) }
+
+// Misses returns the number of new messages passed to the message handler.
+func (stats *DeduplicationStats) Misses() uint64 { return atomic.LoadUint64(&stats.misses) }
+
+type deduplicator struct {
+	store DeduplicationStore
+	key   func(amqp.Delivery) string
+	stats *DeduplicationStats
+}
+
+// Deduplicate makes the message handler idempotent, by acknowledging
+// messages already processed without calling the message handler.
+//
+// Messages are identified by their amqp.Delivery.MessageId, unless a different
+// key is specified using the DeduplicateBy option. Messages without a key are
+// always passed to the message handler.
+//
+// A message is marked as processed in the DeduplicationStore only after
+// the message handler succeeded. When used together with SessionPerRequest
+// and a SQLDeduplicationStore, the message is marked in the same database
+// transaction used by the message handler.
+func Deduplicate(store DeduplicationStore, options ...DeduplicateOption) func(handler.Handler) handler.Handler {
+	dedup := deduplicator{
+		store: store,
+		key:   func(delivery amqp.Delivery) string { return delivery.MessageId },
+		stats: new(DeduplicationStats),
+	}
+
+	for _, option := range options {
+		if option == nil {
+			continue
+		}
+
+		option(&dedup)
+	}
+
+	return func(next handler.Handler) handler.Handler {
+		return handler.Func(func(ctx context.Context, delivery amqp.Delivery) error {
+			key := dedup.key(delivery)
+			if key == "" {
+				return next.Handle(ctx, delivery)
+			}
+
+			seen, err := dedup.store.Seen(ctx, key)
+			if err != nil {
+				return fmt.Errorf("middleware.Deduplicate: failed to check message, %w", err)
+			}
+
+			if seen {
+				atomic.AddUint64(&dedup.stats.hits, 1)
+				return nil
+			}
+
+			atomic.AddUint64(&dedup.stats.misses, 1)
+
+			if err := next.Handle(ctx, delivery); err != nil {
+				return err
+			}
+
+			if err := dedup.store.Mark(ctx, key); err != nil {
+				return fmt.Errorf("middleware.Deduplicate: failed to mark message as processed, %w", err)
+			}
+
+			return nil
+		})
+	}
+}
+
+// DeduplicateOption is an optional functionality that can be added to the
+// Deduplicate middleware.
+type DeduplicateOption func(*deduplicator)
+
+// DeduplicateBy specifies the function used to extract the key that identifies
+// an incoming message.
+func DeduplicateBy(key func(amqp.Delivery) string) DeduplicateOption {
+	return func(dedup *deduplicator) { dedup.key = key }
+}
+
+// DeduplicateStats specifies the DeduplicationStats instance to update
+// with the number of hits and misses.
+func DeduplicateStats(stats *DeduplicationStats) DeduplicateOption {
+	return func(dedup *deduplicator) { dedup.stats = stats }
+}
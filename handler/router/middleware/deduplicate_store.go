@@ -0,0 +1,171 @@
+package middleware
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// LRUDeduplicationStore is an in-memory DeduplicationStore, which remembers
+// up to a fixed number of processed messages for a limited amount of time.
+//
+// Use NewLRUDeduplicationStore to create a new instance.
+type LRUDeduplicationStore struct {
+	mx sync.Mutex
+
+	size    int
+	ttl     time.Duration
+	entries map[string]*list.Element
+	order   *list.List
+	now     func() time.Time
+}
+
+type lruEntry struct {
+	key       string
+	expiresAt time.Time
+}
+
+// NewLRUDeduplicationStore returns a new LRUDeduplicationStore, remembering up
+// to size processed messages, evicting the least recently used ones first.
+//
+// Messages are forgotten after ttl has elapsed since they have been processed;
+// a zero ttl never expires messages.
+func NewLRUDeduplicationStore(size int, ttl time.Duration) *LRUDeduplicationStore {
+	return &LRUDeduplicationStore{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[string]*list.Element, size),
+		order:   list.New(),
+		now:     time.Now,
+	}
+}
+
+// Seen reports whether the message has been processed and not yet expired.
+func (store *LRUDeduplicationStore) Seen(_ context.Context, key string) (bool, error) {
+	store.mx.Lock()
+	defer store.mx.Unlock()
+
+	element, ok := store.entries[key]
+	if !ok {
+		return false, nil
+	}
+
+	if entry := element.Value.(*lruEntry); store.expired(entry) {
+		store.remove(element)
+		return false, nil
+	}
+
+	store.order.MoveToFront(element)
+
+	return true, nil
+}
+
+// Mark remembers the message as processed, evicting the least recently used
+// message if the store is full.
+func (store *LRUDeduplicationStore) Mark(_ context.Context, key string) error {
+	store.mx.Lock()
+	defer store.mx.Unlock()
+
+	expiresAt := store.now().Add(store.ttl)
+
+	if element, ok := store.entries[key]; ok {
+		element.Value.(*lruEntry).expiresAt = expiresAt
+		store.order.MoveToFront(element)
+
+		return nil
+	}
+
+	store.entries[key] = store.order.PushFront(&lruEntry{key: key, expiresAt: expiresAt})
+
+	for store.order.Len() > store.size {
+		store.remove(store.order.Back())
+	}
+
+	return nil
+}
+
+func (store *LRUDeduplicationStore) expired(entry *lruEntry) bool {
+	return store.ttl > 0 && store.now().After(entry.expiresAt)
+}
+
+func (store *LRUDeduplicationStore) remove(element *list.Element) {
+	store.order.Remove(element)
+	delete(store.entries, element.Value.(*lruEntry).key)
+}
+
+// SQLDeduplicationStore is a DeduplicationStore backed by a database table,
+// which uses the transaction opened by SessionPerRequest, if any.
+//
+// Queries use "$N" placeholders, supported by both PostgreSQL and SQLite.
+//
+// Use NewSQLDeduplicationStore to create a new instance.
+type SQLDeduplicationStore struct {
+	db    *sql.DB
+	table string
+}
+
+// NewSQLDeduplicationStore returns a new SQLDeduplicationStore, using
+// the specified database table.
+func NewSQLDeduplicationStore(db *sql.DB, table string) *SQLDeduplicationStore {
+	return &SQLDeduplicationStore{db: db, table: table}
+}
+
+// CreateTable creates the deduplication table, if it doesn't exist already.
+func (store *SQLDeduplicationStore) CreateTable(ctx context.Context) error {
+	_, err := store.db.ExecContext(ctx, fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s (message_key TEXT PRIMARY KEY, processed_at TIMESTAMP NOT NULL)",
+		store.table,
+	))
+
+	return err
+}
+
+type execQueryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func (store *SQLDeduplicationStore) conn(ctx context.Context) execQueryer {
+	if tx, ok := Transaction(ctx); ok {
+		return tx
+	}
+
+	return store.db
+}
+
+// Seen reports whether the message has been stored in the deduplication table.
+func (store *SQLDeduplicationStore) Seen(ctx context.Context, key string) (bool, error) {
+	var found int
+
+	err := store.conn(ctx).
+		QueryRowContext(ctx, fmt.Sprintf("SELECT 1 FROM %s WHERE message_key = $1", store.table), key).
+		Scan(&found)
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return false, nil
+	case err != nil:
+		return false, err
+	default:
+		return true, nil
+	}
+}
+
+// Mark stores the message in the deduplication table.
+//
+// If the same message has been marked concurrently, Mark fails with
+// a primary key violation: when used with SessionPerRequest, the whole
+// transaction is rolled back, and the message will be recognized as
+// duplicated on redelivery.
+func (store *SQLDeduplicationStore) Mark(ctx context.Context, key string) error {
+	_, err := store.conn(ctx).ExecContext(ctx,
+		fmt.Sprintf("INSERT INTO %s (message_key, processed_at) VALUES ($1, $2)", store.table),
+		key, time.Now().UTC(),
+	)
+
+	return err
+}
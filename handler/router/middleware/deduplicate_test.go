@@ -0,0 +1,174 @@
+package middleware_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ar3s3ru/go-carrot/handler"
+	"github.com/ar3s3ru/go-carrot/handler/router/middleware"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeduplicate(t *testing.T) {
+	t.Run("duplicated messages are acknowledged without calling the handler", func(t *testing.T) {
+		calls := 0
+		stats := new(middleware.DeduplicationStats)
+
+		h := middleware.Deduplicate(
+			middleware.NewLRUDeduplicationStore(10, 0),
+			middleware.DeduplicateStats(stats),
+		)(handler.Func(func(context.Context, amqp.Delivery) error {
+			calls++
+			return nil
+		}))
+
+		ctx := context.Background()
+
+		assert.NoError(t, h.Handle(ctx, amqp.Delivery{MessageId: "1"}))
+		assert.NoError(t, h.Handle(ctx, amqp.Delivery{MessageId: "1"}))
+		assert.NoError(t, h.Handle(ctx, amqp.Delivery{MessageId: "2"}))
+
+		// Messages without id can't be deduplicated.
+		assert.NoError(t, h.Handle(ctx, amqp.Delivery{}))
+		assert.NoError(t, h.Handle(ctx, amqp.Delivery{}))
+
+		assert.Equal(t, 4, calls)
+		assert.Equal(t, uint64(1), stats.Hits())
+		assert.Equal(t, uint64(2), stats.Misses())
+	})
+
+	t.Run("failed messages are not marked as processed", func(t *testing.T) {
+		calls := 0
+		failure := errors.New("failed")
+
+		h := middleware.Deduplicate(middleware.NewLRUDeduplicationStore(10, 0))(
+			handler.Func(func(context.Context, amqp.Delivery) error {
+				calls++
+
+				if calls == 1 {
+					return failure
+				}
+
+				return nil
+			}),
+		)
+
+		ctx := context.Background()
+
+		assert.True(t, errors.Is(h.Handle(ctx, amqp.Delivery{MessageId: "1"}), failure))
+		assert.NoError(t, h.Handle(ctx, amqp.Delivery{MessageId: "1"}))
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("custom key extractor", func(t *testing.T) {
+		calls := 0
+
+		h := middleware.Deduplicate(
+			middleware.NewLRUDeduplicationStore(10, 0),
+			middleware.DeduplicateBy(func(delivery amqp.Delivery) string {
+				key, _ := delivery.Headers["idempotency-key"].(string)
+				return key
+			}),
+		)(handler.Func(func(context.Context, amqp.Delivery) error {
+			calls++
+			return nil
+		}))
+
+		ctx := context.Background()
+
+		assert.NoError(t, h.Handle(ctx, amqp.Delivery{MessageId: "1", Headers: amqp.Table{"idempotency-key": "a"}}))
+		assert.NoError(t, h.Handle(ctx, amqp.Delivery{MessageId: "2", Headers: amqp.Table{"idempotency-key": "a"}}))
+		assert.Equal(t, 1, calls)
+	})
+}
+
+func TestLRUDeduplicationStore(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("least recently used messages are evicted", func(t *testing.T) {
+		store := middleware.NewLRUDeduplicationStore(2, 0)
+
+		assert.NoError(t, store.Mark(ctx, "1"))
+		assert.NoError(t, store.Mark(ctx, "2"))
+
+		// Use "1", so that "2" is the least recently used.
+		seen, _ := store.Seen(ctx, "1")
+		assert.True(t, seen)
+
+		assert.NoError(t, store.Mark(ctx, "3"))
+
+		seen, _ = store.Seen(ctx, "2")
+		assert.False(t, seen)
+
+		seen, _ = store.Seen(ctx, "1")
+		assert.True(t, seen)
+	})
+
+	t.Run("messages expire after the ttl", func(t *testing.T) {
+		store := middleware.NewLRUDeduplicationStore(2, 10*time.Millisecond)
+
+		assert.NoError(t, store.Mark(ctx, "1"))
+
+		seen, _ := store.Seen(ctx, "1")
+		assert.True(t, seen)
+
+		time.Sleep(20 * time.Millisecond)
+
+		seen, _ = store.Seen(ctx, "1")
+		assert.False(t, seen)
+	})
+}
+
+func TestSQLDeduplicationStore(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+
+	// Every new connection opens a different in-memory database.
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+
+	store := middleware.NewSQLDeduplicationStore(db, "processed_messages")
+	require.NoError(t, store.CreateTable(context.Background()))
+
+	calls := 0
+	failure := errors.New("failed")
+
+	h := middleware.SessionPerRequest(db)(
+		middleware.Deduplicate(store)(
+			handler.Func(func(context.Context, amqp.Delivery) error {
+				calls++
+
+				if calls == 1 {
+					return failure
+				}
+
+				return nil
+			}),
+		),
+	)
+
+	ctx := context.Background()
+
+	// First attempt fails: the transaction is rolled back.
+	assert.True(t, errors.Is(h.Handle(ctx, amqp.Delivery{MessageId: "1"}), failure))
+
+	seen, err := store.Seen(ctx, "1")
+	assert.NoError(t, err)
+	assert.False(t, seen)
+
+	// Second attempt succeeds, third one is a duplicate.
+	assert.NoError(t, h.Handle(ctx, amqp.Delivery{MessageId: "1"}))
+	assert.NoError(t, h.Handle(ctx, amqp.Delivery{MessageId: "1"}))
+	assert.Equal(t, 2, calls)
+
+	seen, err = store.Seen(ctx, "1")
+	assert.NoError(t, err)
+	assert.True(t, seen)
+}
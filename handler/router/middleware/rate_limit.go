@@ -0,0 +1,154 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ar3s3ru/go-carrot/handler"
+
+	"github.com/streadway/amqp"
+	"golang.org/x/time/rate"
+)
+
+type rateLimiter struct {
+	limit rate.Limit
+	burst int
+	key   func(amqp.Delivery) string
+	now   func() time.Time
+
+	mx        sync.Mutex
+	limiters  map[string]*keyLimiter
+	lastSweep time.Time
+}
+
+// keyLimiter is the rate limiter of a single key.
+type keyLimiter struct {
+	*rate.Limiter
+	waiting  int
+	lastUsed time.Time
+}
+
+// RateLimit limits the number of messages per second passed to the message
+// handler, using a token bucket of the specified burst size.
+//
+// When no tokens are available, the middleware waits for one before calling
+// the message handler, or fails if the handler context is done first.
+//
+// By default, all messages share the same limit. Use the RateLimitBy option,
+// or one of RateLimitByHeader and RateLimitByRoutingKey, to have a separate
+// limit for each key, e.g. for each tenant. The limit of a key is forgotten
+// once its token bucket has been refilled, since it's then the same
+// as the limit of a new key, and no messages are waiting for it.
+func RateLimit(limit float64, burst int, options ...RateLimitOption) func(handler.Handler) handler.Handler {
+	limiter := &rateLimiter{
+		limit:    rate.Limit(limit),
+		burst:    burst,
+		key:      func(amqp.Delivery) string { return "" },
+		now:      time.Now,
+		limiters: make(map[string]*keyLimiter),
+	}
+
+	for _, option := range options {
+		if option == nil {
+			continue
+		}
+
+		option(limiter)
+	}
+
+	return func(next handler.Handler) handler.Handler {
+		return handler.Func(func(ctx context.Context, delivery amqp.Delivery) error {
+			l := limiter.acquire(limiter.key(delivery))
+			err := l.Wait(ctx)
+			limiter.release(l)
+
+			if err != nil {
+				return fmt.Errorf("middleware.RateLimit: failed to wait for rate limit, %w", err)
+			}
+
+			return next.Handle(ctx, delivery)
+		})
+	}
+}
+
+// acquire returns the limiter of the specified key, which can't be removed
+// until released.
+func (limiter *rateLimiter) acquire(key string) *keyLimiter {
+	limiter.mx.Lock()
+	defer limiter.mx.Unlock()
+
+	limiter.sweep(limiter.now())
+
+	l, ok := limiter.limiters[key]
+	if !ok {
+		l = &keyLimiter{Limiter: rate.NewLimiter(limiter.limit, limiter.burst)}
+		limiter.limiters[key] = l
+	}
+
+	l.waiting++
+
+	return l
+}
+
+// release marks the limiter as used once done waiting for it, since tokens
+// reserved by the waiting messages are only taken from the bucket by then.
+func (limiter *rateLimiter) release(l *keyLimiter) {
+	limiter.mx.Lock()
+	defer limiter.mx.Unlock()
+
+	l.waiting--
+	l.lastUsed = limiter.now()
+}
+
+// sweep removes the limiters no messages are waiting for, which have been
+// unused for longer than the time needed to refill their token bucket,
+// at most once in that time.
+func (limiter *rateLimiter) sweep(now time.Time) {
+	// Token buckets with no limit are never refilled.
+	if limiter.limit <= 0 {
+		return
+	}
+
+	refill := time.Duration(float64(limiter.burst) / float64(limiter.limit) * float64(time.Second))
+	if now.Sub(limiter.lastSweep) < refill {
+		return
+	}
+
+	for key, l := range limiter.limiters {
+		if l.waiting == 0 && now.Sub(l.lastUsed) >= refill {
+			delete(limiter.limiters, key)
+		}
+	}
+
+	limiter.lastSweep = now
+}
+
+// RateLimitOption is an optional functionality that can be added to the
+// RateLimit middleware.
+type RateLimitOption func(*rateLimiter)
+
+// RateLimitBy specifies the function used to extract the key of an incoming
+// message: messages with different keys have separate rate limits.
+func RateLimitBy(key func(amqp.Delivery) string) RateLimitOption {
+	return func(limiter *rateLimiter) { limiter.key = key }
+}
+
+// RateLimitByHeader uses the value of the specified header as rate limit key.
+// Messages without the header share the same limit.
+func RateLimitByHeader(header string) RateLimitOption {
+	return RateLimitBy(func(delivery amqp.Delivery) string {
+		value, ok := delivery.Headers[header]
+		if !ok {
+			return ""
+		}
+
+		return fmt.Sprint(value)
+	})
+}
+
+// RateLimitByRoutingKey uses the routing key of the message as rate limit key.
+var RateLimitByRoutingKey RateLimitOption = RateLimitBy(func(delivery amqp.Delivery) string {
+	return delivery.RoutingKey
+})
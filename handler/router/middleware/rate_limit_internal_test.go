@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter_Sweep(t *testing.T) {
+	now := time.Now()
+
+	limiter := &rateLimiter{
+		limit:    2,
+		burst:    4,
+		now:      func() time.Time { return now },
+		limiters: make(map[string]*keyLimiter),
+	}
+
+	use := func(key string) *keyLimiter {
+		l := limiter.acquire(key)
+		limiter.release(l)
+		return l
+	}
+
+	first := use("tenant-1")
+	use("tenant-2")
+	waiting := limiter.acquire("tenant-3")
+
+	// Token buckets are refilled in two seconds: tenant-2 is removed,
+	// while tenant-1 is still in use and messages are waiting for tenant-3.
+	now = now.Add(time.Second)
+	assert.Same(t, first, use("tenant-1"))
+
+	now = now.Add(1500 * time.Millisecond)
+	use("tenant-1")
+
+	assert.Len(t, limiter.limiters, 2)
+	assert.Contains(t, limiter.limiters, "tenant-1")
+	assert.Same(t, waiting, limiter.limiters["tenant-3"])
+
+	// Once done waiting, tenant-3 is used from then on.
+	limiter.release(waiting)
+
+	now = now.Add(2500 * time.Millisecond)
+	use("tenant-1")
+
+	assert.Len(t, limiter.limiters, 1)
+	assert.Contains(t, limiter.limiters, "tenant-1")
+}
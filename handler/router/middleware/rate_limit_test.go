@@ -0,0 +1,45 @@
+package middleware_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ar3s3ru/go-carrot/handler"
+	"github.com/ar3s3ru/go-carrot/handler/router/middleware"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimit(t *testing.T) {
+	noop := handler.Func(func(context.Context, amqp.Delivery) error { return nil })
+
+	t.Run("it waits for the rate limit on the handler context", func(t *testing.T) {
+		h := middleware.RateLimit(1, 1)(noop)
+
+		assert.NoError(t, h.Handle(context.Background(), amqp.Delivery{}))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		assert.Error(t, h.Handle(ctx, amqp.Delivery{}))
+	})
+
+	t.Run("it keeps a separate limit for each key", func(t *testing.T) {
+		h := middleware.RateLimit(1, 1, middleware.RateLimitByHeader("tenant"))(noop)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		assert.NoError(t, h.Handle(ctx, amqp.Delivery{Headers: amqp.Table{"tenant": "a"}}))
+		assert.NoError(t, h.Handle(ctx, amqp.Delivery{Headers: amqp.Table{"tenant": "b"}}))
+		assert.Error(t, h.Handle(ctx, amqp.Delivery{Headers: amqp.Table{"tenant": "a"}}))
+
+		h = middleware.RateLimit(1, 1, middleware.RateLimitByRoutingKey)(noop)
+
+		assert.NoError(t, h.Handle(ctx, amqp.Delivery{RoutingKey: "a"}))
+		assert.NoError(t, h.Handle(ctx, amqp.Delivery{RoutingKey: "b"}))
+		assert.Error(t, h.Handle(ctx, amqp.Delivery{RoutingKey: "b"}))
+	})
+}
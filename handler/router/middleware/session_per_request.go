@@ -3,16 +3,51 @@ package middleware
 import (
 	"context"
 	"database/sql"
+	"fmt"
 
 	"github.com/ar3s3ru/go-carrot/handler"
 
 	"github.com/streadway/amqp"
 )
 
+type txKey struct{}
+
+// Transaction returns the *sql.Tx opened by SessionPerRequest for the message
+// currently being handled, if any.
+func Transaction(ctx context.Context) (*sql.Tx, bool) {
+	tx, ok := ctx.Value(txKey{}).(*sql.Tx)
+	return tx, ok
+}
+
+// SessionPerRequest opens a new database transaction for each incoming message,
+// which is accessible from the message handler through the Transaction function.
+//
+// The transaction is committed if the message handler succeeds,
+// and rolled back if it fails with an error.
 func SessionPerRequest(db *sql.DB) func(handler.Handler) handler.Handler {
 	return func(next handler.Handler) handler.Handler {
 		return handler.Func(func(ctx context.Context, delivery amqp.Delivery) error {
-			panic("implement me")
+			tx, err := db.BeginTx(ctx, nil)
+			if err != nil {
+				return fmt.Errorf("middleware.SessionPerRequest: failed to begin transaction, %w", err)
+			}
+
+			if err := next.Handle(context.WithValue(ctx, txKey{}, tx), delivery); err != nil {
+				if rollbackErr := tx.Rollback(); rollbackErr != nil {
+					return fmt.Errorf("middleware.SessionPerRequest: failed to rollback transaction, %w (caused by %s)",
+						rollbackErr,
+						err,
+					)
+				}
+
+				return err
+			}
+
+			if err := tx.Commit(); err != nil {
+				return fmt.Errorf("middleware.SessionPerRequest: failed to commit transaction, %w", err)
+			}
+
+			return nil
 		})
 	}
 }
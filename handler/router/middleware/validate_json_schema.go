@@ -0,0 +1,201 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ar3s3ru/go-carrot/handler"
+	"github.com/ar3s3ru/go-carrot/internal/republish"
+	"github.com/ar3s3ru/go-carrot/publisher"
+
+	"github.com/streadway/amqp"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// Headers added to the messages dead-lettered by the ValidateJSONSchema
+// middleware, when using the JSONSchemaDeadLetter option.
+const (
+	HeaderValidationErrors    = "x-validation-errors"
+	HeaderOriginalExchange    = "x-original-exchange"
+	HeaderOriginalRoutingKey  = "x-original-routing-key"
+	HeaderOriginalConsumerTag = "x-original-consumer-tag"
+)
+
+// Publisher is the interface used by middlewares to publish messages,
+// such as publisher.Publisher.
+type Publisher = publisher.Interface
+
+// JSONSchema is a compiled JSON Schema, used by the ValidateJSONSchema
+// middleware to validate incoming messages.
+type JSONSchema struct {
+	schema *gojsonschema.Schema
+}
+
+// CompileJSONSchema compiles the provided JSON Schema document.
+func CompileJSONSchema(schema []byte) (*JSONSchema, error) {
+	compiled, err := gojsonschema.NewSchema(gojsonschema.NewBytesLoader(schema))
+	if err != nil {
+		return nil, fmt.Errorf("middleware.CompileJSONSchema: failed to compile schema, %w", err)
+	}
+
+	return &JSONSchema{schema: compiled}, nil
+}
+
+// MustCompileJSONSchema is like CompileJSONSchema, but panics if the schema
+// cannot be compiled. Useful for schemas embedded in the application.
+func MustCompileJSONSchema(schema []byte) *JSONSchema {
+	compiled, err := CompileJSONSchema(schema)
+	if err != nil {
+		panic(err)
+	}
+
+	return compiled
+}
+
+// validate returns the list of validation errors of the provided JSON document.
+func (schema *JSONSchema) validate(document []byte) []string {
+	result, err := schema.schema.Validate(gojsonschema.NewBytesLoader(document))
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	violations := make([]string, 0, len(result.Errors()))
+	for _, violation := range result.Errors() {
+		violations = append(violations, violation.String())
+	}
+
+	return violations
+}
+
+// ValidationError is returned by the ValidateJSONSchema middleware when
+// the incoming message doesn't match the JSON Schema.
+type ValidationError struct {
+	Errors []string
+}
+
+func (err *ValidationError) Error() string {
+	return "middleware.ValidateJSONSchema: invalid message, " + strings.Join(err.Errors, "; ")
+}
+
+type jsonSchemaValidator struct {
+	schema *JSONSchema
+	byType map[string]*JSONSchema
+
+	publisher  Publisher
+	exchange   string
+	routingKey string
+}
+
+// ValidateJSONSchema validates the body of incoming messages against
+// the provided JSON Schema, before calling the message handler.
+//
+// Use the middleware inline for a router binding, with router.Router.With,
+// to have a different schema for each binding. Use the JSONSchemaByType option
+// to pick the schema using the amqp.Delivery.Type property instead: messages
+// without a schema for their type are validated against the provided schema,
+// if not nil, or passed to the message handler as they are.
+//
+// Invalid messages are rejected without being requeued, by returning
+// a *ValidationError wrapped with handler.Reject, so that the AMQP broker
+// dead-letters them. Use the JSONSchemaDeadLetter option to have invalid
+// messages published to the dead-letter exchange with headers describing
+// the validation errors instead.
+func ValidateJSONSchema(schema *JSONSchema, options ...JSONSchemaOption) func(handler.Handler) handler.Handler {
+	validator := jsonSchemaValidator{schema: schema}
+
+	for _, option := range options {
+		if option == nil {
+			continue
+		}
+
+		option(&validator)
+	}
+
+	return func(next handler.Handler) handler.Handler {
+		return handler.Func(func(ctx context.Context, delivery amqp.Delivery) error {
+			schema := validator.schemaFor(delivery)
+			if schema == nil {
+				return next.Handle(ctx, delivery)
+			}
+
+			violations := schema.validate(delivery.Body)
+			if len(violations) == 0 {
+				return next.Handle(ctx, delivery)
+			}
+
+			err := &ValidationError{Errors: violations}
+
+			if validator.publisher == nil {
+				return handler.Reject(err)
+			}
+
+			return validator.deadLetter(ctx, delivery, err)
+		})
+	}
+}
+
+func (validator jsonSchemaValidator) schemaFor(delivery amqp.Delivery) *JSONSchema {
+	if schema, ok := validator.byType[delivery.Type]; ok {
+		return schema
+	}
+
+	return validator.schema
+}
+
+// deadLetter publishes the invalid message to the dead-letter exchange,
+// so that the original message can be acknowledged.
+func (validator jsonSchemaValidator) deadLetter(ctx context.Context, delivery amqp.Delivery, err *ValidationError) error {
+	msg := republish.Publishing(delivery)
+	if msg.Headers == nil {
+		msg.Headers = make(amqp.Table)
+	}
+
+	violations := make([]interface{}, 0, len(err.Errors))
+	for _, violation := range err.Errors {
+		violations = append(violations, violation)
+	}
+
+	msg.Headers[HeaderValidationErrors] = violations
+	msg.Headers[HeaderOriginalExchange] = delivery.Exchange
+	msg.Headers[HeaderOriginalRoutingKey] = delivery.RoutingKey
+	msg.Headers[HeaderOriginalConsumerTag] = delivery.ConsumerTag
+
+	routingKey := validator.routingKey
+	if routingKey == "" {
+		routingKey = delivery.RoutingKey
+	}
+
+	if pubErr := validator.publisher.Publish(ctx, validator.exchange, routingKey, msg); pubErr != nil {
+		return fmt.Errorf("middleware.ValidateJSONSchema: failed to dead-letter invalid message, %w", pubErr)
+	}
+
+	return nil
+}
+
+// JSONSchemaOption is an optional functionality that can be added to the
+// ValidateJSONSchema middleware.
+type JSONSchemaOption func(*jsonSchemaValidator)
+
+// JSONSchemaByType specifies the JSON Schemas used to validate incoming messages,
+// by their amqp.Delivery.Type property.
+func JSONSchemaByType(schemas map[string]*JSONSchema) JSONSchemaOption {
+	return func(validator *jsonSchemaValidator) { validator.byType = schemas }
+}
+
+// JSONSchemaDeadLetter publishes invalid messages to the specified dead-letter
+// exchange, using the provided Publisher, and acknowledges them.
+//
+// Published messages keep the original properties and headers, adding the
+// validation errors in the "x-validation-errors" header, together with
+// the original exchange, routing key and consumer tag.
+// If routingKey is empty, the original routing key is used.
+//
+// If the message cannot be published, it is requeued.
+func JSONSchemaDeadLetter(publisher Publisher, exchange, routingKey string) JSONSchemaOption {
+	return func(validator *jsonSchemaValidator) {
+		validator.publisher = publisher
+		validator.exchange = exchange
+		validator.routingKey = routingKey
+	}
+}
@@ -0,0 +1,108 @@
+package middleware_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ar3s3ru/go-carrot/handler"
+	"github.com/ar3s3ru/go-carrot/handler/router/middleware"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const orderSchema = `{
+	"type": "object",
+	"required": ["id", "amount"],
+	"properties": {
+		"id": {"type": "string"},
+		"amount": {"type": "number", "minimum": 0}
+	}
+}`
+
+type publishFunc func(ctx context.Context, exchange, key string, msg amqp.Publishing) error
+
+func (fn publishFunc) Publish(ctx context.Context, exchange, key string, msg amqp.Publishing) error {
+	return fn(ctx, exchange, key, msg)
+}
+
+func TestValidateJSONSchema(t *testing.T) {
+	ctx := context.Background()
+	schema := middleware.MustCompileJSONSchema([]byte(orderSchema))
+
+	calls := 0
+	next := handler.Func(func(context.Context, amqp.Delivery) error {
+		calls++
+		return nil
+	})
+
+	t.Run("invalid schemas fail to compile", func(t *testing.T) {
+		_, err := middleware.CompileJSONSchema([]byte(`{"type": 1}`))
+		assert.Error(t, err)
+	})
+
+	t.Run("valid messages are passed to the handler", func(t *testing.T) {
+		calls = 0
+		h := middleware.ValidateJSONSchema(schema)(next)
+
+		assert.NoError(t, h.Handle(ctx, amqp.Delivery{Body: []byte(`{"id": "1", "amount": 10}`)}))
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("invalid messages are rejected", func(t *testing.T) {
+		calls = 0
+		h := middleware.ValidateJSONSchema(schema)(next)
+
+		for _, body := range []string{`{"id": "1", "amount": -1}`, `not json`} {
+			err := h.Handle(ctx, amqp.Delivery{Body: []byte(body)})
+
+			var validationErr *middleware.ValidationError
+			assert.True(t, handler.IsRejected(err))
+			assert.True(t, errors.As(err, &validationErr))
+			assert.NotEmpty(t, validationErr.Errors)
+		}
+
+		assert.Equal(t, 0, calls)
+	})
+
+	t.Run("schemas are picked by message type", func(t *testing.T) {
+		calls = 0
+		h := middleware.ValidateJSONSchema(nil, middleware.JSONSchemaByType(map[string]*middleware.JSONSchema{
+			"order.created": schema,
+		}))(next)
+
+		assert.True(t, handler.IsRejected(h.Handle(ctx, amqp.Delivery{Type: "order.created", Body: []byte(`{}`)})))
+		assert.NoError(t, h.Handle(ctx, amqp.Delivery{Type: "order.deleted", Body: []byte(`{}`)}))
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("invalid messages are dead-lettered with validation errors", func(t *testing.T) {
+		var published []amqp.Publishing
+
+		h := middleware.ValidateJSONSchema(schema, middleware.JSONSchemaDeadLetter(
+			publishFunc(func(_ context.Context, exchange, key string, msg amqp.Publishing) error {
+				assert.Equal(t, "dlx", exchange)
+				assert.Equal(t, "orders.created", key)
+				published = append(published, msg)
+				return nil
+			}),
+			"dlx", "",
+		))(next)
+
+		require.NoError(t, h.Handle(ctx, amqp.Delivery{
+			Exchange:   "orders",
+			RoutingKey: "orders.created",
+			MessageId:  "message-1",
+			Headers:    amqp.Table{"tenant": "acme"},
+			Body:       []byte(`{"id": 1}`),
+		}))
+
+		require.Len(t, published, 1)
+		assert.Equal(t, "message-1", published[0].MessageId)
+		assert.Equal(t, "acme", published[0].Headers["tenant"])
+		assert.Equal(t, "orders", published[0].Headers[middleware.HeaderOriginalExchange])
+		assert.Len(t, published[0].Headers[middleware.HeaderValidationErrors], 2)
+	})
+}
@@ -3,6 +3,7 @@ package router
 import (
 	"context"
 	"errors"
+	"sort"
 
 	"github.com/ar3s3ru/go-carrot/handler"
 
@@ -52,6 +53,19 @@ func (r Mux) Handle(ctx context.Context, delivery amqp.Delivery) error {
 	return applyTo(handler, r.middlewares...).Handle(ctx, delivery)
 }
 
+// Bindings returns the names of the consumers bound to a message handler,
+// sorted alphabetically.
+func (r Mux) Bindings() []string {
+	bindings := make([]string, 0, len(r.consumers))
+	for consumer := range r.consumers {
+		bindings = append(bindings, consumer)
+	}
+
+	sort.Strings(bindings)
+
+	return bindings
+}
+
 // Bind binds a message handler function to the specified queue, if the
 // handler is not nil.
 func (r *Mux) Bind(queue string, h handler.Handler) {
@@ -0,0 +1,194 @@
+// Package health reports the liveness and readiness of a carrot Runner,
+// by looking at the state of its AMQP connection and consumers.
+package health
+
+import (
+	"sync"
+
+	"github.com/ar3s3ru/go-carrot/blocking"
+	"github.com/ar3s3ru/go-carrot/listener"
+
+	"github.com/streadway/amqp"
+)
+
+// Connection is the connection interface the Monitor uses to watch
+// for connection closing and broker flow-control alarms.
+type Connection interface {
+	NotifyClose(chan *amqp.Error) chan *amqp.Error
+	NotifyBlocked(chan amqp.Blocking) chan amqp.Blocking
+}
+
+// Monitor keeps track of the state of an AMQP connection and of the consumers
+// listening on it.
+//
+// Use New to create a new Monitor instance, and carrot.WithHealth to have it
+// fed by a carrot.Runner.
+type Monitor struct {
+	mx sync.RWMutex
+
+	watched   bool
+	connected bool
+	blocked   bool
+	reason    string
+	reporter  listener.Reporter
+	tracker   *blocking.Tracker
+}
+
+// New returns a new Monitor instance, which reports as alive but not ready
+// until a connection is being watched.
+func New() *Monitor {
+	return new(Monitor)
+}
+
+// WatchConnection starts watching the provided connection for closing
+// and blocked notifications.
+func (m *Monitor) WatchConnection(conn Connection) {
+	closing := conn.NotifyClose(make(chan *amqp.Error, 1))
+	blocking := conn.NotifyBlocked(make(chan amqp.Blocking, 1))
+
+	m.mx.Lock()
+	m.watched = true
+	m.connected = true
+	m.blocked = false
+	m.reason = ""
+	m.mx.Unlock()
+
+	go m.watch(closing, blocking)
+}
+
+// WatchListener uses the provided Reporter to report the state of the consumers.
+func (m *Monitor) WatchListener(reporter listener.Reporter) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	m.reporter = reporter
+}
+
+func (m *Monitor) watch(closing <-chan *amqp.Error, blocking <-chan amqp.Blocking) {
+	for {
+		select {
+		case err, ok := <-closing:
+			m.mx.Lock()
+			m.connected = false
+
+			if ok && err != nil {
+				m.reason = err.Error()
+			}
+
+			m.mx.Unlock()
+
+			return
+
+		case notification, ok := <-blocking:
+			if !ok {
+				// Blocking notifications channel gets closed when the connection
+				// shuts down: wait for the closing notification instead.
+				blocking = nil
+				continue
+			}
+
+			m.mx.Lock()
+			m.blocked = notification.Active
+			m.reason = notification.Reason
+			m.mx.Unlock()
+		}
+	}
+}
+
+// WatchPublishing uses the provided blocking.Tracker to report whether
+// publishing is blocked by the broker, e.g. when publishers use a connection
+// other than the watched one.
+func (m *Monitor) WatchPublishing(tracker *blocking.Tracker) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	m.tracker = tracker
+}
+
+// Report returns a snapshot of the current state of the watched components.
+func (m *Monitor) Report() Report {
+	m.mx.RLock()
+	defer m.mx.RUnlock()
+
+	report := Report{
+		Connection: ConnectionReport{
+			Watched: m.watched,
+			Open:    m.connected,
+			Blocked: m.blocked,
+			Reason:  m.reason,
+		},
+	}
+
+	if m.tracker != nil {
+		report.Publishing = &PublishingReport{
+			Blocked: m.tracker.Blocked(),
+			Reason:  m.tracker.Reason(),
+		}
+	}
+
+	if m.reporter != nil {
+		for _, status := range m.reporter.Status() {
+			report.Consumers = append(report.Consumers, ConsumerReport{
+				Name:  status.Name,
+				State: status.State,
+			})
+		}
+	}
+
+	return report
+}
+
+// Report is a snapshot of the state of the components watched by a Monitor.
+type Report struct {
+	Connection ConnectionReport  `json:"connection"`
+	Publishing *PublishingReport `json:"publishing,omitempty"`
+	Consumers  []ConsumerReport  `json:"consumers,omitempty"`
+}
+
+// ConnectionReport describes the state of the watched AMQP connection.
+type ConnectionReport struct {
+	Watched bool   `json:"-"`
+	Open    bool   `json:"open"`
+	Blocked bool   `json:"blocked"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// PublishingReport describes whether publishing is blocked by the broker.
+type PublishingReport struct {
+	Blocked bool   `json:"blocked"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// ConsumerReport describes the state of a single consumer.
+type ConsumerReport struct {
+	Name  string         `json:"name"`
+	State listener.State `json:"state"`
+}
+
+// Live reports whether the service is alive: a service is considered dead
+// only when the watched connection has been closed, since carrot does not
+// support reconnection.
+func (report Report) Live() bool {
+	return !report.Connection.Watched || report.Connection.Open
+}
+
+// Ready reports whether the service is ready to receive messages: the connection
+// must be open and not blocked by the broker, publishing must not be blocked
+// either, and all consumers must be consuming.
+func (report Report) Ready() bool {
+	if !report.Connection.Open || report.Connection.Blocked {
+		return false
+	}
+
+	if report.Publishing != nil && report.Publishing.Blocked {
+		return false
+	}
+
+	for _, consumer := range report.Consumers {
+		if consumer.State != listener.Consuming {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,173 @@
+package health_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ar3s3ru/go-carrot/blocking"
+	"github.com/ar3s3ru/go-carrot/health"
+	"github.com/ar3s3ru/go-carrot/listener"
+	"github.com/ar3s3ru/go-carrot/listener/mocks"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+type connection struct {
+	closing  chan *amqp.Error
+	blocking chan amqp.Blocking
+}
+
+func (conn *connection) NotifyClose(ch chan *amqp.Error) chan *amqp.Error {
+	conn.closing = ch
+	return ch
+}
+
+func (conn *connection) NotifyBlocked(ch chan amqp.Blocking) chan amqp.Blocking {
+	conn.blocking = ch
+	return ch
+}
+
+type response struct {
+	Status     string `json:"status"`
+	Connection struct {
+		Open    bool   `json:"open"`
+		Blocked bool   `json:"blocked"`
+		Reason  string `json:"reason"`
+	} `json:"connection"`
+	Publishing struct {
+		Blocked bool   `json:"blocked"`
+		Reason  string `json:"reason"`
+	} `json:"publishing"`
+	Consumers []struct {
+		Name  string `json:"name"`
+		State string `json:"state"`
+	} `json:"consumers"`
+}
+
+func get(t *testing.T, h http.Handler, path string) (int, response) {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+	var res response
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&res))
+
+	return rec.Code, res
+}
+
+func TestMonitor_Handler(t *testing.T) {
+	t.Run("not watching any connection is alive but not ready", func(t *testing.T) {
+		h := health.New().Handler()
+
+		code, res := get(t, h, "/live")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "up", res.Status)
+
+		code, res = get(t, h, "/ready")
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, "down", res.Status)
+	})
+
+	t.Run("open connection with consuming listeners is ready", func(t *testing.T) {
+		reporter := new(mocks.Reporter)
+		reporter.On("Status").Return([]listener.Status{
+			{Name: "queue-1", State: listener.Consuming},
+			{Name: "queue-2", State: listener.Consuming},
+		})
+
+		monitor := health.New()
+		monitor.WatchConnection(new(connection))
+		monitor.WatchListener(reporter)
+
+		code, res := get(t, monitor.Handler(), "/ready")
+		assert.Equal(t, http.StatusOK, code)
+		assert.True(t, res.Connection.Open)
+		assert.Len(t, res.Consumers, 2)
+		assert.Equal(t, "queue-1", res.Consumers[0].Name)
+		assert.Equal(t, "consuming", res.Consumers[0].State)
+	})
+
+	t.Run("cancelled consumer is not ready, but still alive", func(t *testing.T) {
+		reporter := new(mocks.Reporter)
+		reporter.On("Status").Return([]listener.Status{
+			{Name: "queue-1", State: listener.Consuming},
+			{Name: "queue-2", State: listener.Cancelled},
+		})
+
+		monitor := health.New()
+		monitor.WatchConnection(new(connection))
+		monitor.WatchListener(reporter)
+
+		code, _ := get(t, monitor.Handler(), "/ready")
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+
+		code, _ = get(t, monitor.Handler(), "/live")
+		assert.Equal(t, http.StatusOK, code)
+	})
+
+	t.Run("blocked connection is not ready", func(t *testing.T) {
+		conn := new(connection)
+
+		monitor := health.New()
+		monitor.WatchConnection(conn)
+
+		conn.blocking <- amqp.Blocking{Active: true, Reason: "low on memory"}
+
+		assert.Eventually(t, func() bool { return monitor.Report().Connection.Blocked }, time.Second, 10*time.Millisecond)
+
+		code, res := get(t, monitor.Handler(), "/ready")
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.True(t, res.Connection.Blocked)
+		assert.Equal(t, "low on memory", res.Connection.Reason)
+
+		conn.blocking <- amqp.Blocking{Active: false}
+
+		assert.Eventually(t, func() bool { return !monitor.Report().Connection.Blocked }, time.Second, 10*time.Millisecond)
+
+		code, _ = get(t, monitor.Handler(), "/ready")
+		assert.Equal(t, http.StatusOK, code)
+	})
+
+	t.Run("closed connection is not alive", func(t *testing.T) {
+		conn := new(connection)
+
+		monitor := health.New()
+		monitor.WatchConnection(conn)
+
+		conn.closing <- amqp.ErrClosed
+		close(conn.closing)
+
+		assert.Eventually(t, func() bool { return !monitor.Report().Connection.Open }, time.Second, 10*time.Millisecond)
+
+		code, res := get(t, monitor.Handler(), "/live")
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, "down", res.Status)
+		assert.False(t, res.Connection.Open)
+	})
+
+	t.Run("blocked publishing is not ready", func(t *testing.T) {
+		publishing := new(connection)
+		tracker := blocking.Watch(publishing)
+
+		monitor := health.New()
+		monitor.WatchConnection(new(connection))
+		monitor.WatchPublishing(tracker)
+
+		code, res := get(t, monitor.Handler(), "/ready")
+		assert.Equal(t, http.StatusOK, code)
+		assert.False(t, res.Publishing.Blocked)
+
+		publishing.blocking <- amqp.Blocking{Active: true, Reason: "low on disk"}
+
+		assert.Eventually(t, tracker.Blocked, time.Second, 10*time.Millisecond)
+
+		code, res = get(t, monitor.Handler(), "/ready")
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.False(t, res.Connection.Blocked)
+		assert.True(t, res.Publishing.Blocked)
+		assert.Equal(t, "low on disk", res.Publishing.Reason)
+	})
+}
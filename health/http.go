@@ -0,0 +1,57 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Handler returns an http.Handler serving the liveness report on "/live"
+// and the readiness report on "/ready".
+//
+// Use http.StripPrefix to mount the Handler on a different path.
+func (m *Monitor) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/live", m.Liveness())
+	mux.Handle("/ready", m.Readiness())
+
+	return mux
+}
+
+// Liveness returns an http.Handler that responds with the Monitor report
+// and 200 OK if the service is alive, 503 Service Unavailable otherwise.
+func (m *Monitor) Liveness() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := m.Report()
+		writeReport(w, report, report.Live())
+	})
+}
+
+// Readiness returns an http.Handler that responds with the Monitor report
+// and 200 OK if the service is ready, 503 Service Unavailable otherwise.
+func (m *Monitor) Readiness() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := m.Report()
+		writeReport(w, report, report.Ready())
+	})
+}
+
+type response struct {
+	Status string `json:"status"`
+	Report
+}
+
+func writeReport(w http.ResponseWriter, report Report, ok bool) {
+	res := response{Status: "up", Report: report}
+	code := http.StatusOK
+
+	if !ok {
+		res.Status = "down"
+		code = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	// nolint:errcheck
+	json.NewEncoder(w).Encode(res)
+}
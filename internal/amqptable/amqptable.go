@@ -0,0 +1,53 @@
+// Package amqptable converts decoded JSON values into amqp.Table values.
+package amqptable
+
+import (
+	"encoding/json"
+
+	"github.com/streadway/amqp"
+)
+
+// FromJSON returns the amqp.Table of a JSON object decoded using
+// json.Decoder.UseNumber, converting numbers to int64, or float64
+// if they're not integers, and nested objects to amqp.Table.
+//
+// Returns nil if the object is empty.
+func FromJSON(object map[string]interface{}) amqp.Table {
+	if len(object) == 0 {
+		return nil
+	}
+
+	t := make(amqp.Table, len(object))
+	for key, value := range object {
+		t[key] = fromJSONValue(value)
+	}
+
+	return t
+}
+
+func fromJSONValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+
+		f, _ := v.Float64()
+
+		return f
+
+	case map[string]interface{}:
+		return FromJSON(v)
+
+	case []interface{}:
+		values := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			values = append(values, fromJSONValue(item))
+		}
+
+		return values
+
+	default:
+		return v
+	}
+}
@@ -0,0 +1,37 @@
+// Package republish contains helpers to publish received messages again.
+package republish
+
+import "github.com/streadway/amqp"
+
+// Publishing returns a new amqp.Publishing with the same properties, headers
+// and body of the delivery, so that it can be published again.
+//
+// Headers are copied, so that they can be modified without affecting
+// the original delivery.
+func Publishing(delivery amqp.Delivery) amqp.Publishing {
+	var headers amqp.Table
+
+	if delivery.Headers != nil {
+		headers = make(amqp.Table, len(delivery.Headers))
+		for key, value := range delivery.Headers {
+			headers[key] = value
+		}
+	}
+
+	return amqp.Publishing{
+		Headers:         headers,
+		ContentType:     delivery.ContentType,
+		ContentEncoding: delivery.ContentEncoding,
+		DeliveryMode:    delivery.DeliveryMode,
+		Priority:        delivery.Priority,
+		CorrelationId:   delivery.CorrelationId,
+		ReplyTo:         delivery.ReplyTo,
+		Expiration:      delivery.Expiration,
+		MessageId:       delivery.MessageId,
+		Timestamp:       delivery.Timestamp,
+		Type:            delivery.Type,
+		UserId:          delivery.UserId,
+		AppId:           delivery.AppId,
+		Body:            delivery.Body,
+	}
+}
@@ -3,11 +3,14 @@ package consumer
 import (
 	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/ar3s3ru/go-carrot/handler"
 	"github.com/ar3s3ru/go-carrot/listener"
 
 	"github.com/streadway/amqp"
+	"golang.org/x/time/rate"
 )
 
 // Listener allows to listen for incoming messages from a consumer, which
@@ -18,6 +21,7 @@ type Listener struct {
 	server
 
 	queue       string
+	tag         string
 	title       string
 	description string
 
@@ -26,33 +30,164 @@ type Listener struct {
 	noLocal   bool
 	noWait    bool
 	args      amqp.Table
+
+	qos           bool
+	qosGlobal     bool
+	prefetchCount int
+	prefetchSize  int
+
+	throughput float64
+
+	dedicatedChannel bool
 }
 
 // Listen starts listening to incoming messages on the specified queue (or queues)
 // using the provided Connection or Channel, and serving them by using
 // the provided Handler.
 //
+// If any of Prefetch, Priority or Tag options have been specified, the Listener
+// opens a new, dedicated channel from the provided Connection, instead of
+// using the provided Channel.
+//
 // An error is returned if the Listener is unable to start listening
 // on the provided Channel.
 func (l Listener) Listen(conn listener.Connection, ch listener.Channel, h handler.Handler) (listener.Closer, error) {
-	delivery, err := ch.Consume(l.queue, l.queue, l.autoAck, l.exclusive, l.noLocal, l.noWait, l.args)
+	l.server.open = func() (listener.Channel, error) {
+		ch, err := conn.Channel()
+		if err != nil {
+			return nil, err
+		}
+
+		return ch, nil
+	}
+
+	if l.dedicatedChannel {
+		dedicated, err := l.open()
+		if err != nil {
+			return nil, fmt.Errorf("consumer.Listener: failed to open dedicated channel, %w", err)
+		}
+
+		ch = dedicated
+	}
+
+	return l.listen(conn, ch, h)
+}
+
+// listen starts listening on the provided channel, which is set up first
+// if dedicated to the Listener.
+func (l Listener) listen(conn listener.Connection, ch listener.Channel, h handler.Handler) (listener.Closer, error) {
+	if l.dedicatedChannel {
+		if err := l.setQos(ch, l.prefetchCount); err != nil {
+			return nil, err
+		}
+	}
+
+	l.server.ch = ch
+	l.server.ownsChannel = l.dedicatedChannel
+	l.server.prefetch = l.prefetchCount
+	l.server.consume = l.consumeFrom
+	l.server.reopen = l.reopen
+
+	delivery, err := l.startConsuming()
 	if err != nil {
+		if l.dedicatedChannel {
+			// nolint:errcheck
+			ch.Close()
+		}
+
 		return nil, fmt.Errorf("consumer.Listener: failed to start consuming messages, %w", err)
 	}
 
+	l.server.name = l.Tag()
 	l.server.conn = conn
-	l.server.ch = ch
 	l.server.sink = delivery
+	l.server.state = new(atomic.Value)
+	l.server.setState(listener.Consuming)
+	if l.throughput > 0 {
+		l.server.limiter = rate.NewLimiter(rate.Limit(l.throughput), 1)
+		l.server.throttlePrefetch = l.qosGlobal
+	}
+
+	l.server.mx = new(sync.Mutex)
+	l.server.resume = make(chan (<-chan amqp.Delivery), 1)
 	l.server.closeOnce = new(sync.Once)
-	l.server.done = make(chan bool)
+	l.server.reportOnce = new(sync.Once)
+	l.server.stop = make(chan struct{})
+	l.server.done = make(chan struct{})
 	// Needs buffer, in case user of the library doesn't listen to the close channel.
 	l.server.close = make(chan error, 1)
 
+	l.notifyCancel(ch)
+
 	go l.serve(h)
 
 	return &l, nil
 }
 
+// Queue returns the name of the queue the consumer is attached to.
+func (l Listener) Queue() string { return l.queue }
+
+// Tag returns the consumer tag, used by router.Mux to route messages.
+func (l Listener) Tag() string {
+	if l.tag == "" {
+		return l.queue
+	}
+
+	return l.tag
+}
+
+// Title returns the title of the consumer, if any.
+func (l Listener) Title() string { return l.title }
+
+// Description returns the description of the consumer, if any.
+func (l Listener) Description() string { return l.description }
+
+// startConsuming starts consuming from the queue, with the options specified
+// when the Listener was created.
+func (l *Listener) startConsuming() (<-chan amqp.Delivery, error) {
+	return l.consumeFrom(l.ch)
+}
+
+// consumeFrom starts consuming from the queue on the provided channel.
+func (l Listener) consumeFrom(ch listener.Channel) (<-chan amqp.Delivery, error) {
+	return ch.Consume(l.queue, l.Tag(), l.autoAck, l.exclusive, l.noLocal, l.noWait, l.args)
+}
+
+// reopen opens a new channel from the Connection, with the QoS of the Listener,
+// using the prefetch count last set by Throttle, if any.
+func (l *Listener) reopen() (listener.Channel, error) {
+	ch, err := l.open()
+	if err != nil {
+		return nil, fmt.Errorf("consumer.Listener: failed to open channel, %w", err)
+	}
+
+	l.mx.Lock()
+	prefetch := l.prefetch
+	l.mx.Unlock()
+
+	if err := l.setQos(ch, prefetch); err != nil {
+		return nil, err
+	}
+
+	return ch, nil
+}
+
+// setQos sets the QoS of the Listener on the provided channel, if specified,
+// closing the channel on failure.
+func (l Listener) setQos(ch listener.Channel, prefetchCount int) error {
+	if !l.qos {
+		return nil
+	}
+
+	if err := ch.Qos(prefetchCount, l.prefetchSize, l.qosGlobal); err != nil {
+		// nolint:errcheck
+		ch.Close()
+		return fmt.Errorf("consumer.Listener: failed to set channel QoS, %w", err)
+	}
+
+	return nil
+}
+
 func (l *Listener) addToTable(key string, value interface{}) {
 	if l.args == nil {
 		l.args = make(amqp.Table)
@@ -116,6 +251,120 @@ func Arguments(args amqp.Table) Option {
 	}
 }
 
+// Prefetch limits the number of messages (count) or bytes (size) that
+// the AMQP broker delivers to the consumer before receiving acknowledgements.
+// A zero value means no limit.
+//
+// Since QoS is applied per channel, the consumer will use a dedicated channel.
+func Prefetch(count, size int) Option {
+	return func(listener *Listener) {
+		listener.qos = true
+		// Overrides the channel-wide prefetch set by Throughput, if any.
+		listener.qosGlobal = false
+		listener.prefetchCount = count
+		listener.prefetchSize = size
+		listener.dedicatedChannel = true
+	}
+}
+
+// Priority sets the consumer priority, using the "x-priority" consumer argument:
+// messages are delivered to lower priority consumers only when higher priority
+// consumers are blocked.
+//
+// The consumer will use a dedicated channel, so that its prefetch is not shared
+// with other consumers.
+func Priority(priority int) Option {
+	return func(listener *Listener) {
+		listener.addToTable("x-priority", priority)
+		listener.dedicatedChannel = true
+	}
+}
+
+// Tag specifies the consumer tag, which defaults to the queue name.
+//
+// Since router.Mux routes messages using amqp.Delivery.ConsumerTag, handlers
+// for this consumer must be bound to the tag, rather than to the queue name.
+//
+// The consumer will use a dedicated channel.
+func Tag(tag string) Option {
+	return func(listener *Listener) {
+		listener.tag = tag
+		listener.dedicatedChannel = true
+	}
+}
+
+// Batch makes the consumer collect up to size messages, or wait up to the
+// specified duration since the first message of the batch has been received,
+// before calling the provided BatchHandler with the whole batch.
+//
+// When the BatchHandler succeeds, the whole batch is acknowledged with
+// the multiple flag; when it fails, the whole batch is negatively-acknowledged
+// and requeued, unless the error has been wrapped with handler.Reject.
+//
+// Return a *handler.BatchError from the BatchHandler to report failures
+// of single messages: those are handled as in OnError, while all the others
+// are handled as in OnSuccess.
+//
+// The handler.Handler provided to Listen is not used by this consumer.
+//
+// The consumer will use a dedicated channel, and unless the Prefetch option
+// has been specified, a prefetch count equal to the batch size.
+func Batch(h handler.BatchHandler, size int, wait time.Duration) Option {
+	return func(listener *Listener) {
+		listener.batchHandler = h
+		listener.batchSize = size
+		listener.batchWait = wait
+		listener.dedicatedChannel = true
+
+		if !listener.qos {
+			listener.qos = true
+			listener.prefetchCount = size
+		}
+	}
+}
+
+// Throughput caps the number of messages per second handled by the consumer.
+// The limit can be changed while the consumer is running using Throttle.
+//
+// The consumer will use a dedicated channel, and unless the Prefetch option
+// has been specified, a prefetch count equal to the number of messages
+// handled in one second, so that the AMQP broker doesn't pile unacknowledged
+// messages onto a throttled consumer.
+func Throughput(limit float64) Option {
+	return func(listener *Listener) {
+		listener.throughput = limit
+		listener.dedicatedChannel = true
+
+		if !listener.qos {
+			// Per-consumer prefetch can't be changed for running consumers,
+			// hence the channel-wide prefetch is used instead.
+			listener.qos = true
+			listener.qosGlobal = true
+			listener.prefetchCount = throughputPrefetch(limit)
+		}
+	}
+}
+
+// Reconsume makes the consumer attempt to consume from the queue again,
+// up to the specified number of retries, when it gets cancelled by the AMQP
+// broker, e.g. because the queue has been deleted and declared again, or
+// a quorum queue lost its leader.
+//
+// The consumer waits for the specified backoff before the first attempt,
+// doubling it after each failed attempt, up to 30 seconds. Each attempt opens
+// a new channel from the Connection, with the QoS set by Prefetch or
+// Throughput, if any, replacing the channel used so far.
+//
+// If all the attempts fail, or if this option has not been specified,
+// an error wrapping ErrCancelled is reported through the closing channel
+// of the Listener.
+func Reconsume(retries int, backoff time.Duration) Option {
+	return func(listener *Listener) {
+		listener.reconsumeRetries = retries
+		listener.reconsumeBackoff = backoff
+	}
+}
+
 // OnSuccess specifies the callback function to execute when the message handler
 // successfully processed the message (i.e. failed without an error).
 //
@@ -128,7 +377,9 @@ func OnSuccess(fn func(amqp.Delivery)) Option {
 // fails with an error, providing the amqp.Delivery and the error returned
 // by the handler itself.
 //
-// If not specified, the Server will call amqp.Delivery.Nack(false, true).
+// If not specified, the Server will call amqp.Delivery.Nack(false, true),
+// or amqp.Delivery.Nack(false, false) if the error has been wrapped
+// with handler.Reject.
 func OnError(fn func(amqp.Delivery, error)) Option {
 	return func(listener *Listener) { listener.onError = fn }
 }
@@ -1,10 +1,20 @@
 package consumer
 
 import (
+	"context"
+	"errors"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/ar3s3ru/go-carrot/handler"
+	"github.com/ar3s3ru/go-carrot/listener"
+	"github.com/ar3s3ru/go-carrot/listener/mocks"
 
 	"github.com/streadway/amqp"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/time/rate"
 )
 
 func TestListen(t *testing.T) {
@@ -69,6 +79,75 @@ func TestListen(t *testing.T) {
 				},
 			},
 		},
+		"with prefetch uses a dedicated channel": {
+			queue: "queue",
+			options: []Option{
+				Prefetch(10, 0),
+			},
+			output: Listener{
+				queue:            "queue",
+				qos:              true,
+				prefetchCount:    10,
+				dedicatedChannel: true,
+			},
+		},
+		"with priority and tag uses a dedicated channel": {
+			queue: "queue",
+			options: []Option{
+				Priority(5),
+				Tag("consumer-tag"),
+			},
+			output: Listener{
+				queue: "queue",
+				tag:   "consumer-tag",
+				args: amqp.Table{
+					"x-priority": 5,
+				},
+				dedicatedChannel: true,
+			},
+		},
+		"with throughput uses a dedicated channel with channel-wide prefetch": {
+			queue: "queue",
+			options: []Option{
+				Throughput(2.5),
+			},
+			output: Listener{
+				queue:            "queue",
+				qos:              true,
+				qosGlobal:        true,
+				prefetchCount:    3,
+				throughput:       2.5,
+				dedicatedChannel: true,
+			},
+		},
+		"with prefetch after throughput keeps the specified prefetch": {
+			queue: "queue",
+			options: []Option{
+				Throughput(2.5),
+				Prefetch(10, 0),
+			},
+			output: Listener{
+				queue:            "queue",
+				qos:              true,
+				prefetchCount:    10,
+				throughput:       2.5,
+				dedicatedChannel: true,
+			},
+		},
+		"with throughput keeps the specified prefetch": {
+			queue: "queue",
+			options: []Option{
+				Prefetch(10, 0),
+				Throughput(2.5),
+			},
+			output: Listener{
+				queue:            "queue",
+				qos:              true,
+				prefetchCount:    10,
+				throughput:       2.5,
+				dedicatedChannel: true,
+			},
+		},
 	}
 
 	for queue, tc := range testcases {
@@ -76,3 +155,434 @@ func TestListen(t *testing.T) {
 		t.Run(queue, func(t *testing.T) { assert.Equal(t, tc.output, Listen(tc.queue, tc.options...)) })
 	}
 }
+
+func TestListen_DedicatedChannel(t *testing.T) {
+	failure := errors.New("queue not found")
+
+	testcases := map[string]struct {
+		options []Option
+		expect  func(*mocks.Channel)
+		err     error
+	}{
+		"prefetch is set on the dedicated channel": {
+			options: []Option{Prefetch(10, 0)},
+			expect: func(ch *mocks.Channel) {
+				ch.On("Qos", 10, 0, false).Return(nil).Once()
+				ch.
+					On("Consume", "queue", "queue", false, false, false, false, amqp.Table(nil)).
+					Return((<-chan amqp.Delivery)(make(chan amqp.Delivery)), nil)
+			},
+		},
+		"priority is sent as consumer argument": {
+			options: []Option{Priority(5)},
+			expect: func(ch *mocks.Channel) {
+				ch.
+					On("Consume", "queue", "queue", false, false, false, false, amqp.Table{"x-priority": 5}).
+					Return((<-chan amqp.Delivery)(make(chan amqp.Delivery)), nil)
+			},
+		},
+		"tag is used as consumer tag": {
+			options: []Option{Tag("consumer-tag")},
+			expect: func(ch *mocks.Channel) {
+				ch.
+					On("Consume", "queue", "consumer-tag", false, false, false, false, amqp.Table(nil)).
+					Return((<-chan amqp.Delivery)(make(chan amqp.Delivery)), nil)
+			},
+		},
+		"throughput sets channel-wide prefetch": {
+			options: []Option{Throughput(2.5)},
+			expect: func(ch *mocks.Channel) {
+				ch.On("Qos", 3, 0, true).Return(nil).Once()
+				ch.
+					On("Consume", "queue", "queue", false, false, false, false, amqp.Table(nil)).
+					Return((<-chan amqp.Delivery)(make(chan amqp.Delivery)), nil)
+			},
+		},
+		"dedicated channel is closed when failing to consume": {
+			options: []Option{Prefetch(10, 0)},
+			expect: func(ch *mocks.Channel) {
+				ch.On("Qos", 10, 0, false).Return(nil).Once()
+				ch.
+					On("Consume", "queue", "queue", false, false, false, false, amqp.Table(nil)).
+					Return((<-chan amqp.Delivery)(nil), failure)
+				ch.On("Close").Return(nil).Once()
+			},
+			err: failure,
+		},
+		"dedicated channel is closed when failing to set prefetch": {
+			options: []Option{Prefetch(10, 0)},
+			expect: func(ch *mocks.Channel) {
+				ch.On("Qos", 10, 0, false).Return(failure).Once()
+				ch.On("Close").Return(nil).Once()
+			},
+			err: failure,
+		},
+	}
+
+	for name, tc := range testcases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			dedicated := new(mocks.Channel)
+			tc.expect(dedicated)
+
+			closer, err := Listen("queue", tc.options...).listen(nil, dedicated, handler.Func(func(context.Context, amqp.Delivery) error {
+				return nil
+			}))
+
+			if tc.err != nil {
+				assert.True(t, errors.Is(err, tc.err))
+				assert.Nil(t, closer)
+			} else {
+				assert.NoError(t, err)
+				assert.NotNil(t, closer)
+			}
+
+			dedicated.AssertExpectations(t)
+		})
+	}
+
+	t.Run("fails when the dedicated channel can't be opened", func(t *testing.T) {
+		conn := new(mocks.Connection)
+		conn.On("Channel").Return(nil, amqp.ErrClosed).Once()
+
+		// The shared channel must not be used.
+		shared := new(mocks.Channel)
+
+		closer, err := Listen("queue", Prefetch(10, 0)).Listen(conn, shared, handler.Func(func(context.Context, amqp.Delivery) error {
+			return nil
+		}))
+
+		assert.True(t, errors.Is(err, amqp.ErrClosed))
+		assert.Nil(t, closer)
+		conn.AssertExpectations(t)
+		shared.AssertExpectations(t)
+	})
+}
+
+type acknowledger struct {
+	acks     []uint64
+	nacks    []uint64
+	requeued []uint64
+}
+
+func (a *acknowledger) Ack(tag uint64, multiple bool) error {
+	if multiple {
+		tag += 1000
+	}
+
+	a.acks = append(a.acks, tag)
+
+	return nil
+}
+
+func (a *acknowledger) Nack(tag uint64, multiple, requeue bool) error {
+	if multiple {
+		tag += 1000
+	}
+
+	a.nacks = append(a.nacks, tag)
+
+	if requeue {
+		a.requeued = append(a.requeued, tag)
+	}
+
+	return nil
+}
+
+func (a *acknowledger) Reject(uint64, bool) error { return nil }
+
+func TestBatch(t *testing.T) {
+	serveBatches := func(h handler.BatchHandler, deliveries int) (*acknowledger, [][]uint64) {
+		ack := new(acknowledger)
+		sink := make(chan amqp.Delivery)
+
+		var batches [][]uint64
+
+		l := Listen("queue", Batch(handler.BatchFunc(func(ctx context.Context, batch []amqp.Delivery) error {
+			tags := make([]uint64, 0, len(batch))
+			for _, delivery := range batch {
+				tags = append(tags, delivery.DeliveryTag)
+			}
+
+			batches = append(batches, tags)
+
+			return h.Handle(ctx, batch)
+		}), 3, 10*time.Millisecond))
+
+		l.server.sink = sink
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			l.serveBatches()
+		}()
+
+		for tag := 1; tag <= deliveries; tag++ {
+			sink <- amqp.Delivery{Acknowledger: ack, DeliveryTag: uint64(tag)}
+		}
+
+		// Wait for the batch timeout to flush incomplete batches.
+		time.Sleep(50 * time.Millisecond)
+		close(sink)
+		<-done
+
+		return ack, batches
+	}
+
+	t.Run("batch options use a dedicated channel with batch size prefetch", func(t *testing.T) {
+		l := Listen("queue", Batch(handler.BatchFunc(func(context.Context, []amqp.Delivery) error { return nil }), 10, time.Second))
+
+		assert.True(t, l.dedicatedChannel)
+		assert.True(t, l.qos)
+		assert.Equal(t, 10, l.prefetchCount)
+
+		l = Listen("queue",
+			Prefetch(20, 0),
+			Batch(handler.BatchFunc(func(context.Context, []amqp.Delivery) error { return nil }), 10, time.Second),
+		)
+
+		assert.Equal(t, 20, l.prefetchCount)
+	})
+
+	t.Run("successful batches are acknowledged with the multiple flag", func(t *testing.T) {
+		ack, batches := serveBatches(handler.BatchFunc(func(context.Context, []amqp.Delivery) error {
+			return nil
+		}), 4)
+
+		assert.Equal(t, [][]uint64{{1, 2, 3}, {4}}, batches)
+		assert.Equal(t, []uint64{1003, 1004}, ack.acks)
+		assert.Empty(t, ack.nacks)
+	})
+
+	t.Run("failed batches are negatively acknowledged with the multiple flag", func(t *testing.T) {
+		ack, _ := serveBatches(handler.BatchFunc(func(context.Context, []amqp.Delivery) error {
+			return errors.New("failed")
+		}), 3)
+
+		assert.Empty(t, ack.acks)
+		assert.Equal(t, []uint64{1003}, ack.nacks)
+	})
+
+	t.Run("single failures are reported with BatchError", func(t *testing.T) {
+		ack, _ := serveBatches(handler.BatchFunc(func(context.Context, []amqp.Delivery) error {
+			return &handler.BatchError{Failures: map[int]error{1: errors.New("failed")}}
+		}), 3)
+
+		assert.Equal(t, []uint64{1, 3}, ack.acks)
+		assert.Equal(t, []uint64{2}, ack.nacks)
+	})
+}
+
+func TestThrottle(t *testing.T) {
+	ch := new(mocks.Channel)
+	ch.On("Qos", 20, 0, true).Return(nil).Once()
+	ch.On("Qos", 1, 0, true).Return(nil).Once()
+
+	l := Listener{server: server{ch: ch, mx: new(sync.Mutex)}}
+
+	assert.Equal(t, ErrNotThrottled, l.Throttle(10))
+
+	l.limiter = rate.NewLimiter(5, 1)
+	l.throttlePrefetch = true
+
+	assert.Equal(t, ErrInvalidThroughput, l.Throttle(0))
+	assert.NoError(t, l.Throttle(20))
+	assert.NoError(t, l.Throttle(0.5))
+
+	assert.Equal(t, rate.Limit(0.5), l.limiter.Limit())
+	assert.Equal(t, 1, l.prefetch)
+	ch.AssertExpectations(t)
+}
+
+func TestRejectedMessagesAreNotRequeued(t *testing.T) {
+	ack := new(acknowledger)
+	srv := server{}
+
+	srv.handleError(amqp.Delivery{Acknowledger: ack, DeliveryTag: 1}, errors.New("failed"))
+	srv.handleError(amqp.Delivery{Acknowledger: ack, DeliveryTag: 2}, handler.Reject(errors.New("malformed")))
+
+	assert.Equal(t, []uint64{1}, ack.requeued)
+	assert.Equal(t, []uint64{1, 2}, ack.nacks)
+}
+
+// openChannels makes the Listener open the provided channels, in order,
+// when consuming again.
+func openChannels(l listener.Closer, chs ...listener.Channel) {
+	l.(*Listener).open = func() (listener.Channel, error) {
+		ch := chs[0]
+		chs = chs[1:]
+
+		return ch, nil
+	}
+}
+
+func TestListen_Reconsume(t *testing.T) {
+	consume := func(ch *mocks.Channel) *mock.Call {
+		return ch.On("Consume", "test-queue", "test-queue", false, false, false, false, amqp.Table(nil))
+	}
+
+	t.Run("it consumes again on a new channel after a cancellation", func(t *testing.T) {
+		handled := make(chan amqp.Delivery, 1)
+
+		first := make(chan amqp.Delivery)
+		second := make(chan amqp.Delivery)
+		failure := &amqp.Error{Code: amqp.NotFound, Reason: "NOT_FOUND - no queue 'test-queue'"}
+
+		// The AMQP broker closes the channel after failing to consume,
+		// so each attempt must use a new channel, with the prefetch count
+		// last set by Throttle.
+		original, failing, reopened := new(mocks.Channel), new(mocks.Channel), new(mocks.Channel)
+
+		original.On("Qos", 3, 0, true).Return(nil).Once()
+		original.On("Qos", 5, 0, true).Return(nil).Once()
+		consume(original).Return((<-chan amqp.Delivery)(first), nil).Once()
+		consume(original).Return((<-chan amqp.Delivery)(nil), amqp.ErrClosed).Maybe()
+
+		failing.On("Qos", 5, 0, true).Return(nil).Once()
+		consume(failing).Return((<-chan amqp.Delivery)(nil), failure).Once()
+
+		reopened.On("Qos", 5, 0, true).Return(nil).Once()
+		consume(reopened).Return((<-chan amqp.Delivery)(second), nil).Once()
+
+		for _, ch := range []*mocks.Channel{original, failing, reopened} {
+			ch.On("Close").Return(nil).Once()
+		}
+
+		closer, err := Listen("test-queue",
+			Throughput(2.5),
+			Reconsume(2, time.Millisecond),
+			OnSuccess(func(delivery amqp.Delivery) { handled <- delivery }),
+		).listen(nil, original, handler.Func(func(context.Context, amqp.Delivery) error {
+			return nil
+		}))
+
+		assert.NoError(t, err)
+		assert.NoError(t, closer.(listener.Throttler).Throttle(5))
+
+		openChannels(closer, failing, reopened)
+		close(first)
+
+		select {
+		case second <- amqp.Delivery{DeliveryTag: 1}:
+		case <-time.After(1 * time.Second):
+			assert.FailNow(t, "did not consume again after 1 second")
+		}
+
+		assert.Equal(t, uint64(1), (<-handled).DeliveryTag)
+
+		reporter := closer.(listener.Reporter)
+		assert.Equal(t, []listener.Status{{Name: "test-queue", State: listener.Consuming}}, reporter.Status())
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		close(second)
+		assert.NoError(t, closer.Close(ctx))
+
+		for _, ch := range []*mocks.Channel{original, failing, reopened} {
+			ch.AssertExpectations(t)
+		}
+	})
+
+	t.Run("it reports the cancellation when failing to consume again", func(t *testing.T) {
+		sink := make(chan amqp.Delivery)
+		failure := &amqp.Error{Code: amqp.ChannelError, Reason: "CHANNEL_ERROR - no more channels"}
+
+		ch := new(mocks.Channel)
+		consume(ch).Return((<-chan amqp.Delivery)(sink), nil).Once()
+
+		conn := new(mocks.Connection)
+		conn.On("Channel").Return(nil, failure).Times(2)
+
+		closer, err := Listen("test-queue", Reconsume(2, time.Millisecond)).
+			Listen(conn, ch, handler.Func(func(context.Context, amqp.Delivery) error {
+				return nil
+			}))
+
+		assert.NoError(t, err)
+
+		close(sink)
+
+		select {
+		case err := <-closer.Closed():
+			assert.True(t, errors.Is(err, ErrCancelled))
+			assert.Contains(t, err.Error(), failure.Reason)
+		case <-time.After(1 * time.Second):
+			assert.Fail(t, "did not report cancellation after 1 second")
+		}
+
+		ch.AssertExpectations(t)
+		conn.AssertExpectations(t)
+	})
+
+	t.Run("it stops consuming again when the connection is closed", func(t *testing.T) {
+		sink := make(chan amqp.Delivery)
+
+		ch := new(mocks.Channel)
+		consume(ch).Return((<-chan amqp.Delivery)(sink), nil).Once()
+
+		conn := new(mocks.Connection)
+		conn.On("Channel").Return(nil, amqp.ErrClosed).Once()
+
+		closer, err := Listen("test-queue", Reconsume(3, time.Millisecond)).
+			Listen(conn, ch, handler.Func(func(context.Context, amqp.Delivery) error {
+				return nil
+			}))
+
+		assert.NoError(t, err)
+
+		close(sink)
+
+		select {
+		case err := <-closer.Closed():
+			assert.True(t, errors.Is(err, ErrCancelled))
+		case <-time.After(1 * time.Second):
+			assert.Fail(t, "did not report cancellation after 1 second")
+		}
+
+		conn.AssertExpectations(t)
+	})
+
+	t.Run("it cancels the new consumer when closed while consuming again", func(t *testing.T) {
+		sink := make(chan amqp.Delivery)
+		closed := make(chan error, 1)
+
+		ch := new(mocks.Channel)
+		consume(ch).Return((<-chan amqp.Delivery)(sink), nil).Once()
+		ch.On("Close").Return(nil).Once()
+
+		closer, err := Listen("test-queue", Reconsume(1, time.Millisecond)).
+			Listen(nil, ch, handler.Func(func(context.Context, amqp.Delivery) error {
+				return nil
+			}))
+
+		assert.NoError(t, err)
+
+		reopened := new(mocks.Channel)
+		consume(reopened).
+			Run(func(mock.Arguments) {
+				go func() { closed <- closer.Close(context.Background()) }()
+
+				assert.Eventually(t, func() bool {
+					return closer.(listener.Reporter).Status()[0].State == listener.Closing
+				}, time.Second, time.Millisecond)
+			}).
+			Return((<-chan amqp.Delivery)(make(chan amqp.Delivery)), nil).
+			Once()
+		reopened.On("Cancel", "test-queue", false).Return(nil).Once()
+		reopened.On("Close").Return(nil).Once()
+
+		openChannels(closer, reopened)
+		close(sink)
+
+		select {
+		case err := <-closed:
+			assert.NoError(t, err)
+		case <-time.After(1 * time.Second):
+			assert.FailNow(t, "did not close after 1 second")
+		}
+
+		ch.AssertExpectations(t)
+		reopened.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,97 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ar3s3ru/go-carrot/listener"
+)
+
+var (
+	// ErrNotConsuming is returned by the consumer when pausing a Listener
+	// that is not actively consuming messages.
+	ErrNotConsuming = errors.New("consumer.Listener: not consuming")
+
+	// ErrNotPaused is returned by the consumer when resuming a Listener
+	// that has not been paused.
+	ErrNotPaused = errors.New("consumer.Listener: not paused")
+)
+
+// Pause stops receiving new messages, by cancelling the consumer on the broker,
+// and waits until all the messages already received have been handled,
+// or the context is done.
+//
+// The channel used by the consumer stays open, so that in-flight messages
+// can still be acknowledged.
+func (l *Listener) Pause(ctx context.Context) error {
+	l.mx.Lock()
+
+	if l.state.Load() != listener.Consuming {
+		l.mx.Unlock()
+		return ErrNotConsuming
+	}
+
+	drained := make(chan struct{})
+	l.drained = drained
+	l.setState(listener.Paused)
+
+	if err := l.ch.Cancel(l.name, false); err != nil {
+		l.drained = nil
+		l.setState(listener.Consuming)
+		l.mx.Unlock()
+
+		return fmt.Errorf("consumer.Listener: failed to cancel consumer, %w", err)
+	}
+
+	l.mx.Unlock()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("consumer.Listener: failed to wait for in-flight messages, %w", ctx.Err())
+	}
+}
+
+// Resume starts receiving messages again, by consuming from the queue
+// with the same options specified when the Listener was first started.
+//
+// If the Listener is still handling messages received before Pause was called,
+// Resume waits for them to be handled first, or for the context to be done.
+func (l *Listener) Resume(ctx context.Context) error {
+	l.mx.Lock()
+	drained := l.drained
+	paused := l.state.Load() == listener.Paused
+	l.mx.Unlock()
+
+	if !paused {
+		return ErrNotPaused
+	}
+
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		return fmt.Errorf("consumer.Listener: failed to wait for in-flight messages, %w", ctx.Err())
+	}
+
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	// The Listener might have been closed, or resumed by someone else,
+	// while waiting for in-flight messages.
+	if l.state.Load() != listener.Paused {
+		return ErrNotPaused
+	}
+
+	delivery, err := l.startConsuming()
+	if err != nil {
+		return fmt.Errorf("consumer.Listener: failed to resume consuming messages, %w", err)
+	}
+
+	l.drained = nil
+	l.resume <- delivery
+	l.setState(listener.Consuming)
+
+	return nil
+}
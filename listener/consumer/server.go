@@ -5,35 +5,98 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/ar3s3ru/go-carrot/handler"
 	"github.com/ar3s3ru/go-carrot/listener"
 
 	"github.com/streadway/amqp"
+	"golang.org/x/time/rate"
 )
 
-// ErrAlreadyClosed is returned by the consumer when closing
-// a previously-closed Listener.
-var ErrAlreadyClosed = errors.New("consumer.Listener: already closed")
+var (
+	// ErrAlreadyClosed is returned by the consumer when closing
+	// a previously-closed Listener.
+	ErrAlreadyClosed = errors.New("consumer.Listener: already closed")
+
+	// ErrCancelled is reported through the Listener closing channel when
+	// the consumer has been cancelled without closing the Listener,
+	// e.g. by the AMQP broker after the queue has been deleted.
+	ErrCancelled = errors.New("consumer.Listener: consumer cancelled")
+)
+
+// maxReconsumeBackoff caps the time waited between two attempts
+// to consume again from the queue after a cancellation.
+const maxReconsumeBackoff = 30 * time.Second
+
+// cancelNotifier is implemented by channels notifying the consumers
+// cancelled by the AMQP broker, such as *amqp.Channel.
+type cancelNotifier interface {
+	NotifyCancel(chan string) chan string
+}
 
 type server struct {
+	name string
 	conn listener.Connection
 	ch   listener.Channel
 	sink <-chan amqp.Delivery
 
-	closeOnce *sync.Once
-	close     chan error
-	done      chan bool
+	// open opens a new channel from the Connection, and reopen sets it up
+	// with the QoS of the Listener, so that consume starts consuming
+	// from the queue on it, after the consumer has been cancelled.
+	open             func() (listener.Channel, error)
+	reopen           func() (listener.Channel, error)
+	consume          func(listener.Channel) (<-chan amqp.Delivery, error)
+	reconsumeRetries int
+	reconsumeBackoff time.Duration
+
+	// ownsChannel is true if the channel has been opened by the Listener,
+	// rather than shared with other Listeners.
+	ownsChannel bool
+
+	// prefetch is the prefetch count of the channel, changed by Throttle,
+	// and set on the channels opened when consuming again.
+	prefetch int
+
+	state *atomic.Value
+
+	// Pause and Resume synchronization: drained is closed once all
+	// the in-flight messages have been handled after a Pause, while
+	// resume receives the new delivery channel on Resume.
+	mx      *sync.Mutex
+	drained chan struct{}
+	resume  chan (<-chan amqp.Delivery)
+
+	closeOnce  *sync.Once
+	reportOnce *sync.Once
+	close      chan error
+	stop       chan struct{}
+	done       chan struct{}
 
 	onError   func(amqp.Delivery, error)
 	onSuccess func(amqp.Delivery)
+
+	limiter          *rate.Limiter
+	throttlePrefetch bool
+
+	batchHandler handler.BatchHandler
+	batchSize    int
+	batchWait    time.Duration
 }
 
 func (srv *server) Close(ctx context.Context) error {
 	err := ErrAlreadyClosed
 
 	srv.closeOnce.Do(func() {
-		err = srv.ch.Close()
+		// The channel might be replaced while consuming again.
+		srv.mx.Lock()
+		srv.setState(listener.Closing)
+		ch := srv.ch
+		srv.mx.Unlock()
+
+		close(srv.stop)
+		err = ch.Close()
 
 		select {
 		case <-srv.done:
@@ -41,19 +104,215 @@ func (srv *server) Close(ctx context.Context) error {
 			err = fmt.Errorf("consumer.Listener: failed to close server, %w", err)
 		}
 
-		srv.close <- err
-		close(srv.close)
+		srv.setState(listener.Closed)
+		srv.report(err)
 	})
 
 	return err
 }
 
+// report sends the error on the closing channel of the Listener, only once:
+// if the consumer has been cancelled, closing the Listener later
+// doesn't report anything else.
+func (srv *server) report(err error) {
+	srv.reportOnce.Do(func() {
+		srv.close <- err
+		close(srv.close)
+	})
+}
+
 func (srv *server) Closed() <-chan error {
 	return srv.close
 }
 
+// Status reports the current state of the consumer.
+func (srv *server) Status() []listener.Status {
+	return []listener.Status{{
+		Name:  srv.name,
+		State: srv.state.Load().(listener.State),
+	}}
+}
+
+func (srv *server) setState(state listener.State) {
+	srv.state.Store(state)
+}
+
 func (srv *server) serve(h handler.Handler) {
+	// Closed once done, rather than sent to, since nobody is waiting
+	// for it unless the Listener is being closed.
+	defer close(srv.done)
+
+	for serving := true; serving; serving = srv.waitResume() || srv.reconsume() {
+		if srv.batchHandler != nil {
+			srv.serveBatches()
+		} else {
+			srv.serveMessages(h)
+		}
+	}
+}
+
+// notifyCancel watches the cancellations notified by the channel, if supported.
+func (srv *server) notifyCancel(ch listener.Channel) {
+	if notifier, ok := ch.(cancelNotifier); ok {
+		go srv.watchCancel(notifier.NotifyCancel(make(chan string, 1)))
+	}
+}
+
+// watchCancel marks the consumer as cancelled as soon as the AMQP broker
+// notifies it, e.g. because the queue has been deleted, while the delivery
+// channel is closed right after.
+//
+// Notifications must always be received, since the AMQP channel blocks
+// until they are: the notification channel is closed with the AMQP channel.
+func (srv *server) watchCancel(cancels <-chan string) {
+	for tag := range cancels {
+		if tag != srv.name {
+			continue
+		}
+
+		srv.mx.Lock()
+		if srv.state.Load() == listener.Consuming {
+			srv.setState(listener.Cancelled)
+		}
+		srv.mx.Unlock()
+	}
+}
+
+// reconsume is called when the delivery channel has been closed without
+// closing or pausing the consumer first, so the consumer must have been
+// cancelled.
+//
+// If the Reconsume option has been specified, it attempts to consume from
+// the queue again, backing off between attempts. Each attempt uses a new
+// channel, since the AMQP broker closes the channel when failing to consume,
+// e.g. if the queue doesn't exist. Otherwise, or if all the attempts fail,
+// the cancellation is reported through the closing channel.
+//
+// Returns true if a new delivery channel is available to be served.
+func (srv *server) reconsume() bool {
+	srv.mx.Lock()
+
+	switch srv.state.Load() {
+	case listener.Consuming, listener.Cancelled:
+		srv.setState(listener.Cancelled)
+	default:
+		// Closing, or closed, in the meantime.
+		srv.mx.Unlock()
+		return false
+	}
+
+	srv.mx.Unlock()
+
+	if srv.reconsumeRetries <= 0 {
+		srv.report(ErrCancelled)
+		return false
+	}
+
+	backoff := srv.reconsumeBackoff
+
+	var err error
+
+	for attempt := 0; attempt < srv.reconsumeRetries; attempt++ {
+		timer := time.NewTimer(backoff)
+
+		select {
+		case <-timer.C:
+		case <-srv.stop:
+			timer.Stop()
+			return false
+		}
+
+		if backoff *= 2; backoff > maxReconsumeBackoff {
+			backoff = maxReconsumeBackoff
+		}
+
+		var ch listener.Channel
+		if ch, err = srv.reopen(); err != nil {
+			if errors.Is(err, amqp.ErrClosed) {
+				// The connection is closed, so no other attempt can succeed.
+				break
+			}
+
+			continue
+		}
+
+		var sink <-chan amqp.Delivery
+		if sink, err = srv.consume(ch); err != nil {
+			// nolint:errcheck
+			ch.Close()
+			continue
+		}
+
+		return srv.resumeConsuming(ch, sink)
+	}
+
+	srv.report(fmt.Errorf("%w, failed to consume again, %v", ErrCancelled, err))
+
+	return false
+}
+
+// resumeConsuming replaces the channel of the consumer with the new one,
+// closing the previous channel if it was opened by the Listener.
+func (srv *server) resumeConsuming(ch listener.Channel, sink <-chan amqp.Delivery) bool {
+	srv.mx.Lock()
+	defer srv.mx.Unlock()
+
+	// The Listener might have been closed while consuming again.
+	if srv.state.Load() != listener.Cancelled {
+		// nolint:errcheck
+		ch.Cancel(srv.name, false)
+		// nolint:errcheck
+		ch.Close()
+
+		return false
+	}
+
+	if srv.ownsChannel {
+		// nolint:errcheck
+		srv.ch.Close()
+	}
+
+	srv.ch = ch
+	srv.ownsChannel = true
+	srv.sink = sink
+	srv.setState(listener.Consuming)
+	srv.notifyCancel(ch)
+
+	return true
+}
+
+// waitResume is called when the delivery channel has been closed, and blocks
+// until the consumer is resumed, if it has been paused, or closed.
+//
+// Returns true if the consumer has been resumed and a new delivery channel
+// is available to be served.
+func (srv *server) waitResume() bool {
+	srv.mx.Lock()
+
+	paused := srv.state.Load() == listener.Paused
+	if srv.drained != nil {
+		close(srv.drained)
+	}
+
+	srv.mx.Unlock()
+
+	if !paused {
+		return false
+	}
+
+	select {
+	case sink := <-srv.resume:
+		srv.sink = sink
+		return true
+	case <-srv.stop:
+		return false
+	}
+}
+
+func (srv *server) serveMessages(h handler.Handler) {
 	for delivery := range srv.sink {
+		srv.throttle()
+
 		switch err := h.Handle(context.Background(), delivery); err {
 		case nil:
 			srv.handleSuccess(delivery)
@@ -61,9 +320,105 @@ func (srv *server) serve(h handler.Handler) {
 			srv.handleError(delivery, err)
 		}
 	}
+}
+
+func (srv *server) serveBatches() {
+	batch := make([]amqp.Delivery, 0, srv.batchSize)
+
+	// Timeout channel is nil, hence blocking, until the first message
+	// of a new batch is received.
+	var timeout <-chan time.Time
+
+	flush := func() {
+		if len(batch) > 0 {
+			srv.handleBatch(batch)
+		}
+
+		batch = make([]amqp.Delivery, 0, srv.batchSize)
+		timeout = nil
+	}
+
+	for {
+		select {
+		case delivery, ok := <-srv.sink:
+			if !ok {
+				flush()
+				return
+			}
+
+			srv.throttle()
+			batch = append(batch, delivery)
+
+			if len(batch) == 1 {
+				timeout = time.After(srv.batchWait)
+			}
+
+			if len(batch) >= srv.batchSize {
+				flush()
+			}
+
+		case <-timeout:
+			flush()
+		}
+	}
+}
+
+// throttle waits until the consumer is allowed to handle another message,
+// if a maximum throughput has been specified.
+func (srv *server) throttle() {
+	if srv.limiter != nil {
+		// nolint:errcheck
+		srv.limiter.Wait(context.Background())
+	}
+}
+
+func (srv *server) handleBatch(batch []amqp.Delivery) {
+	err := srv.batchHandler.Handle(context.Background(), batch)
+
+	var batchErr *handler.BatchError
+
+	switch {
+	case err == nil:
+		srv.handleBatchSuccess(batch)
+
+	case errors.As(err, &batchErr):
+		for i, delivery := range batch {
+			if failure, failed := batchErr.Failures[i]; failed {
+				srv.handleError(delivery, failure)
+			} else {
+				srv.handleSuccess(delivery)
+			}
+		}
+
+	default:
+		srv.handleBatchError(batch, err)
+	}
+}
+
+// nolint:errcheck
+func (srv *server) handleBatchSuccess(batch []amqp.Delivery) {
+	if srv.onSuccess == nil {
+		// Acknowledges all the messages in the batch, since the channel
+		// is dedicated to this consumer.
+		batch[len(batch)-1].Ack(true)
+		return
+	}
 
-	srv.done <- true
-	close(srv.done)
+	for _, delivery := range batch {
+		srv.onSuccess(delivery)
+	}
+}
+
+// nolint:errcheck
+func (srv *server) handleBatchError(batch []amqp.Delivery, err error) {
+	if srv.onError == nil {
+		batch[len(batch)-1].Nack(true, !handler.IsRejected(err))
+		return
+	}
+
+	for _, delivery := range batch {
+		srv.onError(delivery, err)
+	}
 }
 
 // nolint:errcheck
@@ -71,7 +426,7 @@ func (srv *server) handleError(delivery amqp.Delivery, err error) {
 	if srv.onError != nil {
 		srv.onError(delivery, err)
 	} else {
-		delivery.Nack(false, true)
+		delivery.Nack(false, !handler.IsRejected(err))
 	}
 }
 
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/ar3s3ru/go-carrot/handler"
+	"github.com/ar3s3ru/go-carrot/listener"
 	"github.com/ar3s3ru/go-carrot/listener/consumer"
 	"github.com/ar3s3ru/go-carrot/listener/mocks"
 
@@ -18,7 +19,7 @@ import (
 
 func TestListener_Server(t *testing.T) {
 	t.Run("it closes the channel successfully", func(t *testing.T) {
-		listener := consumer.Listen(
+		l := consumer.Listen(
 			"test-queue",
 			consumer.OnSuccess(func(amqp.Delivery) {
 				assert.Fail(t, "called success callback")
@@ -40,13 +41,17 @@ func TestListener_Server(t *testing.T) {
 			Run(func(args mock.Arguments) { sinkCloser.Do(func() { close(sink) }) }).
 			Return(nil)
 
-		closer, err := listener.Listen(nil, ch, handler.Func(func(context.Context, amqp.Delivery) error {
+		closer, err := l.Listen(nil, ch, handler.Func(func(context.Context, amqp.Delivery) error {
 			return errors.New("should not be called")
 		}))
 
 		assert.NoError(t, err)
 		assert.NotNil(t, closer)
 
+		reporter, ok := closer.(listener.Reporter)
+		assert.True(t, ok)
+		assert.Equal(t, []listener.Status{{Name: "test-queue", State: listener.Consuming}}, reporter.Status())
+
 		go func() {
 			assert.NoError(t, closer.Close(context.Background()))
 		}()
@@ -58,6 +63,8 @@ func TestListener_Server(t *testing.T) {
 			assert.Fail(t, "did not finish after 1 second")
 		}
 
+		assert.Equal(t, []listener.Status{{Name: "test-queue", State: listener.Closed}}, reporter.Status())
+
 		// Closing a second time will return an error
 		assert.Equal(t, consumer.ErrAlreadyClosed, closer.Close(context.Background()))
 	})
@@ -164,4 +171,184 @@ func TestListener_Server(t *testing.T) {
 			assert.Fail(t, "did not finish after 1 second")
 		}
 	})
+
+	t.Run("it pauses and resumes consumption", func(t *testing.T) {
+		handled := make(chan amqp.Delivery, 2)
+
+		first := make(chan amqp.Delivery, 1)
+		second := make(chan amqp.Delivery, 1)
+		var secondCloser sync.Once
+
+		ch := new(mocks.Channel)
+		ch.
+			On("Consume", "test-queue", "test-queue", false, false, false, false, amqp.Table(nil)).
+			Return((<-chan amqp.Delivery)(first), nil).
+			Once()
+		ch.
+			On("Consume", "test-queue", "test-queue", false, false, false, false, amqp.Table(nil)).
+			Return((<-chan amqp.Delivery)(second), nil).
+			Once()
+		ch.
+			On("Cancel", "test-queue", false).
+			Run(func(args mock.Arguments) { close(first) }).
+			Return(nil)
+		ch.
+			On("Close").
+			Run(func(args mock.Arguments) { secondCloser.Do(func() { close(second) }) }).
+			Return(nil)
+
+		closer, err := consumer.Listen("test-queue", consumer.OnSuccess(func(delivery amqp.Delivery) {
+			handled <- delivery
+		})).Listen(nil, ch, handler.Func(func(context.Context, amqp.Delivery) error {
+			return nil
+		}))
+
+		assert.NoError(t, err)
+
+		pauser, ok := closer.(listener.Pauser)
+		assert.True(t, ok)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		// Messages already received before pausing are handled.
+		first <- amqp.Delivery{DeliveryTag: 1}
+		assert.NoError(t, pauser.Pause(ctx))
+		assert.Equal(t, uint64(1), (<-handled).DeliveryTag)
+
+		reporter := closer.(listener.Reporter)
+		assert.Equal(t, []listener.Status{{Name: "test-queue", State: listener.Paused}}, reporter.Status())
+
+		assert.Equal(t, consumer.ErrNotConsuming, pauser.Pause(ctx))
+		assert.NoError(t, pauser.Resume(ctx))
+		assert.Equal(t, consumer.ErrNotPaused, pauser.Resume(ctx))
+		assert.Equal(t, []listener.Status{{Name: "test-queue", State: listener.Consuming}}, reporter.Status())
+
+		second <- amqp.Delivery{DeliveryTag: 2}
+		assert.Equal(t, uint64(2), (<-handled).DeliveryTag)
+
+		assert.NoError(t, closer.Close(ctx))
+		ch.AssertExpectations(t)
+	})
+
+	t.Run("it closes a paused consumer", func(t *testing.T) {
+		sink := make(chan amqp.Delivery)
+
+		ch := new(mocks.Channel)
+		ch.
+			On("Consume", "test-queue", "test-queue", false, false, false, false, amqp.Table(nil)).
+			Return((<-chan amqp.Delivery)(sink), nil)
+		ch.
+			On("Cancel", "test-queue", false).
+			Run(func(args mock.Arguments) { close(sink) }).
+			Return(nil)
+		ch.On("Close").Return(nil)
+
+		closer, err := consumer.Listen("test-queue").Listen(nil, ch, handler.Func(func(context.Context, amqp.Delivery) error {
+			return nil
+		}))
+
+		assert.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		assert.NoError(t, closer.(listener.Pauser).Pause(ctx))
+		assert.NoError(t, closer.Close(ctx))
+		assert.Equal(t, consumer.ErrNotPaused, closer.(listener.Pauser).Resume(ctx))
+	})
+
+	t.Run("it reports the cancellation through the closing channel", func(t *testing.T) {
+		sink := make(chan amqp.Delivery)
+
+		ch := &cancellingChannel{Channel: new(mocks.Channel)}
+		ch.
+			On("Consume", "test-queue", "test-queue", false, false, false, false, amqp.Table(nil)).
+			Return((<-chan amqp.Delivery)(sink), nil)
+		ch.On("Close").Return(nil)
+
+		closer, err := consumer.Listen("test-queue").Listen(nil, ch, handler.Func(func(context.Context, amqp.Delivery) error {
+			return nil
+		}))
+
+		assert.NoError(t, err)
+
+		// Notifications for other consumers on the same channel are ignored.
+		ch.cancels <- "other-queue"
+		ch.cancels <- "test-queue"
+		close(sink)
+
+		select {
+		case err := <-closer.Closed():
+			assert.True(t, errors.Is(err, consumer.ErrCancelled))
+		case <-time.After(1 * time.Second):
+			assert.Fail(t, "did not report cancellation after 1 second")
+		}
+
+		reporter := closer.(listener.Reporter)
+		assert.Equal(t, []listener.Status{{Name: "test-queue", State: listener.Cancelled}}, reporter.Status())
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		// Closing a cancelled consumer doesn't block.
+		assert.NoError(t, closer.Close(ctx))
+		assert.Equal(t, []listener.Status{{Name: "test-queue", State: listener.Closed}}, reporter.Status())
+	})
+}
+
+func TestListener_Sink(t *testing.T) {
+	t.Run("it reports the cancellation of a consumer in a sink", func(t *testing.T) {
+		orders := make(chan amqp.Delivery)
+		payments := make(chan amqp.Delivery)
+
+		ch := new(mocks.Channel)
+		ch.On("Qos", 2, 0, false).Return(nil).Once()
+		ch.
+			On("Consume", "orders", "orders", false, false, false, false, amqp.Table(nil)).
+			Return((<-chan amqp.Delivery)(orders), nil).
+			Once()
+		ch.
+			On("Consume", "payments", "payments", false, false, false, false, amqp.Table(nil)).
+			Return((<-chan amqp.Delivery)(payments), nil).
+			Once()
+		ch.On("Close").Return(nil)
+
+		closer, err := listener.Sink(
+			consumer.Listen("orders"),
+			consumer.Listen("payments"),
+		).Listen(nil, ch, handler.Func(func(context.Context, amqp.Delivery) error {
+			return nil
+		}))
+
+		assert.NoError(t, err)
+
+		close(orders)
+
+		select {
+		case err := <-closer.Closed():
+			assert.True(t, errors.Is(err, consumer.ErrCancelled))
+		case <-time.After(1 * time.Second):
+			assert.FailNow(t, "did not report cancellation after 1 second")
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		close(payments)
+		assert.NoError(t, closer.Close(ctx))
+		ch.AssertExpectations(t)
+	})
+}
+
+// cancellingChannel is a channel notifying the consumers cancelled
+// by the AMQP broker.
+type cancellingChannel struct {
+	*mocks.Channel
+	cancels chan string
+}
+
+func (ch *cancellingChannel) NotifyCancel(cancels chan string) chan string {
+	ch.cancels = cancels
+	return cancels
 }
@@ -0,0 +1,67 @@
+package consumer
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"golang.org/x/time/rate"
+)
+
+var (
+	// ErrNotThrottled is returned by the consumer when changing the throughput
+	// of a Listener started without the Throughput option.
+	ErrNotThrottled = errors.New("consumer.Listener: throughput not specified")
+
+	// ErrInvalidThroughput is returned by the consumer when changing
+	// the throughput of a Listener to a non-positive value.
+	ErrInvalidThroughput = errors.New("consumer.Listener: throughput must be positive")
+)
+
+// Throttle changes the maximum number of messages per second handled by
+// the consumer, previously specified with the Throughput option.
+//
+// Unless the Prefetch option has been specified, the prefetch count of the
+// consumer channel is changed accordingly, also for the channels opened
+// when consuming again, with the Reconsume option.
+//
+// Use Pause to stop receiving messages altogether.
+func (l *Listener) Throttle(limit float64) error {
+	if l.limiter == nil {
+		return ErrNotThrottled
+	}
+
+	if limit <= 0 {
+		return ErrInvalidThroughput
+	}
+
+	l.limiter.SetLimit(rate.Limit(limit))
+
+	if !l.throttlePrefetch {
+		return nil
+	}
+
+	// The channel might be replaced while consuming again.
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	prefetch := throughputPrefetch(limit)
+
+	if err := l.ch.Qos(prefetch, 0, true); err != nil {
+		return fmt.Errorf("consumer.Listener: failed to set channel QoS, %w", err)
+	}
+
+	l.prefetch = prefetch
+
+	return nil
+}
+
+// throughputPrefetch returns the number of messages handled in one second
+// with the specified throughput.
+func throughputPrefetch(limit float64) int {
+	if limit < 1 {
+		return 1
+	}
+
+	return int(math.Ceil(limit))
+}
@@ -13,12 +13,39 @@ func UseDedicatedChannel(listener Listener) Listener {
 		return nil
 	}
 
-	return Func(func(conn Connection, _ Channel, h handler.Handler) (Closer, error) {
-		ch, err := conn.Channel()
-		if err != nil {
-			return nil, fmt.Errorf("listener.UseDedicatedChannel: failed to open new channel, %w", err)
-		}
-
-		return listener.Listen(conn, ch, h)
-	})
+	return dedicated{listener: listener}
+}
+
+type dedicated struct {
+	listener Listener
+}
+
+// Listeners returns the wrapped Listener.
+func (d dedicated) Listeners() []Listener { return []Listener{d.listener} }
+
+func (d dedicated) Listen(conn Connection, _ Channel, h handler.Handler) (Closer, error) {
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("listener.UseDedicatedChannel: failed to open new channel, %w", err)
+	}
+
+	// Connections might return no channel, e.g. mocks.Connection.
+	if ch == nil {
+		return d.listener.Listen(conn, ch, h)
+	}
+
+	return d.listen(conn, ch, h)
+}
+
+// listen starts the wrapped Listener on the dedicated channel, closing it
+// if the Listener fails to start.
+func (d dedicated) listen(conn Connection, ch Channel, h handler.Handler) (Closer, error) {
+	closer, err := d.listener.Listen(conn, ch, h)
+	if err != nil {
+		// nolint:errcheck
+		ch.Close()
+		return nil, err
+	}
+
+	return closer, nil
 }
@@ -0,0 +1,57 @@
+package listener
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ar3s3ru/go-carrot/handler"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+// channel records whether it has been closed.
+type channel struct {
+	closed bool
+}
+
+func (ch *channel) Close() error {
+	ch.closed = true
+	return nil
+}
+
+func (ch *channel) Qos(int, int, bool) error  { return nil }
+func (ch *channel) Cancel(string, bool) error { return nil }
+
+func (ch *channel) Consume(string, string, bool, bool, bool, bool, amqp.Table) (<-chan amqp.Delivery, error) {
+	return nil, nil
+}
+
+func TestUseDedicatedChannel(t *testing.T) {
+	failure := errors.New("queue not found")
+
+	t.Run("closes the channel when the listener fails", func(t *testing.T) {
+		ch := new(channel)
+
+		d := UseDedicatedChannel(Func(func(Connection, Channel, handler.Handler) (Closer, error) {
+			return nil, failure
+		})).(dedicated)
+
+		closer, err := d.listen(nil, ch, nil)
+		assert.Equal(t, failure, err)
+		assert.Nil(t, closer)
+		assert.True(t, ch.closed)
+	})
+
+	t.Run("keeps the channel open when the listener starts", func(t *testing.T) {
+		ch := new(channel)
+
+		d := UseDedicatedChannel(Func(func(Connection, Channel, handler.Handler) (Closer, error) {
+			return nil, nil
+		})).(dedicated)
+
+		_, err := d.listen(nil, ch, nil)
+		assert.NoError(t, err)
+		assert.False(t, ch.closed)
+	})
+}
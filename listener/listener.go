@@ -2,6 +2,7 @@ package listener
 
 import (
 	"context"
+	"errors"
 	"io"
 
 	"github.com/ar3s3ru/go-carrot/handler"
@@ -24,6 +25,7 @@ type Channel interface {
 
 	Qos(prefetchCount, prefetchSize int, global bool) error
 	Consume(queue, name string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error)
+	Cancel(consumer string, noWait bool) error
 }
 
 // Closer is used to stop listening from a specific Listener.
@@ -32,6 +34,27 @@ type Closer interface {
 	Closed() <-chan error
 }
 
+// ErrNotPausable is returned when attempting to pause or resume a Closer
+// that doesn't implement the Pauser interface.
+var ErrNotPausable = errors.New("listener: closer cannot be paused")
+
+// Pauser is implemented by those Closers able to temporarily stop receiving
+// messages, without closing the Listener.
+type Pauser interface {
+	// Pause stops receiving new messages, and waits until all the messages
+	// already received have been handled or the context is done.
+	Pause(context.Context) error
+
+	// Resume starts receiving messages again, after Pause has been called.
+	Resume(context.Context) error
+}
+
+// Throttler is implemented by those Closers able to change the maximum
+// number of messages per second handled, while running.
+type Throttler interface {
+	Throttle(limit float64) error
+}
+
 // Listener listens for incoming messages using an amqp.Connection or
 // amqp.Channel provided, and calls the specified message handler
 // to handle all incoming messages.
@@ -18,6 +18,11 @@ var ErrSinkAlreadyClosed = errors.New("listener.Sink: already closed")
 // Sink allows for listening from multiple Listeners, by maintaining
 // an amqp.Delivery sink to which all the messages are sent.
 //
+// The first error reported by any of the Listeners through its closing
+// channel, e.g. by a consumer cancelled by the AMQP broker, is reported
+// through the closing channel of the Sink, while the other Listeners keep
+// running until the Sink is closed.
+//
 // Returns nil if no listeners are supplied.
 func Sink(listeners ...Listener) Listener {
 	if len(listeners) == 0 {
@@ -27,6 +32,7 @@ func Sink(listeners ...Listener) Listener {
 	sinker := new(sinker)
 	sinker.listeners = listeners
 	sinker.sink = make(chan error, 1)
+	sinker.done = make(chan struct{})
 
 	return sinker
 }
@@ -34,12 +40,17 @@ func Sink(listeners ...Listener) Listener {
 type sinker struct {
 	sync.WaitGroup
 
-	listeners []Listener
-	closers   []Closer
-	sink      chan error
-	closeOnce sync.Once
+	listeners  []Listener
+	closers    []Closer
+	sink       chan error
+	done       chan struct{}
+	closeOnce  sync.Once
+	reportOnce sync.Once
 }
 
+// Listeners returns the Listeners in the sink.
+func (sinker *sinker) Listeners() []Listener { return sinker.listeners }
+
 func (sinker *sinker) Listen(conn Connection, ch Channel, h handler.Handler) (Closer, error) {
 	if err := sinker.collectClosers(conn, ch, h); err != nil {
 		return nil, err
@@ -66,9 +77,36 @@ func (sinker *sinker) collectClosers(conn Connection, ch Channel, h handler.Hand
 
 	sinker.closers = closers
 
+	for _, closer := range closers {
+		if closed := closer.Closed(); closed != nil {
+			go sinker.watch(closed)
+		}
+	}
+
 	return nil
 }
 
+// watch reports the error sent by a Listener through its closing channel,
+// until the Sink is closed.
+func (sinker *sinker) watch(closed <-chan error) {
+	select {
+	case err, ok := <-closed:
+		if ok && err != nil {
+			sinker.report(err)
+		}
+	case <-sinker.done:
+	}
+}
+
+// report sends the error on the closing channel of the Sink, only once.
+func (sinker *sinker) report(err error) {
+	sinker.reportOnce.Do(func() {
+		sinker.sink <- err
+		close(sinker.sink)
+		close(sinker.done)
+	})
+}
+
 func (sinker *sinker) Close(ctx context.Context) error {
 	err := ErrSinkAlreadyClosed
 
@@ -81,9 +119,7 @@ func (sinker *sinker) Close(ctx context.Context) error {
 		}
 
 		err = g.Wait()
-
-		sinker.sink <- err
-		close(sinker.sink)
+		sinker.report(err)
 	})
 
 	return err
@@ -92,3 +128,52 @@ func (sinker *sinker) Close(ctx context.Context) error {
 func (sinker *sinker) Closed() <-chan error {
 	return sinker.sink
 }
+
+func (sinker *sinker) Status() []Status {
+	var status []Status
+
+	for _, closer := range sinker.closers {
+		if reporter, ok := closer.(Reporter); ok {
+			status = append(status, reporter.Status()...)
+		}
+	}
+
+	return status
+}
+
+// Pause pauses all the Listeners in the sink, waiting for all of them
+// to finish handling their in-flight messages.
+//
+// Returns ErrNotPausable if any of the Listeners cannot be paused.
+func (sinker *sinker) Pause(ctx context.Context) error {
+	return sinker.eachPauser(ctx, Pauser.Pause)
+}
+
+// Resume resumes all the Listeners in the sink.
+//
+// Returns ErrNotPausable if any of the Listeners cannot be resumed.
+func (sinker *sinker) Resume(ctx context.Context) error {
+	return sinker.eachPauser(ctx, Pauser.Resume)
+}
+
+func (sinker *sinker) eachPauser(ctx context.Context, fn func(Pauser, context.Context) error) error {
+	pausers := make([]Pauser, 0, len(sinker.closers))
+
+	for _, closer := range sinker.closers {
+		pauser, ok := closer.(Pauser)
+		if !ok {
+			return ErrNotPausable
+		}
+
+		pausers = append(pausers, pauser)
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+
+	for _, pauser := range pausers {
+		pauser := pauser
+		g.Go(func() error { return fn(pauser, ctx) })
+	}
+
+	return g.Wait()
+}
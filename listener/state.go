@@ -0,0 +1,35 @@
+package listener
+
+// State represents the current state of a consumer controlled by a Listener.
+type State string
+
+// Supported consumer states.
+const (
+	// Consuming means the consumer is actively receiving messages from the broker.
+	Consuming State = "consuming"
+	// Paused means the consumer has been temporarily stopped from receiving
+	// messages, and can be resumed.
+	Paused State = "paused"
+	// Cancelled means the consumer has stopped receiving messages
+	// without being closed, e.g. because the broker cancelled it.
+	Cancelled State = "cancelled"
+	// Closing means the consumer is being closed and it's waiting
+	// for in-flight messages to be handled.
+	Closing State = "closing"
+	// Closed means the consumer has been closed.
+	Closed State = "closed"
+)
+
+func (state State) String() string { return string(state) }
+
+// Status describes the State of a single consumer, identified by its name.
+type Status struct {
+	Name  string
+	State State
+}
+
+// Reporter is implemented by those Closers able to report the state
+// of the consumers they control.
+type Reporter interface {
+	Status() []Status
+}
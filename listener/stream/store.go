@@ -0,0 +1,176 @@
+package stream
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// OffsetStore persists the offset of the last message processed by a stream
+// consumer, identified by its name.
+type OffsetStore interface {
+	// Load returns the last saved offset for the consumer, if any.
+	Load(ctx context.Context, name string) (offset int64, ok bool, err error)
+
+	// Save saves the offset of the last processed message for the consumer.
+	Save(ctx context.Context, name string, offset int64) error
+}
+
+// MemoryStore is an in-memory OffsetStore, useful for testing or for consumers
+// that don't need to survive restarts.
+type MemoryStore struct {
+	mx      sync.RWMutex
+	offsets map[string]int64
+}
+
+// NewMemoryStore returns a new, empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{offsets: make(map[string]int64)}
+}
+
+// Load returns the last saved offset for the consumer, if any.
+func (store *MemoryStore) Load(_ context.Context, name string) (int64, bool, error) {
+	store.mx.RLock()
+	defer store.mx.RUnlock()
+
+	offset, ok := store.offsets[name]
+
+	return offset, ok, nil
+}
+
+// Save saves the offset of the last processed message for the consumer.
+func (store *MemoryStore) Save(_ context.Context, name string, offset int64) error {
+	store.mx.Lock()
+	defer store.mx.Unlock()
+
+	store.offsets[name] = offset
+
+	return nil
+}
+
+// FileStore is an OffsetStore that saves offsets on the filesystem,
+// using one file per consumer in the specified directory.
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore returns a new FileStore, saving offsets in the specified directory.
+func NewFileStore(dir string) *FileStore {
+	return &FileStore{dir: dir}
+}
+
+func (store *FileStore) path(name string) string {
+	return filepath.Join(store.dir, filename(name)+".offset")
+}
+
+// filename escapes path separators from consumer names.
+func filename(name string) string {
+	return strings.NewReplacer("/", "_", string(filepath.Separator), "_").Replace(name)
+}
+
+// Load returns the last saved offset for the consumer, if any.
+func (store *FileStore) Load(_ context.Context, name string) (int64, bool, error) {
+	data, err := ioutil.ReadFile(store.path(name))
+	if os.IsNotExist(err) {
+		return 0, false, nil
+	}
+
+	if err != nil {
+		return 0, false, fmt.Errorf("stream.FileStore: failed to read offset file, %w", err)
+	}
+
+	offset, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("stream.FileStore: invalid offset file, %w", err)
+	}
+
+	return offset, true, nil
+}
+
+// Save saves the offset of the last processed message for the consumer.
+//
+// The offset file is replaced atomically, so that a crash while saving
+// never leaves a corrupted file behind.
+func (store *FileStore) Save(_ context.Context, name string, offset int64) error {
+	tmp, err := ioutil.TempFile(store.dir, filename(name)+".offset.*")
+	if err != nil {
+		return fmt.Errorf("stream.FileStore: failed to create offset file, %w", err)
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(strconv.FormatInt(offset, 10)); err != nil {
+		tmp.Close()
+		return fmt.Errorf("stream.FileStore: failed to write offset file, %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("stream.FileStore: failed to write offset file, %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), store.path(name)); err != nil {
+		return fmt.Errorf("stream.FileStore: failed to replace offset file, %w", err)
+	}
+
+	return nil
+}
+
+// SQLStore is an OffsetStore backed by a database table.
+//
+// Queries use "$N" placeholders and "ON CONFLICT" upserts, supported by both
+// PostgreSQL and SQLite.
+type SQLStore struct {
+	db    *sql.DB
+	table string
+}
+
+// NewSQLStore returns a new SQLStore, using the specified database table.
+func NewSQLStore(db *sql.DB, table string) *SQLStore {
+	return &SQLStore{db: db, table: table}
+}
+
+// CreateTable creates the offsets table, if it doesn't exist already.
+func (store *SQLStore) CreateTable(ctx context.Context) error {
+	_, err := store.db.ExecContext(ctx, fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s (name TEXT PRIMARY KEY, stream_offset BIGINT NOT NULL)",
+		store.table,
+	))
+
+	return err
+}
+
+// Load returns the last saved offset for the consumer, if any.
+func (store *SQLStore) Load(ctx context.Context, name string) (int64, bool, error) {
+	var offset int64
+
+	err := store.db.
+		QueryRowContext(ctx, fmt.Sprintf("SELECT stream_offset FROM %s WHERE name = $1", store.table), name).
+		Scan(&offset)
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return 0, false, nil
+	case err != nil:
+		return 0, false, err
+	default:
+		return offset, true, nil
+	}
+}
+
+// Save saves the offset of the last processed message for the consumer.
+func (store *SQLStore) Save(ctx context.Context, name string, offset int64) error {
+	_, err := store.db.ExecContext(ctx, fmt.Sprintf(
+		"INSERT INTO %s (name, stream_offset) VALUES ($1, $2) "+
+			"ON CONFLICT (name) DO UPDATE SET stream_offset = excluded.stream_offset",
+		store.table,
+	), name, offset)
+
+	return err
+}
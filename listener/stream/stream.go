@@ -0,0 +1,205 @@
+// Package stream adds a listener.Listener able to consume messages
+// from RabbitMQ Streams, keeping track of the last processed offset.
+//
+// For more information about streams,
+// please visit https://www.rabbitmq.com/streams.html.
+package stream
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ar3s3ru/go-carrot/handler"
+	"github.com/ar3s3ru/go-carrot/listener"
+	"github.com/ar3s3ru/go-carrot/listener/consumer"
+
+	"github.com/streadway/amqp"
+)
+
+// DefaultPrefetch is the prefetch count used by the Listener in case
+// no overriding value is specified with the Prefetch option.
+//
+// Stream consumers require a prefetch count to be set.
+const DefaultPrefetch = 100
+
+// Offset specifies where to start consuming messages from a stream.
+type Offset struct {
+	value interface{}
+}
+
+// Supported special offsets.
+var (
+	// First starts consuming from the first message available in the stream.
+	First = Offset{value: "first"}
+	// Last starts consuming from the last chunk of messages stored in the stream.
+	Last = Offset{value: "last"}
+	// Next starts consuming from the next message published in the stream.
+	Next = Offset{value: "next"}
+)
+
+// At starts consuming from the message at the specified offset.
+func At(offset int64) Offset {
+	return Offset{value: offset}
+}
+
+// Timestamp starts consuming from the messages published after
+// the specified point in time.
+func Timestamp(t time.Time) Offset {
+	return Offset{value: t}
+}
+
+// Listener allows to listen for incoming messages from a stream queue.
+//
+// Use Listen function to create a new Listener instance.
+type Listener struct {
+	queue    string
+	name     string
+	start    Offset
+	prefetch int
+
+	store   OffsetStore
+	onError func(amqp.Delivery, error)
+}
+
+// Listen starts consuming from the stream, resuming from the offset following
+// the one saved in the OffsetStore, if any, or from the starting offset specified
+// with the StartFrom option otherwise.
+//
+// The Listener uses a dedicated channel, with the configured prefetch count.
+// Every message successfully handled is acknowledged, and its offset is saved
+// in the OffsetStore.
+func (l Listener) Listen(conn listener.Connection, ch listener.Channel, h handler.Handler) (listener.Closer, error) {
+	start, err := l.startOffset(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	return consumer.Listen(l.queue,
+		consumer.Tag(l.name),
+		consumer.Prefetch(l.prefetch, 0),
+		consumer.Arguments(amqp.Table{"x-stream-offset": start.value}),
+		consumer.OnSuccess(l.handleSuccess),
+		consumer.OnError(l.handleError),
+	).Listen(conn, ch, h)
+}
+
+func (l Listener) startOffset(ctx context.Context) (Offset, error) {
+	if l.store == nil {
+		return l.start, nil
+	}
+
+	offset, ok, err := l.store.Load(ctx, l.name)
+	if err != nil {
+		return Offset{}, fmt.Errorf("stream.Listener: failed to load offset, %w", err)
+	}
+
+	if !ok {
+		return l.start, nil
+	}
+
+	// Resume from the message following the last processed one.
+	return At(offset + 1), nil
+}
+
+// nolint:errcheck
+func (l Listener) handleSuccess(delivery amqp.Delivery) {
+	delivery.Ack(false)
+
+	if l.store == nil {
+		return
+	}
+
+	offset, ok := DeliveryOffset(delivery)
+	if !ok {
+		return
+	}
+
+	if err := l.store.Save(context.Background(), l.name, offset); err != nil && l.onError != nil {
+		l.onError(delivery, fmt.Errorf("stream.Listener: failed to save offset, %w", err))
+	}
+}
+
+// nolint:errcheck
+func (l Listener) handleError(delivery amqp.Delivery, err error) {
+	// Streams don't support requeueing messages: acknowledge the message
+	// to keep receiving new ones, without saving its offset.
+	delivery.Ack(false)
+
+	if l.onError != nil {
+		l.onError(delivery, err)
+	}
+}
+
+// DeliveryOffset returns the stream offset of the delivery, as found
+// in the "x-stream-offset" header.
+func DeliveryOffset(delivery amqp.Delivery) (int64, bool) {
+	switch offset := delivery.Headers["x-stream-offset"].(type) {
+	case int64:
+		return offset, true
+	case int32:
+		return int64(offset), true
+	case int:
+		return int64(offset), true
+	default:
+		return 0, false
+	}
+}
+
+// Listen returns a new Listener able to consume messages from the specified
+// stream queue.
+func Listen(queue string, options ...Option) Listener {
+	listener := Listener{
+		queue:    queue,
+		name:     queue,
+		start:    Next,
+		prefetch: DefaultPrefetch,
+	}
+
+	for _, option := range options {
+		if option == nil {
+			continue
+		}
+
+		option(&listener)
+	}
+
+	return listener
+}
+
+// Option is an optional functionality that can be added to the Listener
+// that is being initialized by the Listen factory method.
+type Option func(*Listener)
+
+// Name specifies the consumer name, used both as consumer tag and as key
+// in the OffsetStore. If not specified, the queue name is used.
+func Name(name string) Option {
+	return func(listener *Listener) { listener.name = name }
+}
+
+// StartFrom specifies the offset to start consuming from, when no offset
+// has been saved in the OffsetStore. If not specified, Next is used.
+func StartFrom(offset Offset) Option {
+	return func(listener *Listener) { listener.start = offset }
+}
+
+// Prefetch specifies the prefetch count of the consumer.
+// If not specified, DefaultPrefetch is used.
+func Prefetch(count int) Option {
+	return func(listener *Listener) { listener.prefetch = count }
+}
+
+// Store specifies the OffsetStore used to save the offset of the last
+// processed message, so that consumption resumes from there on restart.
+func Store(store OffsetStore) Option {
+	return func(listener *Listener) { listener.store = store }
+}
+
+// OnError specifies the callback function to execute when the message handler
+// fails with an error, or the offset of the message could not be saved.
+//
+// Failed messages are always acknowledged, since streams don't support
+// requeueing messages.
+func OnError(fn func(amqp.Delivery, error)) Option {
+	return func(listener *Listener) { listener.onError = fn }
+}
@@ -0,0 +1,167 @@
+package stream
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type acknowledger struct {
+	acked []uint64
+}
+
+func (a *acknowledger) Ack(tag uint64, _ bool) error {
+	a.acked = append(a.acked, tag)
+	return nil
+}
+
+func (a *acknowledger) Nack(uint64, bool, bool) error { return errors.New("not supported") }
+func (a *acknowledger) Reject(uint64, bool) error     { return errors.New("not supported") }
+
+func TestListener_startOffset(t *testing.T) {
+	ctx := context.Background()
+	since := time.Date(2020, time.May, 1, 0, 0, 0, 0, time.UTC)
+
+	testcases := map[string]struct {
+		listener Listener
+		expected Offset
+	}{
+		"defaults to next": {
+			listener: Listen("stream"),
+			expected: Next,
+		},
+		"starts from the specified offset without store": {
+			listener: Listen("stream", StartFrom(Timestamp(since))),
+			expected: Timestamp(since),
+		},
+		"starts from the specified offset if nothing has been stored": {
+			listener: Listen("stream", StartFrom(First), Store(NewMemoryStore())),
+			expected: First,
+		},
+		"resumes from the message following the stored offset": {
+			listener: Listen("stream", StartFrom(First), Store(func() OffsetStore {
+				store := NewMemoryStore()
+				require.NoError(t, store.Save(ctx, "stream", 41))
+				return store
+			}())),
+			expected: At(42),
+		},
+	}
+
+	for name, tc := range testcases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			offset, err := tc.listener.startOffset(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, offset)
+		})
+	}
+}
+
+func TestListener_handle(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	ack := new(acknowledger)
+
+	var failures []error
+
+	l := Listen("stream", Name("my-consumer"), Store(store), OnError(func(_ amqp.Delivery, err error) {
+		failures = append(failures, err)
+	}))
+
+	l.handleSuccess(amqp.Delivery{
+		Acknowledger: ack,
+		DeliveryTag:  1,
+		Headers:      amqp.Table{"x-stream-offset": int64(10)},
+	})
+
+	offset, ok, err := store.Load(ctx, "my-consumer")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int64(10), offset)
+
+	// Failed messages are acknowledged, but their offset is not saved.
+	l.handleError(amqp.Delivery{
+		Acknowledger: ack,
+		DeliveryTag:  2,
+		Headers:      amqp.Table{"x-stream-offset": int64(11)},
+	}, errors.New("failed"))
+
+	offset, _, _ = store.Load(ctx, "my-consumer")
+	assert.Equal(t, int64(10), offset)
+	assert.Equal(t, []uint64{1, 2}, ack.acked)
+	assert.Len(t, failures, 1)
+}
+
+func testStore(t *testing.T, store OffsetStore) {
+	ctx := context.Background()
+
+	_, ok, err := store.Load(ctx, "consumer")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	assert.NoError(t, store.Save(ctx, "consumer", 10))
+	assert.NoError(t, store.Save(ctx, "consumer", 20))
+	assert.NoError(t, store.Save(ctx, "other/consumer", 5))
+
+	offset, ok, err := store.Load(ctx, "consumer")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int64(20), offset)
+
+	offset, ok, err = store.Load(ctx, "other/consumer")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int64(5), offset)
+}
+
+func TestOffsetStore(t *testing.T) {
+	t.Run("memory", func(t *testing.T) {
+		testStore(t, NewMemoryStore())
+	})
+
+	t.Run("file", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "carrot-stream")
+		require.NoError(t, err)
+
+		defer os.RemoveAll(dir)
+
+		testStore(t, NewFileStore(dir))
+	})
+
+	t.Run("file in a missing directory", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "carrot-stream")
+		require.NoError(t, err)
+
+		defer os.RemoveAll(dir)
+
+		store := NewFileStore(filepath.Join(dir, "missing"))
+
+		err = store.Save(context.Background(), "orders", 1)
+		assert.True(t, errors.Is(err, os.ErrNotExist))
+		assert.Contains(t, err.Error(), "stream.FileStore")
+	})
+
+	t.Run("sql", func(t *testing.T) {
+		db, err := sql.Open("sqlite3", ":memory:")
+		require.NoError(t, err)
+
+		db.SetMaxOpenConns(1)
+		defer db.Close()
+
+		store := NewSQLStore(db, "stream_offsets")
+		require.NoError(t, store.CreateTable(context.Background()))
+
+		testStore(t, store)
+	})
+}
@@ -0,0 +1,22 @@
+package listener
+
+// Walk calls the provided function for the Listener and, recursively,
+// for all the Listeners it wraps, such as the ones passed to Sink.
+//
+// Listeners wrapping other Listeners expose them with a Listeners method.
+func Walk(listener Listener, fn func(Listener)) {
+	if listener == nil {
+		return
+	}
+
+	fn(listener)
+
+	parent, ok := listener.(interface{ Listeners() []Listener })
+	if !ok {
+		return
+	}
+
+	for _, child := range parent.Listeners() {
+		Walk(child, fn)
+	}
+}
@@ -0,0 +1,98 @@
+package outbox
+
+import "fmt"
+
+// Dialect describes the SQL dialect used by the Outbox to access
+// the outbox table.
+type Dialect struct {
+	schema       string
+	placeholders func(int) string
+	lock         string
+}
+
+// Postgres is the Dialect for PostgreSQL databases.
+//
+// Pending messages are locked using "FOR UPDATE SKIP LOCKED", so that multiple
+// relays, running on different instances, can publish messages concurrently.
+var Postgres = Dialect{
+	schema: `CREATE TABLE IF NOT EXISTS %[1]s (
+	id          BIGSERIAL PRIMARY KEY,
+	exchange    TEXT NOT NULL,
+	routing_key TEXT NOT NULL,
+	properties  TEXT NOT NULL,
+	body        BYTEA NOT NULL,
+	created_at  TIMESTAMPTZ NOT NULL,
+	sent_at     TIMESTAMPTZ,
+	attempts    INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS %[1]s_pending_idx ON %[1]s (attempts, id) WHERE sent_at IS NULL;`,
+	placeholders: func(i int) string { return fmt.Sprintf("$%d", i) },
+	lock:         " FOR UPDATE SKIP LOCKED",
+}
+
+// SQLite is the Dialect for SQLite databases.
+//
+// SQLite does not support row locking, but it serializes all write transactions:
+// multiple relays can run on the same database, although only one of them
+// will be able to publish messages at any given time.
+var SQLite = Dialect{
+	schema: `CREATE TABLE IF NOT EXISTS %[1]s (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	exchange    TEXT NOT NULL,
+	routing_key TEXT NOT NULL,
+	properties  TEXT NOT NULL,
+	body        BLOB NOT NULL,
+	created_at  TIMESTAMP NOT NULL,
+	sent_at     TIMESTAMP,
+	attempts    INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS %[1]s_pending_idx ON %[1]s (sent_at, attempts, id);`,
+	placeholders: func(int) string { return "?" },
+}
+
+// Schema returns the statements needed to create the outbox table
+// with the specified name.
+func (d Dialect) Schema(table string) string {
+	return fmt.Sprintf(d.schema, table)
+}
+
+func (d Dialect) insert(table string) string {
+	return fmt.Sprintf(
+		"INSERT INTO %s (exchange, routing_key, properties, body, created_at) VALUES (%s, %s, %s, %s, %s)",
+		table,
+		d.placeholders(1), d.placeholders(2), d.placeholders(3), d.placeholders(4), d.placeholders(5),
+	)
+}
+
+// selectPending selects the pending messages, starting from the ones
+// that failed to be published the least number of times, so that messages
+// failing repeatedly don't hold back the others.
+//
+// Messages that failed maxAttempts times are not selected, unless
+// maxAttempts is zero.
+func (d Dialect) selectPending(table string, maxAttempts int) string {
+	var where string
+	if maxAttempts > 0 {
+		where = fmt.Sprintf(" AND attempts < %d", maxAttempts)
+	}
+
+	return fmt.Sprintf(
+		"SELECT id, exchange, routing_key, properties, body FROM %s WHERE sent_at IS NULL%s ORDER BY attempts, id LIMIT %s%s",
+		table,
+		where,
+		d.placeholders(1),
+		d.lock,
+	)
+}
+
+func (d Dialect) markSent(table string) string {
+	return fmt.Sprintf(
+		"UPDATE %s SET sent_at = %s WHERE id = %s",
+		table,
+		d.placeholders(1), d.placeholders(2),
+	)
+}
+
+func (d Dialect) markFailed(table string) string {
+	return fmt.Sprintf("UPDATE %s SET attempts = attempts + 1 WHERE id = %s", table, d.placeholders(1))
+}
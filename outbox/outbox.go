@@ -0,0 +1,166 @@
+// Package outbox implements the Transactional Outbox pattern on top of
+// database/sql, making sure a database write and the messages resulting from it
+// are either both persisted or both discarded.
+//
+// Messages are enqueued in the outbox table inside the current database
+// transaction, and a relay goroutine, started and stopped by carrot.Runner
+// using carrot.WithWorker, publishes pending messages with publisher confirms.
+//
+// Messages are delivered at-least-once: if the relay fails to mark a message
+// as sent after publishing it, the message will be published again.
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ar3s3ru/go-carrot/handler/router/middleware"
+
+	"github.com/streadway/amqp"
+)
+
+// ErrNoTransaction is returned by Outbox.Publish when no transaction
+// has been found in the context.
+var ErrNoTransaction = errors.New("outbox: no transaction found in context")
+
+// Default values used by the Outbox, in case no overriding options are specified.
+const (
+	DefaultTable     = "carrot_outbox"
+	DefaultInterval  = 1 * time.Second
+	DefaultBatchSize = 100
+)
+
+// Outbox stores outgoing messages in a database table, and relays them
+// to the AMQP broker in the background.
+//
+// Use New function to create a new Outbox instance.
+type Outbox struct {
+	db      *sql.DB
+	dialect Dialect
+
+	table       string
+	interval    time.Duration
+	batchSize   int
+	maxAttempts int
+	onError     func(error)
+}
+
+// New returns a new Outbox instance, using the specified database and SQL dialect.
+func New(db *sql.DB, dialect Dialect, options ...Option) *Outbox {
+	outbox := &Outbox{
+		db:        db,
+		dialect:   dialect,
+		table:     DefaultTable,
+		interval:  DefaultInterval,
+		batchSize: DefaultBatchSize,
+	}
+
+	for _, option := range options {
+		if option == nil {
+			continue
+		}
+
+		option(outbox)
+	}
+
+	return outbox
+}
+
+// CreateTable creates the outbox table, if it doesn't exist already.
+func (o *Outbox) CreateTable(ctx context.Context) error {
+	if _, err := o.db.ExecContext(ctx, o.dialect.Schema(o.table)); err != nil {
+		return fmt.Errorf("outbox: failed to create table, %w", err)
+	}
+
+	return nil
+}
+
+// Enqueue stores the message in the outbox table, using the provided transaction.
+//
+// The message will be published on the specified exchange and routing key
+// by the relay only after the transaction has been committed.
+func (o *Outbox) Enqueue(ctx context.Context, tx *sql.Tx, exchange, key string, msg amqp.Publishing) error {
+	props, err := marshalProperties(msg)
+	if err != nil {
+		return fmt.Errorf("outbox: failed to marshal message properties, %w", err)
+	}
+
+	body := msg.Body
+	if body == nil {
+		body = []byte{}
+	}
+
+	_, err = tx.ExecContext(ctx, o.dialect.insert(o.table), exchange, key, props, body, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("outbox: failed to enqueue message, %w", err)
+	}
+
+	return nil
+}
+
+// Publish enqueues the message in the outbox table, using the transaction
+// opened by middleware.SessionPerRequest for the message being handled.
+//
+// ErrNoTransaction is returned if no transaction has been found in the context.
+func (o *Outbox) Publish(ctx context.Context, exchange, key string, msg amqp.Publishing) error {
+	tx, ok := middleware.Transaction(ctx)
+	if !ok {
+		return ErrNoTransaction
+	}
+
+	return o.Enqueue(ctx, tx, exchange, key, msg)
+}
+
+// Option is an optional functionality that can be added to the Outbox
+// that is being initialized by the New factory method.
+type Option func(*Outbox)
+
+// Table specifies the name of the outbox table.
+//
+// If not specified, DefaultTable is used.
+func Table(name string) Option {
+	return func(outbox *Outbox) { outbox.table = name }
+}
+
+// Interval specifies how often the relay looks for pending messages.
+//
+// If not specified, DefaultInterval is used.
+func Interval(interval time.Duration) Option {
+	return func(outbox *Outbox) { outbox.interval = interval }
+}
+
+// BatchSize specifies the maximum number of pending messages the relay
+// publishes in a single database transaction.
+//
+// If not specified, DefaultBatchSize is used.
+func BatchSize(size int) Option {
+	return func(outbox *Outbox) { outbox.batchSize = size }
+}
+
+// MaxAttempts specifies the number of times the relay attempts to publish
+// a message before giving up on it: such messages stay in the outbox table,
+// with the attempts column equal to max, and can be published again
+// by resetting the column to zero.
+//
+// Only failures caused by the message itself count as attempts: messages
+// nacked or returned by the AMQP broker, or whose properties can't be
+// unmarshaled. Failures caused by the broker being unavailable don't.
+//
+// If not specified, or zero, messages are attempted until published.
+// Either way, messages failing to be published are attempted again only
+// after the other pending messages, so that they don't hold them back.
+func MaxAttempts(max int) Option {
+	return func(outbox *Outbox) { outbox.maxAttempts = max }
+}
+
+// OnError specifies the callback function to execute when the relay fails
+// to publish pending messages.
+//
+// If not specified, errors are ignored and publishing is retried
+// at the next interval.
+func OnError(fn func(error)) Option {
+	return func(outbox *Outbox) { outbox.onError = fn }
+}
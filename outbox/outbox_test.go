@@ -0,0 +1,297 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ar3s3ru/go-carrot/handler"
+	"github.com/ar3s3ru/go-carrot/handler/router/middleware"
+	"github.com/ar3s3ru/go-carrot/publisher"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type published struct {
+	exchange   string
+	routingKey string
+	msg        amqp.Publishing
+}
+
+type fakePublisher struct {
+	mx        sync.Mutex
+	published []published
+	failAfter int
+	down      bool
+	poison    string
+}
+
+func (p *fakePublisher) Publish(_ context.Context, exchange, key string, msg amqp.Publishing) error {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	if p.down || (p.failAfter > 0 && len(p.published) >= p.failAfter) {
+		return errors.New("broker unavailable")
+	}
+
+	if p.poison != "" && string(msg.Body) == p.poison {
+		return publisher.ErrNacked
+	}
+
+	p.published = append(p.published, published{exchange: exchange, routingKey: key, msg: msg})
+
+	return nil
+}
+
+func (p *fakePublisher) messages() []published {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	return append([]published(nil), p.published...)
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
+func newOutbox(t *testing.T, options ...Option) (*Outbox, *sql.DB) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+
+	// Every new connection opens a different in-memory database.
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	outbox := New(db, SQLite, options...)
+	require.NoError(t, outbox.CreateTable(context.Background()))
+
+	return outbox, db
+}
+
+func countPending(t *testing.T, db *sql.DB) int {
+	var count int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM carrot_outbox WHERE sent_at IS NULL").Scan(&count))
+
+	return count
+}
+
+func TestOutbox_Publish(t *testing.T) {
+	t.Run("fails without a transaction in the context", func(t *testing.T) {
+		outbox, _ := newOutbox(t)
+
+		err := outbox.Publish(context.Background(), "exchange", "key", amqp.Publishing{})
+		assert.True(t, errors.Is(err, ErrNoTransaction))
+	})
+
+	t.Run("messages are stored only if the handler succeeds", func(t *testing.T) {
+		outbox, db := newOutbox(t)
+
+		h := middleware.SessionPerRequest(db)(handler.Func(func(ctx context.Context, delivery amqp.Delivery) error {
+			if err := outbox.Publish(ctx, "orders", "order.created", amqp.Publishing{Body: delivery.Body}); err != nil {
+				return err
+			}
+
+			if delivery.Redelivered {
+				return errors.New("failed")
+			}
+
+			return nil
+		}))
+
+		assert.NoError(t, h.Handle(context.Background(), amqp.Delivery{Body: []byte("first")}))
+		assert.Error(t, h.Handle(context.Background(), amqp.Delivery{Body: []byte("second"), Redelivered: true}))
+
+		assert.Equal(t, 1, countPending(t, db))
+	})
+}
+
+func TestOutbox_Relay(t *testing.T) {
+	t.Run("pending messages are published and marked as sent", func(t *testing.T) {
+		outbox, db := newOutbox(t, BatchSize(2))
+		ctx := context.Background()
+
+		tx, err := db.Begin()
+		require.NoError(t, err)
+
+		for _, body := range []string{"1", "2", "3"} {
+			require.NoError(t, outbox.Enqueue(ctx, tx, "orders", "order.created", amqp.Publishing{
+				MessageId: body,
+				Headers: amqp.Table{
+					"tenant":  "carrot",
+					"retries": int32(3),
+					"ratio":   0.5,
+					"nested":  amqp.Table{"key": "value"},
+				},
+				Body: []byte(body),
+			}))
+		}
+
+		require.NoError(t, tx.Commit())
+
+		pub := new(fakePublisher)
+
+		n, err := outbox.relay(ctx, pub)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, n)
+		assert.Equal(t, 1, countPending(t, db))
+
+		n, err = outbox.relay(ctx, pub)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, n)
+		assert.Equal(t, 0, countPending(t, db))
+
+		messages := pub.messages()
+		assert.Len(t, messages, 3)
+
+		for i, message := range messages {
+			assert.Equal(t, "orders", message.exchange)
+			assert.Equal(t, "order.created", message.routingKey)
+			assert.Equal(t, messages[i].msg.MessageId, string(message.msg.Body))
+			assert.Equal(t, amqp.Table{
+				"tenant":  "carrot",
+				"retries": int64(3),
+				"ratio":   0.5,
+				"nested":  amqp.Table{"key": "value"},
+			}, message.msg.Headers)
+		}
+	})
+
+	t.Run("messages published before a failure are not published again", func(t *testing.T) {
+		outbox, db := newOutbox(t)
+		ctx := context.Background()
+
+		tx, err := db.Begin()
+		require.NoError(t, err)
+
+		for _, body := range []string{"1", "2", "3"} {
+			require.NoError(t, outbox.Enqueue(ctx, tx, "orders", "order.created", amqp.Publishing{Body: []byte(body)}))
+		}
+
+		require.NoError(t, tx.Commit())
+
+		n, err := outbox.relay(ctx, &fakePublisher{failAfter: 2})
+		assert.Error(t, err)
+		assert.Equal(t, 2, n)
+		assert.Equal(t, 1, countPending(t, db))
+	})
+
+	t.Run("messages failing to be published don't hold back the others", func(t *testing.T) {
+		outbox, db := newOutbox(t, MaxAttempts(2))
+		ctx := context.Background()
+
+		tx, err := db.Begin()
+		require.NoError(t, err)
+
+		for _, body := range []string{"1", "2", "3"} {
+			require.NoError(t, outbox.Enqueue(ctx, tx, "orders", "order.created", amqp.Publishing{Body: []byte(body)}))
+		}
+
+		require.NoError(t, tx.Commit())
+
+		pub := &fakePublisher{poison: "1"}
+
+		n, err := outbox.relay(ctx, pub)
+		assert.Error(t, err)
+		assert.Equal(t, 0, n)
+
+		// The failed message is attempted after the others.
+		n, err = outbox.relay(ctx, pub)
+		assert.Error(t, err)
+		assert.Equal(t, 2, n)
+		assert.Equal(t, 1, countPending(t, db))
+
+		// The failed message is given up after the maximum number of attempts.
+		n, err = outbox.relay(ctx, pub)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, n)
+
+		var attempts int
+		require.NoError(t, db.QueryRow("SELECT attempts FROM carrot_outbox WHERE sent_at IS NULL").Scan(&attempts))
+		assert.Equal(t, 2, attempts)
+	})
+
+	t.Run("messages failing to be unmarshaled don't hold back the others", func(t *testing.T) {
+		outbox, db := newOutbox(t)
+		ctx := context.Background()
+
+		_, err := db.Exec(
+			"INSERT INTO carrot_outbox (exchange, routing_key, properties, body, created_at) VALUES (?, ?, ?, ?, ?)",
+			"orders", "order.created", "{corrupt", []byte("1"), time.Now().UTC(),
+		)
+		require.NoError(t, err)
+
+		tx, err := db.Begin()
+		require.NoError(t, err)
+		require.NoError(t, outbox.Enqueue(ctx, tx, "orders", "order.created", amqp.Publishing{Body: []byte("2")}))
+		require.NoError(t, tx.Commit())
+
+		pub := new(fakePublisher)
+
+		_, err = outbox.relay(ctx, pub)
+		assert.Error(t, err)
+
+		n, err := outbox.relay(ctx, pub)
+		assert.Error(t, err)
+		assert.Equal(t, 1, n)
+		assert.Equal(t, 1, countPending(t, db))
+	})
+
+	t.Run("broker failures don't count as attempts", func(t *testing.T) {
+		outbox, db := newOutbox(t, MaxAttempts(1))
+		ctx := context.Background()
+
+		tx, err := db.Begin()
+		require.NoError(t, err)
+		require.NoError(t, outbox.Enqueue(ctx, tx, "orders", "order.created", amqp.Publishing{Body: []byte("1")}))
+		require.NoError(t, tx.Commit())
+
+		_, err = outbox.relay(ctx, &fakePublisher{down: true})
+		assert.Error(t, err)
+
+		n, err := outbox.relay(ctx, new(fakePublisher))
+		assert.NoError(t, err)
+		assert.Equal(t, 1, n)
+	})
+
+	t.Run("relay goroutine publishes in background until closed", func(t *testing.T) {
+		var (
+			mx   sync.Mutex
+			errs []error
+		)
+
+		outbox, db := newOutbox(t,
+			Interval(10*time.Millisecond),
+			OnError(func(err error) {
+				mx.Lock()
+				defer mx.Unlock()
+
+				errs = append(errs, err)
+			}),
+		)
+
+		tx, err := db.Begin()
+		require.NoError(t, err)
+		require.NoError(t, outbox.Enqueue(context.Background(), tx, "orders", "order.created", amqp.Publishing{}))
+		require.NoError(t, tx.Commit())
+
+		pub := new(fakePublisher)
+		closer := outbox.startRelay(nopCloser{}, pub)
+
+		assert.Eventually(t, func() bool { return len(pub.messages()) == 1 }, time.Second, 10*time.Millisecond)
+
+		assert.NoError(t, closer.Close(context.Background()))
+		assert.NoError(t, <-closer.Closed())
+		assert.Equal(t, ErrRelayAlreadyClosed, closer.Close(context.Background()))
+
+		mx.Lock()
+		defer mx.Unlock()
+
+		assert.Empty(t, errs)
+	})
+}
@@ -0,0 +1,83 @@
+package outbox
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	"github.com/ar3s3ru/go-carrot/internal/amqptable"
+
+	"github.com/streadway/amqp"
+)
+
+// properties contains all the amqp.Publishing fields, except for the body,
+// stored in the outbox table as JSON.
+//
+// Header values are restored as amqptable.FromJSON does: numbers, strings,
+// booleans, arrays and nested tables are preserved, while byte slices
+// and timestamps are restored as strings.
+type properties struct {
+	Headers         amqp.Table `json:"headers,omitempty"`
+	ContentType     string     `json:"content_type,omitempty"`
+	ContentEncoding string     `json:"content_encoding,omitempty"`
+	DeliveryMode    uint8      `json:"delivery_mode,omitempty"`
+	Priority        uint8      `json:"priority,omitempty"`
+	CorrelationID   string     `json:"correlation_id,omitempty"`
+	ReplyTo         string     `json:"reply_to,omitempty"`
+	Expiration      string     `json:"expiration,omitempty"`
+	MessageID       string     `json:"message_id,omitempty"`
+	Timestamp       time.Time  `json:"timestamp,omitempty"`
+	Type            string     `json:"type,omitempty"`
+	UserID          string     `json:"user_id,omitempty"`
+	AppID           string     `json:"app_id,omitempty"`
+}
+
+func marshalProperties(msg amqp.Publishing) (string, error) {
+	data, err := json.Marshal(properties{
+		Headers:         msg.Headers,
+		ContentType:     msg.ContentType,
+		ContentEncoding: msg.ContentEncoding,
+		DeliveryMode:    msg.DeliveryMode,
+		Priority:        msg.Priority,
+		CorrelationID:   msg.CorrelationId,
+		ReplyTo:         msg.ReplyTo,
+		Expiration:      msg.Expiration,
+		MessageID:       msg.MessageId,
+		Timestamp:       msg.Timestamp,
+		Type:            msg.Type,
+		UserID:          msg.UserId,
+		AppID:           msg.AppId,
+	})
+
+	return string(data), err
+}
+
+func unmarshalPublishing(data string, body []byte) (amqp.Publishing, error) {
+	var props properties
+
+	// Numbers are decoded as json.Number, so that integer headers
+	// are not turned into float64 values.
+	decoder := json.NewDecoder(bytes.NewReader([]byte(data)))
+	decoder.UseNumber()
+
+	if err := decoder.Decode(&props); err != nil {
+		return amqp.Publishing{}, err
+	}
+
+	return amqp.Publishing{
+		Headers:         amqptable.FromJSON(props.Headers),
+		ContentType:     props.ContentType,
+		ContentEncoding: props.ContentEncoding,
+		DeliveryMode:    props.DeliveryMode,
+		Priority:        props.Priority,
+		CorrelationId:   props.CorrelationID,
+		ReplyTo:         props.ReplyTo,
+		Expiration:      props.Expiration,
+		MessageId:       props.MessageID,
+		Timestamp:       props.Timestamp,
+		Type:            props.Type,
+		UserId:          props.UserID,
+		AppId:           props.AppID,
+		Body:            body,
+	}, nil
+}
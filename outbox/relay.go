@@ -0,0 +1,240 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/ar3s3ru/go-carrot/listener"
+	"github.com/ar3s3ru/go-carrot/publisher"
+)
+
+// ErrRelayAlreadyClosed is returned by the relay Closer when closing
+// a previously-closed relay.
+var ErrRelayAlreadyClosed = errors.New("outbox: relay already closed")
+
+// Publisher is the component the relay uses to publish pending messages.
+type Publisher = publisher.Interface
+
+// Start opens a new channel from the provided connection and starts
+// the relay goroutine, which publishes pending messages with publisher
+// confirms every configured interval.
+//
+// Start implements the carrot.Worker interface.
+func (o *Outbox) Start(conn listener.Connection) (listener.Closer, error) {
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("outbox: failed to open relay channel, %w", err)
+	}
+
+	pub, err := publisher.New(ch, publisher.Confirm)
+	if err != nil {
+		ch.Close()
+		return nil, fmt.Errorf("outbox: failed to create relay publisher, %w", err)
+	}
+
+	return o.startRelay(ch, pub), nil
+}
+
+func (o *Outbox) startRelay(ch io.Closer, pub Publisher) *relay {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	r := &relay{
+		outbox:    o,
+		ch:        ch,
+		publisher: pub,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+		// Needs buffer, in case user of the library doesn't listen to the close channel.
+		close: make(chan error, 1),
+	}
+
+	go r.run(ctx)
+
+	return r
+}
+
+type relay struct {
+	outbox    *Outbox
+	ch        io.Closer
+	publisher Publisher
+
+	cancel    context.CancelFunc
+	done      chan struct{}
+	closeOnce sync.Once
+	close     chan error
+}
+
+func (r *relay) run(ctx context.Context) {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.outbox.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.relayAll(ctx)
+		}
+	}
+}
+
+// relayAll publishes pending messages in batches, until there are no more
+// pending messages or an error occurs.
+func (r *relay) relayAll(ctx context.Context) {
+	for {
+		n, err := r.outbox.relay(ctx, r.publisher)
+		if err != nil && ctx.Err() == nil && r.outbox.onError != nil {
+			r.outbox.onError(err)
+		}
+
+		if err != nil || n < r.outbox.batchSize {
+			return
+		}
+	}
+}
+
+func (r *relay) Close(ctx context.Context) error {
+	err := ErrRelayAlreadyClosed
+
+	r.closeOnce.Do(func() {
+		r.cancel()
+
+		select {
+		case <-r.done:
+			err = nil
+		case <-ctx.Done():
+			err = fmt.Errorf("outbox: failed to close relay, %w", ctx.Err())
+		}
+
+		if closeErr := r.ch.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("outbox: failed to close relay channel, %w", closeErr)
+		}
+
+		r.close <- err
+		close(r.close)
+	})
+
+	return err
+}
+
+func (r *relay) Closed() <-chan error {
+	return r.close
+}
+
+type pending struct {
+	id         int64
+	exchange   string
+	routingKey string
+	properties string
+	body       []byte
+}
+
+// relay publishes a batch of pending messages, marking them as sent
+// in the same database transaction used to lock them.
+//
+// Returns the number of messages published.
+func (o *Outbox) relay(ctx context.Context, pub Publisher) (n int, err error) {
+	tx, err := o.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("outbox: failed to begin relay transaction, %w", err)
+	}
+
+	defer func() {
+		if err == nil {
+			if err = tx.Commit(); err != nil {
+				err = fmt.Errorf("outbox: failed to commit relay transaction, %w", err)
+			}
+
+			return
+		}
+
+		// Commit the messages published so far, so that they won't be published again.
+		if commitErr := tx.Commit(); commitErr != nil {
+			err = fmt.Errorf("outbox: failed to commit relay transaction, %w (caused by %s)", commitErr, err)
+		}
+	}()
+
+	messages, err := o.pending(ctx, tx)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, message := range messages {
+		msg, err := unmarshalPublishing(message.properties, message.body)
+		if err != nil {
+			return n, o.markFailed(ctx, tx, message.id, fmt.Errorf("outbox: failed to unmarshal message %d, %w", message.id, err))
+		}
+
+		if err := pub.Publish(ctx, message.exchange, message.routingKey, msg); err != nil {
+			err = fmt.Errorf("outbox: failed to publish message %d, %w", message.id, err)
+
+			// Only failures caused by the message itself are counted,
+			// so that broker outages don't use up the attempts.
+			if ctx.Err() != nil || !rejected(err) {
+				return n, err
+			}
+
+			return n, o.markFailed(ctx, tx, message.id, err)
+		}
+
+		if _, err := tx.ExecContext(ctx, o.dialect.markSent(o.table), time.Now().UTC(), message.id); err != nil {
+			return n, fmt.Errorf("outbox: failed to mark message %d as sent, %w", message.id, err)
+		}
+
+		n++
+	}
+
+	return n, nil
+}
+
+// markFailed counts a failed attempt to publish the message, in the relay
+// transaction committed with the messages published so far, and returns
+// the error that caused it.
+func (o *Outbox) markFailed(ctx context.Context, tx *sql.Tx, id int64, cause error) error {
+	if _, err := tx.ExecContext(ctx, o.dialect.markFailed(o.table), id); err != nil {
+		return fmt.Errorf("outbox: failed to mark message %d as failed, %w (caused by %s)", id, err, cause)
+	}
+
+	return cause
+}
+
+// rejected reports whether the message has been rejected by the AMQP broker,
+// rather than failing to be published because of the broker or the channel.
+func rejected(err error) bool {
+	return errors.Is(err, publisher.ErrNacked) || errors.Is(err, publisher.ErrReturned)
+}
+
+func (o *Outbox) pending(ctx context.Context, tx *sql.Tx) ([]pending, error) {
+	rows, err := tx.QueryContext(ctx, o.dialect.selectPending(o.table, o.maxAttempts), o.batchSize)
+	if err != nil {
+		return nil, fmt.Errorf("outbox: failed to select pending messages, %w", err)
+	}
+
+	defer rows.Close()
+
+	var messages []pending
+
+	for rows.Next() {
+		var message pending
+
+		err := rows.Scan(&message.id, &message.exchange, &message.routingKey, &message.properties, &message.body)
+		if err != nil {
+			return nil, fmt.Errorf("outbox: failed to scan pending message, %w", err)
+		}
+
+		messages = append(messages, message)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("outbox: failed to select pending messages, %w", err)
+	}
+
+	return messages, nil
+}
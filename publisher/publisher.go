@@ -0,0 +1,241 @@
+// Package publisher contains a safe-for-concurrent-use message publisher,
+// which optionally waits for the AMQP broker to confirm published messages.
+package publisher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ar3s3ru/go-carrot/blocking"
+
+	"github.com/streadway/amqp"
+)
+
+// ErrNacked is returned by Publisher.Publish when the AMQP broker negatively
+// acknowledged the published message.
+var ErrNacked = errors.New("publisher: message nacked by the broker")
+
+// ErrConfirmsClosed is returned by Publisher.Publish when the channel has been
+// closed while waiting for the broker confirmation.
+var ErrConfirmsClosed = errors.New("publisher: confirmation channel closed")
+
+// ErrReturned is returned by Publisher.Publish when the AMQP broker returned
+// a message published with the Mandatory option, since it couldn't be routed
+// to any queue.
+var ErrReturned = errors.New("publisher: message returned by the broker")
+
+// ErrUnsupportedChannel is returned by New when the Mandatory option has been
+// specified, but the channel doesn't notify returned messages.
+var ErrUnsupportedChannel = errors.New("publisher: channel doesn't notify returned messages")
+
+// Channel is the channel interface the Publisher uses to publish messages.
+type Channel interface {
+	Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+	Confirm(noWait bool) error
+	NotifyPublish(chan amqp.Confirmation) chan amqp.Confirmation
+}
+
+// returner is implemented by channels notifying the messages returned
+// by the AMQP broker, such as *amqp.Channel.
+type returner interface {
+	NotifyReturn(chan amqp.Return) chan amqp.Return
+}
+
+// Interface is implemented by the components able to publish messages,
+// such as Publisher, channelpool.Pool and outbox.Outbox, and it's used
+// by the packages publishing messages on behalf of message handlers.
+type Interface interface {
+	Publish(ctx context.Context, exchange, key string, msg amqp.Publishing) error
+}
+
+var _ Interface = new(Publisher)
+
+// Publisher publishes messages on an AMQP channel.
+//
+// Since amqp.Channel is not safe for concurrent publishing, Publisher serializes
+// all the publish requests on the underlying channel.
+//
+// Use New function to create a new Publisher instance.
+type Publisher struct {
+	mx sync.Mutex
+	ch Channel
+
+	tracker         *blocking.Tracker
+	failWhenBlocked bool
+
+	confirm   bool
+	confirms  chan amqp.Confirmation
+	published uint64
+
+	mandatory bool
+	returns   chan amqp.Return
+}
+
+// New returns a new Publisher instance, using the provided channel to publish
+// messages.
+//
+// An error is returned if the Publisher can't put the channel in confirm mode,
+// when requested by using the Confirm option.
+func New(ch Channel, options ...Option) (*Publisher, error) {
+	publisher := &Publisher{ch: ch}
+
+	for _, option := range options {
+		if option == nil {
+			continue
+		}
+
+		option(publisher)
+	}
+
+	if publisher.confirm {
+		if err := ch.Confirm(false); err != nil {
+			return nil, fmt.Errorf("publisher.New: failed to put channel in confirm mode, %w", err)
+		}
+
+		publisher.confirms = ch.NotifyPublish(make(chan amqp.Confirmation, 1))
+	}
+
+	if publisher.mandatory {
+		notifier, ok := ch.(returner)
+		if !ok {
+			return nil, ErrUnsupportedChannel
+		}
+
+		publisher.returns = notifier.NotifyReturn(make(chan amqp.Return, 1))
+	}
+
+	return publisher, nil
+}
+
+// Publish publishes the message on the specified exchange and routing key.
+//
+// If the Publisher uses publisher confirms, Publish waits until the broker
+// confirms the message or the context is done, whichever comes first.
+//
+// While the connection is blocked by the broker, Publish fails or waits
+// according to the FailWhenBlocked and WaitWhenBlocked options.
+func (p *Publisher) Publish(ctx context.Context, exchange, key string, msg amqp.Publishing) error {
+	if err := p.waitUnblocked(ctx); err != nil {
+		return fmt.Errorf("publisher: failed to publish message, %w", err)
+	}
+
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	// Returns of previous messages might still be in the channel,
+	// if the caller stopped waiting for their confirmation: skip them.
+	p.returned()
+
+	if err := p.ch.Publish(exchange, key, p.mandatory, false, msg); err != nil {
+		return fmt.Errorf("publisher: failed to publish message, %w", err)
+	}
+
+	if !p.confirm {
+		return nil
+	}
+
+	p.published++
+
+	if err := p.waitConfirm(ctx, p.published); err != nil {
+		return err
+	}
+
+	// The broker sends the return of a message before its confirmation.
+	if ret, ok := p.returned(); ok {
+		return fmt.Errorf("%w, %s", ErrReturned, ret.ReplyText)
+	}
+
+	return nil
+}
+
+// returned receives the message returned by the broker, if any.
+func (p *Publisher) returned() (amqp.Return, bool) {
+	select {
+	case ret, ok := <-p.returns:
+		return ret, ok
+	default:
+		return amqp.Return{}, false
+	}
+}
+
+func (p *Publisher) waitUnblocked(ctx context.Context) error {
+	switch {
+	case p.tracker == nil:
+		return nil
+	case p.failWhenBlocked:
+		return p.tracker.Check()
+	default:
+		return p.tracker.Wait(ctx)
+	}
+}
+
+func (p *Publisher) waitConfirm(ctx context.Context, tag uint64) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("publisher: failed to receive confirmation, %w", ctx.Err())
+
+		case confirmation, ok := <-p.confirms:
+			if !ok {
+				return ErrConfirmsClosed
+			}
+
+			// Confirmations of previous messages might still be in the channel,
+			// if the caller stopped waiting for them: skip them.
+			if confirmation.DeliveryTag < tag {
+				continue
+			}
+
+			if !confirmation.Ack {
+				return ErrNacked
+			}
+
+			return nil
+		}
+	}
+}
+
+// Option is an optional functionality that can be added to the Publisher
+// that is being initialized by the New factory method.
+type Option func(*Publisher)
+
+// Confirm puts the channel in confirm mode, so that Publisher.Publish waits
+// for the broker to confirm the published message.
+func Confirm(publisher *Publisher) { publisher.confirm = true }
+
+// Mandatory publishes messages with the mandatory flag, so that
+// Publisher.Publish fails with an error wrapping ErrReturned when the message
+// can't be routed to any queue, and puts the channel in confirm mode,
+// to wait for the message to be either confirmed or returned.
+//
+// The channel must notify returned messages, as *amqp.Channel does.
+func Mandatory(publisher *Publisher) {
+	publisher.confirm = true
+	publisher.mandatory = true
+}
+
+// FailWhenBlocked makes Publisher.Publish fail immediately with an error
+// wrapping blocking.ErrBlocked while the connection is blocked by the broker,
+// according to the provided blocking.Tracker.
+func FailWhenBlocked(tracker *blocking.Tracker) Option {
+	return func(publisher *Publisher) {
+		publisher.tracker = tracker
+		publisher.failWhenBlocked = true
+	}
+}
+
+// WaitWhenBlocked makes Publisher.Publish wait for the connection to be
+// unblocked by the broker before publishing, according to the provided
+// blocking.Tracker, instead of hanging on the blocked connection.
+//
+// Use a context with a deadline: if the context is done before
+// the connection gets unblocked, an error wrapping both blocking.ErrBlocked
+// and the context error is returned.
+func WaitWhenBlocked(tracker *blocking.Tracker) Option {
+	return func(publisher *Publisher) {
+		publisher.tracker = tracker
+		publisher.failWhenBlocked = false
+	}
+}
@@ -0,0 +1,196 @@
+package publisher_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ar3s3ru/go-carrot/blocking"
+	"github.com/ar3s3ru/go-carrot/publisher"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type channel struct {
+	confirms  chan amqp.Confirmation
+	published int
+	ack       func(tag uint64) (amqp.Confirmation, bool)
+}
+
+func (ch *channel) Publish(string, string, bool, bool, amqp.Publishing) error {
+	ch.published++
+
+	if confirmation, ok := ch.ack(uint64(ch.published)); ok {
+		ch.confirms <- confirmation
+	}
+
+	return nil
+}
+
+func (ch *channel) Confirm(bool) error { return nil }
+
+func (ch *channel) NotifyPublish(confirms chan amqp.Confirmation) chan amqp.Confirmation {
+	ch.confirms = make(chan amqp.Confirmation, 10)
+	return ch.confirms
+}
+
+func TestPublisher_Publish(t *testing.T) {
+	t.Run("without confirms returns as soon as the message is published", func(t *testing.T) {
+		ch := &channel{ack: func(uint64) (amqp.Confirmation, bool) { return amqp.Confirmation{}, false }}
+
+		pub, err := publisher.New(ch)
+		require.NoError(t, err)
+
+		assert.NoError(t, pub.Publish(context.Background(), "exchange", "key", amqp.Publishing{}))
+		assert.Equal(t, 1, ch.published)
+	})
+
+	t.Run("with confirms waits for the broker ack", func(t *testing.T) {
+		ch := &channel{ack: func(tag uint64) (amqp.Confirmation, bool) {
+			return amqp.Confirmation{DeliveryTag: tag, Ack: tag != 2}, true
+		}}
+
+		pub, err := publisher.New(ch, publisher.Confirm)
+		require.NoError(t, err)
+
+		assert.NoError(t, pub.Publish(context.Background(), "exchange", "key", amqp.Publishing{}))
+		assert.True(t, errors.Is(pub.Publish(context.Background(), "exchange", "key", amqp.Publishing{}), publisher.ErrNacked))
+	})
+
+	t.Run("late confirmations of previous messages are skipped", func(t *testing.T) {
+		var late []amqp.Confirmation
+
+		ch := &channel{ack: func(tag uint64) (amqp.Confirmation, bool) {
+			if tag == 1 {
+				// Broker does not confirm in time.
+				late = append(late, amqp.Confirmation{DeliveryTag: tag, Ack: true})
+				return amqp.Confirmation{}, false
+			}
+
+			return amqp.Confirmation{DeliveryTag: tag, Ack: true}, true
+		}}
+
+		pub, err := publisher.New(ch, publisher.Confirm)
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		assert.True(t, errors.Is(pub.Publish(ctx, "exchange", "key", amqp.Publishing{}), context.DeadlineExceeded))
+
+		ch.confirms <- late[0]
+		assert.NoError(t, pub.Publish(context.Background(), "exchange", "key", amqp.Publishing{}))
+	})
+}
+
+// returningChannel returns the messages published with the "unroutable"
+// routing key, before confirming them.
+type returningChannel struct {
+	*channel
+	returns chan amqp.Return
+}
+
+func (ch *returningChannel) Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	if mandatory && key == "unroutable" {
+		ch.returns <- amqp.Return{ReplyCode: amqp.NoRoute, ReplyText: "NO_ROUTE", RoutingKey: key}
+	}
+
+	return ch.channel.Publish(exchange, key, mandatory, immediate, msg)
+}
+
+func (ch *returningChannel) NotifyReturn(returns chan amqp.Return) chan amqp.Return {
+	ch.returns = returns
+	return returns
+}
+
+func TestPublisher_Mandatory(t *testing.T) {
+	t.Run("fails with unsupported channels", func(t *testing.T) {
+		_, err := publisher.New(new(channel), publisher.Mandatory)
+		assert.Equal(t, publisher.ErrUnsupportedChannel, err)
+	})
+
+	t.Run("fails when the message is returned", func(t *testing.T) {
+		ch := &returningChannel{channel: &channel{ack: func(tag uint64) (amqp.Confirmation, bool) {
+			return amqp.Confirmation{DeliveryTag: tag, Ack: true}, true
+		}}}
+
+		pub, err := publisher.New(ch, publisher.Mandatory)
+		require.NoError(t, err)
+
+		err = pub.Publish(context.Background(), "exchange", "unroutable", amqp.Publishing{})
+		assert.True(t, errors.Is(err, publisher.ErrReturned))
+		assert.Contains(t, err.Error(), "NO_ROUTE")
+
+		assert.NoError(t, pub.Publish(context.Background(), "exchange", "key", amqp.Publishing{}))
+	})
+}
+
+type connection struct {
+	blocking chan amqp.Blocking
+}
+
+func (conn *connection) NotifyBlocked(ch chan amqp.Blocking) chan amqp.Blocking {
+	conn.blocking = ch
+	return ch
+}
+
+func TestPublisher_Blocked(t *testing.T) {
+	conn := new(connection)
+	blocked := make(chan struct{})
+	unblocked := make(chan struct{})
+
+	tracker := blocking.Watch(conn,
+		blocking.OnBlocked(func(string) { blocked <- struct{}{} }),
+		blocking.OnUnblocked(func() { unblocked <- struct{}{} }),
+	)
+
+	conn.blocking <- amqp.Blocking{Active: true, Reason: "low on memory"}
+	<-blocked
+
+	noConfirms := func(uint64) (amqp.Confirmation, bool) { return amqp.Confirmation{}, false }
+
+	t.Run("fails fast while blocked", func(t *testing.T) {
+		ch := &channel{ack: noConfirms}
+
+		pub, err := publisher.New(ch, publisher.FailWhenBlocked(tracker))
+		require.NoError(t, err)
+
+		err = pub.Publish(context.Background(), "exchange", "key", amqp.Publishing{})
+		assert.True(t, errors.Is(err, blocking.ErrBlocked))
+		assert.Equal(t, 0, ch.published)
+	})
+
+	t.Run("waits until the context is done while blocked", func(t *testing.T) {
+		ch := &channel{ack: noConfirms}
+
+		pub, err := publisher.New(ch, publisher.WaitWhenBlocked(tracker))
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		err = pub.Publish(ctx, "exchange", "key", amqp.Publishing{})
+		assert.True(t, errors.Is(err, blocking.ErrBlocked))
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+		assert.Equal(t, 0, ch.published)
+	})
+
+	t.Run("publishes once unblocked", func(t *testing.T) {
+		ch := &channel{ack: noConfirms}
+
+		pub, err := publisher.New(ch, publisher.WaitWhenBlocked(tracker))
+		require.NoError(t, err)
+
+		published := make(chan error, 1)
+		go func() { published <- pub.Publish(context.Background(), "exchange", "key", amqp.Publishing{}) }()
+
+		conn.blocking <- amqp.Blocking{Active: false}
+		<-unblocked
+
+		assert.NoError(t, <-published)
+		assert.Equal(t, 1, ch.published)
+	})
+}
@@ -0,0 +1,82 @@
+package recording
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/ar3s3ru/go-carrot/internal/amqptable"
+)
+
+// Writer writes messages as JSON lines, safe for concurrent use.
+type Writer struct {
+	mx      sync.Mutex
+	encoder *json.Encoder
+}
+
+// NewWriter returns a new Writer writing to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{encoder: json.NewEncoder(w)}
+}
+
+// Write writes the message as a new line.
+func (w *Writer) Write(msg Message) error {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+
+	if err := w.encoder.Encode(msg); err != nil {
+		return fmt.Errorf("recording.Writer: failed to encode message, %w", err)
+	}
+
+	return nil
+}
+
+// Read reads all the JSON lines messages from r, in order,
+// calling fn for each one.
+//
+// Reading stops at the first error returned by fn.
+func Read(r io.Reader, fn func(Message) error) error {
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+
+	for line := 1; ; line++ {
+		var msg Message
+
+		err := decoder.Decode(&msg)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("recording.Read: failed to decode message %d, %w", line, err)
+		}
+
+		msg.Headers = amqptable.FromJSON(msg.Headers)
+
+		if err := fn(msg); err != nil {
+			return err
+		}
+	}
+}
+
+// ReadFile returns all the messages recorded in the specified file.
+func ReadFile(path string) ([]Message, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("recording.ReadFile: failed to open file, %w", err)
+	}
+
+	defer f.Close()
+
+	var messages []Message
+
+	err = Read(f, func(msg Message) error {
+		messages = append(messages, msg)
+		return nil
+	})
+
+	return messages, err
+}
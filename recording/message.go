@@ -0,0 +1,109 @@
+// Package recording captures the deliveries received by consumers into
+// a file, so that they can be replayed later, e.g. against message handlers
+// in regression tests, using carrottest.Replay.
+//
+// Recordings are JSON lines files, one Message per line, in the same format
+// used by the carrot command line tool to dump and replay queues.
+package recording
+
+import (
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// Message is the JSON format of a recorded delivery.
+//
+// All the message properties and headers are preserved; header values are
+// encoded as JSON, so integers are read back as int64 and timestamps
+// as RFC 3339 strings.
+//
+// The fields of this format are stable: new fields might be added,
+// but existing ones won't be renamed nor removed.
+type Message struct {
+	// ConsumerTag is the tag of the consumer that received the delivery,
+	// used by router.Mux to route messages.
+	ConsumerTag string `json:"consumerTag,omitempty"`
+	// ReceivedAt is the time the delivery has been received at, used
+	// to replay messages with the same timing.
+	ReceivedAt *time.Time `json:"receivedAt,omitempty"`
+
+	Exchange    string `json:"exchange"`
+	RoutingKey  string `json:"routingKey"`
+	Redelivered bool   `json:"redelivered,omitempty"`
+
+	Headers         amqp.Table `json:"headers,omitempty"`
+	ContentType     string     `json:"contentType,omitempty"`
+	ContentEncoding string     `json:"contentEncoding,omitempty"`
+	DeliveryMode    uint8      `json:"deliveryMode,omitempty"`
+	Priority        uint8      `json:"priority,omitempty"`
+	CorrelationID   string     `json:"correlationId,omitempty"`
+	ReplyTo         string     `json:"replyTo,omitempty"`
+	Expiration      string     `json:"expiration,omitempty"`
+	MessageID       string     `json:"messageId,omitempty"`
+	Timestamp       *time.Time `json:"timestamp,omitempty"`
+	Type            string     `json:"type,omitempty"`
+	UserID          string     `json:"userId,omitempty"`
+	AppID           string     `json:"appId,omitempty"`
+
+	Body []byte `json:"body"`
+}
+
+// NewMessage returns the Message of the provided delivery, with no
+// consumer tag nor receiving time.
+func NewMessage(delivery amqp.Delivery) Message {
+	msg := Message{
+		Exchange:        delivery.Exchange,
+		RoutingKey:      delivery.RoutingKey,
+		Redelivered:     delivery.Redelivered,
+		Headers:         delivery.Headers,
+		ContentType:     delivery.ContentType,
+		ContentEncoding: delivery.ContentEncoding,
+		DeliveryMode:    delivery.DeliveryMode,
+		Priority:        delivery.Priority,
+		CorrelationID:   delivery.CorrelationId,
+		ReplyTo:         delivery.ReplyTo,
+		Expiration:      delivery.Expiration,
+		MessageID:       delivery.MessageId,
+		Type:            delivery.Type,
+		UserID:          delivery.UserId,
+		AppID:           delivery.AppId,
+		Body:            delivery.Body,
+	}
+
+	if !delivery.Timestamp.IsZero() {
+		timestamp := delivery.Timestamp
+		msg.Timestamp = &timestamp
+	}
+
+	return msg
+}
+
+// Delivery returns the Message as a delivery, with no Acknowledger.
+func (msg Message) Delivery() amqp.Delivery {
+	delivery := amqp.Delivery{
+		ConsumerTag:     msg.ConsumerTag,
+		Exchange:        msg.Exchange,
+		RoutingKey:      msg.RoutingKey,
+		Redelivered:     msg.Redelivered,
+		Headers:         msg.Headers,
+		ContentType:     msg.ContentType,
+		ContentEncoding: msg.ContentEncoding,
+		DeliveryMode:    msg.DeliveryMode,
+		Priority:        msg.Priority,
+		CorrelationId:   msg.CorrelationID,
+		ReplyTo:         msg.ReplyTo,
+		Expiration:      msg.Expiration,
+		MessageId:       msg.MessageID,
+		Type:            msg.Type,
+		UserId:          msg.UserID,
+		AppId:           msg.AppID,
+		Body:            msg.Body,
+	}
+
+	if msg.Timestamp != nil {
+		delivery.Timestamp = *msg.Timestamp
+	}
+
+	return delivery
+}
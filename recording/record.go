@@ -0,0 +1,105 @@
+package recording
+
+import (
+	"context"
+	"time"
+
+	"github.com/ar3s3ru/go-carrot/handler"
+	"github.com/ar3s3ru/go-carrot/listener"
+
+	"github.com/streadway/amqp"
+)
+
+// Record returns a middleware recording all the deliveries received
+// with the provided Writer, before handling them with the next handler.
+//
+// Failing to record a delivery doesn't prevent it from being handled:
+// use OnError to be notified of recording failures.
+func Record(w *Writer, options ...Option) func(handler.Handler) handler.Handler {
+	r := newRecorder(w, options...)
+
+	return func(next handler.Handler) handler.Handler {
+		return handler.Func(func(ctx context.Context, delivery amqp.Delivery) error {
+			r.record(delivery)
+			return next.Handle(ctx, delivery)
+		})
+	}
+}
+
+// Tap wraps the provided Listener, recording all the deliveries it receives
+// with the provided Writer, before handling them with the handler.Handler
+// passed to Listen, such as the one of the Runner.
+//
+// Failing to record a delivery doesn't prevent it from being handled:
+// use OnError to be notified of recording failures.
+func Tap(l listener.Listener, w *Writer, options ...Option) listener.Listener {
+	if l == nil {
+		return nil
+	}
+
+	return tap{listener: l, record: Record(w, options...)}
+}
+
+type tap struct {
+	listener listener.Listener
+	record   func(handler.Handler) handler.Handler
+}
+
+// Listeners returns the wrapped Listener.
+func (t tap) Listeners() []listener.Listener { return []listener.Listener{t.listener} }
+
+func (t tap) Listen(conn listener.Connection, ch listener.Channel, h handler.Handler) (listener.Closer, error) {
+	return t.listener.Listen(conn, ch, t.record(h))
+}
+
+// Option is an optional functionality that can be added to the recorder
+// that is being initialized by the Record and Tap factory methods.
+type Option func(*recorder)
+
+// OnError specifies a function called when a delivery can't be recorded.
+func OnError(fn func(amqp.Delivery, error)) Option {
+	return func(r *recorder) { r.onError = fn }
+}
+
+// Filter specifies which deliveries to record, e.g. to sample production
+// traffic: deliveries not matching the filter are only handled.
+func Filter(fn func(amqp.Delivery) bool) Option {
+	return func(r *recorder) { r.filter = fn }
+}
+
+type recorder struct {
+	w       *Writer
+	onError func(amqp.Delivery, error)
+	filter  func(amqp.Delivery) bool
+	now     func() time.Time
+}
+
+func newRecorder(w *Writer, options ...Option) *recorder {
+	r := &recorder{w: w, now: time.Now}
+
+	for _, option := range options {
+		if option == nil {
+			continue
+		}
+
+		option(r)
+	}
+
+	return r
+}
+
+func (r *recorder) record(delivery amqp.Delivery) {
+	if r.filter != nil && !r.filter(delivery) {
+		return
+	}
+
+	receivedAt := r.now()
+
+	msg := NewMessage(delivery)
+	msg.ConsumerTag = delivery.ConsumerTag
+	msg.ReceivedAt = &receivedAt
+
+	if err := r.w.Write(msg); err != nil && r.onError != nil {
+		r.onError(delivery, err)
+	}
+}
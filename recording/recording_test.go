@@ -0,0 +1,154 @@
+package recording_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ar3s3ru/go-carrot/handler"
+	"github.com/ar3s3ru/go-carrot/listener"
+	"github.com/ar3s3ru/go-carrot/recording"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var timestamp = time.Date(2021, time.March, 1, 10, 0, 0, 0, time.UTC)
+
+func delivery(id string) amqp.Delivery {
+	return amqp.Delivery{
+		ConsumerTag:  "orders",
+		Exchange:     "orders",
+		RoutingKey:   "order.created",
+		MessageId:    id,
+		Timestamp:    timestamp,
+		DeliveryMode: amqp.Persistent,
+		Headers: amqp.Table{
+			"tenant":  "acme",
+			"version": int32(2),
+			"nested":  amqp.Table{"retries": int64(1)},
+		},
+		Body: []byte(`{"id":"` + id + `"}`),
+	}
+}
+
+func TestRecord(t *testing.T) {
+	var (
+		buffer  bytes.Buffer
+		handled []string
+	)
+
+	h := handler.Func(func(_ context.Context, delivery amqp.Delivery) error {
+		handled = append(handled, delivery.MessageId)
+		return nil
+	})
+
+	record := recording.Record(recording.NewWriter(&buffer), recording.Filter(func(delivery amqp.Delivery) bool {
+		return delivery.MessageId != "2"
+	}))
+
+	ctx := context.Background()
+	before := time.Now()
+
+	for _, id := range []string{"1", "2", "3"} {
+		require.NoError(t, record(h).Handle(ctx, delivery(id)))
+	}
+
+	assert.Equal(t, []string{"1", "2", "3"}, handled)
+
+	var messages []recording.Message
+
+	require.NoError(t, recording.Read(&buffer, func(msg recording.Message) error {
+		messages = append(messages, msg)
+		return nil
+	}))
+
+	require.Len(t, messages, 2)
+
+	for i, id := range []string{"1", "3"} {
+		msg := messages[i]
+
+		require.NotNil(t, msg.ReceivedAt)
+		assert.False(t, msg.ReceivedAt.Before(before))
+
+		expected := delivery(id)
+		expected.Headers = amqp.Table{
+			"tenant":  "acme",
+			"version": int64(2),
+			"nested":  amqp.Table{"retries": int64(1)},
+		}
+
+		actual := msg.Delivery()
+		actual.Timestamp = actual.Timestamp.UTC()
+
+		assert.Equal(t, expected, actual)
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestTap(t *testing.T) {
+	var failed []string
+
+	inner := listener.Func(func(_ listener.Connection, _ listener.Channel, h handler.Handler) (listener.Closer, error) {
+		return nil, h.Handle(context.Background(), delivery("1"))
+	})
+
+	called := false
+	h := handler.Func(func(context.Context, amqp.Delivery) error {
+		called = true
+		return nil
+	})
+
+	l := recording.Tap(inner, recording.NewWriter(failingWriter{}), recording.OnError(func(delivery amqp.Delivery, err error) {
+		assert.Error(t, err)
+		failed = append(failed, delivery.MessageId)
+	}))
+
+	_, err := l.Listen(nil, nil, h)
+	require.NoError(t, err)
+
+	assert.True(t, called, "recording failures should not prevent handling")
+	assert.Equal(t, []string{"1"}, failed)
+
+	var listeners []listener.Listener
+	listener.Walk(l, func(l listener.Listener) { listeners = append(listeners, l) })
+	assert.Len(t, listeners, 2)
+
+	assert.Nil(t, recording.Tap(nil, recording.NewWriter(failingWriter{})))
+}
+
+func TestReadFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recording")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "orders.jsonl")
+	content := `{"consumerTag":"orders","receivedAt":"2021-03-01T10:00:00Z","exchange":"orders","routingKey":"order.created","body":"e30="}
+{"exchange":"orders","routingKey":"order.deleted","headers":{"retries":3,"ratio":0.5},"body":null}
+`
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+
+	messages, err := recording.ReadFile(path)
+	require.NoError(t, err)
+	require.Len(t, messages, 2)
+
+	assert.Equal(t, "orders", messages[0].ConsumerTag)
+	assert.Equal(t, timestamp, messages[0].ReceivedAt.UTC())
+	assert.Equal(t, []byte("{}"), messages[0].Body)
+	assert.Equal(t, amqp.Table{"retries": int64(3), "ratio": 0.5}, messages[1].Headers)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("{\n"), 0600))
+
+	_, err = recording.ReadFile(path)
+	assert.Error(t, err)
+}
@@ -0,0 +1,220 @@
+package rpc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/streadway/amqp"
+)
+
+// DirectReplyTo is the pseudo-queue used by RabbitMQ to implement the
+// direct reply-to feature.
+//
+// For more information, please visit https://www.rabbitmq.com/direct-reply-to.html.
+const DirectReplyTo = "amq.rabbitmq.reply-to"
+
+// ErrClientClosed is returned by Client.Call when the Client has been closed,
+// or the reply consumer has been cancelled, before receiving the reply.
+var ErrClientClosed = errors.New("rpc.Client: client closed")
+
+// RemoteError is returned by Client.Call when the request Handler on the server
+// side failed, as reported by the HeaderError reply header.
+type RemoteError struct {
+	Message string
+}
+
+func (err *RemoteError) Error() string {
+	return fmt.Sprintf("rpc: remote handler failed, %s", err.Message)
+}
+
+// Channel is the channel interface the Client uses to publish requests
+// and consume replies.
+type Channel interface {
+	Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+	Consume(queue, name string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error)
+	QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error)
+	Close() error
+}
+
+// Client publishes requests and waits for their replies.
+//
+// Client is safe for concurrent use: replies are matched with their requests
+// using amqp.Publishing.CorrelationId.
+//
+// Use NewClient to create a new Client instance.
+type Client struct {
+	mx sync.Mutex
+	ch Channel
+
+	exclusiveQueue bool
+	replyTo        string
+
+	pending map[string]chan amqp.Delivery
+	closed  bool
+	done    chan struct{}
+}
+
+// NewClient returns a new Client, consuming replies on the provided Channel.
+//
+// By default, the Client uses RabbitMQ direct reply-to: use the ReplyQueue
+// option to use an exclusive reply queue instead.
+func NewClient(ch Channel, options ...ClientOption) (*Client, error) {
+	client := &Client{
+		ch:      ch,
+		replyTo: DirectReplyTo,
+		pending: make(map[string]chan amqp.Delivery),
+		done:    make(chan struct{}),
+	}
+
+	for _, option := range options {
+		if option == nil {
+			continue
+		}
+
+		option(client)
+	}
+
+	if client.exclusiveQueue {
+		queue, err := ch.QueueDeclare(client.replyTo, false, true, true, false, nil)
+		if err != nil {
+			return nil, fmt.Errorf("rpc.NewClient: failed to declare reply queue, %w", err)
+		}
+
+		client.replyTo = queue.Name
+	}
+
+	// Direct reply-to requires no-ack mode, and exclusive queues are only
+	// consumed by this Client: auto-ack replies in both cases.
+	replies, err := ch.Consume(client.replyTo, "", true, false, false, false, nil)
+	if err != nil {
+		return nil, fmt.Errorf("rpc.NewClient: failed to consume replies, %w", err)
+	}
+
+	go client.dispatch(replies)
+
+	return client, nil
+}
+
+func (c *Client) dispatch(replies <-chan amqp.Delivery) {
+	defer close(c.done)
+
+	for reply := range replies {
+		c.mx.Lock()
+		call, ok := c.pending[reply.CorrelationId]
+		delete(c.pending, reply.CorrelationId)
+		c.mx.Unlock()
+
+		// Late replies, after the caller stopped waiting, are discarded.
+		if ok {
+			call <- reply
+		}
+	}
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	c.closed = true
+
+	for id, call := range c.pending {
+		close(call)
+		delete(c.pending, id)
+	}
+}
+
+// Call publishes the request on the specified exchange and routing key,
+// and waits for its reply until the context is done.
+//
+// A *RemoteError is returned, together with the reply, if the request Handler
+// on the server side failed.
+func (c *Client) Call(ctx context.Context, exchange, key string, msg amqp.Publishing) (amqp.Delivery, error) {
+	id, err := newCorrelationID()
+	if err != nil {
+		return amqp.Delivery{}, fmt.Errorf("rpc.Client: failed to generate correlation id, %w", err)
+	}
+
+	msg.CorrelationId = id
+	msg.ReplyTo = c.replyTo
+
+	call := make(chan amqp.Delivery, 1)
+
+	if err := c.publish(exchange, key, id, call, msg); err != nil {
+		return amqp.Delivery{}, err
+	}
+
+	select {
+	case <-ctx.Done():
+		c.mx.Lock()
+		delete(c.pending, id)
+		c.mx.Unlock()
+
+		return amqp.Delivery{}, fmt.Errorf("rpc.Client: failed to receive reply, %w", ctx.Err())
+
+	case reply, ok := <-call:
+		if !ok {
+			return amqp.Delivery{}, ErrClientClosed
+		}
+
+		if message, ok := reply.Headers[HeaderError]; ok {
+			return reply, &RemoteError{Message: fmt.Sprint(message)}
+		}
+
+		return reply, nil
+	}
+}
+
+func (c *Client) publish(exchange, key, id string, call chan amqp.Delivery, msg amqp.Publishing) error {
+	// Channel publishing is not safe for concurrent use, so the lock is held
+	// while publishing, and the call is registered before the reply can arrive.
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if c.closed {
+		return ErrClientClosed
+	}
+
+	c.pending[id] = call
+
+	if err := c.ch.Publish(exchange, key, false, false, msg); err != nil {
+		delete(c.pending, id)
+		return fmt.Errorf("rpc.Client: failed to publish request, %w", err)
+	}
+
+	return nil
+}
+
+// Close closes the underlying Channel: all the calls waiting for a reply
+// fail with ErrClientClosed.
+func (c *Client) Close() error {
+	err := c.ch.Close()
+	<-c.done
+
+	return err
+}
+
+func newCorrelationID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(id), nil
+}
+
+// ClientOption is an optional functionality that can be added to the Client
+// that is being initialized by the NewClient factory method.
+type ClientOption func(*Client)
+
+// ReplyQueue makes the Client declare and consume from an exclusive,
+// auto-delete reply queue, instead of using direct reply-to.
+//
+// Use an empty name to have the queue named by the broker.
+func ReplyQueue(name string) ClientOption {
+	return func(client *Client) {
+		client.exclusiveQueue = true
+		client.replyTo = name
+	}
+}
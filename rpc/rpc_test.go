@@ -0,0 +1,214 @@
+package rpc_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ar3s3ru/go-carrot/rpc"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// broker simulates a server handling requests published on the channel,
+// by calling the rpc.Server handler and looping back its replies.
+type broker struct {
+	mx       sync.Mutex
+	closed   bool
+	replies  chan amqp.Delivery
+	consumed string
+	declared string
+
+	server func(amqp.Delivery) error
+}
+
+// Publish is used by the Client to publish requests.
+func (b *broker) Publish(exchange, key string, _, _ bool, msg amqp.Publishing) error {
+	go b.server(amqp.Delivery{ // nolint:errcheck
+		Exchange:      exchange,
+		RoutingKey:    key,
+		ReplyTo:       msg.ReplyTo,
+		CorrelationId: msg.CorrelationId,
+		Body:          msg.Body,
+	})
+
+	return nil
+}
+
+// publishReply is used by the Server, through replyPublisher, to publish replies.
+func (b *broker) publishReply(_ context.Context, exchange, key string, msg amqp.Publishing) error {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	if b.closed || exchange != "" || key != b.consumed {
+		return errors.New("unroutable reply")
+	}
+
+	b.replies <- amqp.Delivery{
+		Headers:       msg.Headers,
+		CorrelationId: msg.CorrelationId,
+		Body:          msg.Body,
+	}
+
+	return nil
+}
+
+func (b *broker) Consume(queue, _ string, autoAck, _, _, _ bool, _ amqp.Table) (<-chan amqp.Delivery, error) {
+	if !autoAck {
+		return nil, errors.New("reply consumer must be in no-ack mode")
+	}
+
+	b.consumed = queue
+
+	return b.replies, nil
+}
+
+func (b *broker) QueueDeclare(name string, _, _, exclusive, _ bool, _ amqp.Table) (amqp.Queue, error) {
+	if !exclusive {
+		return amqp.Queue{}, errors.New("reply queue must be exclusive")
+	}
+
+	if name == "" {
+		name = "amq.gen-reply"
+	}
+
+	b.declared = name
+
+	return amqp.Queue{Name: name}, nil
+}
+
+func (b *broker) Close() error {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	if !b.closed {
+		b.closed = true
+		close(b.replies)
+	}
+
+	return nil
+}
+
+type replyPublisher struct{ *broker }
+
+func (p replyPublisher) Publish(ctx context.Context, exchange, key string, msg amqp.Publishing) error {
+	return p.publishReply(ctx, exchange, key, msg)
+}
+
+var upper = rpc.HandlerFunc(func(_ context.Context, delivery amqp.Delivery) (amqp.Publishing, error) {
+	if len(delivery.Body) == 0 {
+		return amqp.Publishing{}, errors.New("empty request")
+	}
+
+	if string(delivery.Body) == "slow" {
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	return amqp.Publishing{Body: []byte(strings.ToUpper(string(delivery.Body)))}, nil
+})
+
+func setup(t *testing.T, options ...rpc.ClientOption) (*rpc.Client, *broker) {
+	b := &broker{replies: make(chan amqp.Delivery)}
+	server := rpc.Server(replyPublisher{b}, upper)
+	b.server = func(delivery amqp.Delivery) error { return server.Handle(context.Background(), delivery) }
+
+	client, err := rpc.NewClient(b, options...)
+	require.NoError(t, err)
+
+	return client, b
+}
+
+func TestClient_Call(t *testing.T) {
+	t.Run("uses direct reply-to by default", func(t *testing.T) {
+		client, b := setup(t)
+		defer client.Close()
+
+		assert.Equal(t, rpc.DirectReplyTo, b.consumed)
+
+		reply, err := client.Call(context.Background(), "commands", "upper", amqp.Publishing{Body: []byte("carrot")})
+		assert.NoError(t, err)
+		assert.Equal(t, "CARROT", string(reply.Body))
+	})
+
+	t.Run("uses an exclusive reply queue", func(t *testing.T) {
+		client, b := setup(t, rpc.ReplyQueue(""))
+		defer client.Close()
+
+		assert.Equal(t, "amq.gen-reply", b.declared)
+		assert.Equal(t, "amq.gen-reply", b.consumed)
+
+		reply, err := client.Call(context.Background(), "commands", "upper", amqp.Publishing{Body: []byte("carrot")})
+		assert.NoError(t, err)
+		assert.Equal(t, "CARROT", string(reply.Body))
+	})
+
+	t.Run("concurrent calls receive their own replies", func(t *testing.T) {
+		client, _ := setup(t)
+		defer client.Close()
+
+		var wg sync.WaitGroup
+
+		for _, word := range []string{"a", "b", "c", "d", "e", "f"} {
+			word := word
+
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+
+				reply, err := client.Call(context.Background(), "commands", "upper", amqp.Publishing{Body: []byte(word)})
+				assert.NoError(t, err)
+				assert.Equal(t, strings.ToUpper(word), string(reply.Body))
+			}()
+		}
+
+		wg.Wait()
+	})
+
+	t.Run("remote errors are propagated through reply headers", func(t *testing.T) {
+		client, _ := setup(t)
+		defer client.Close()
+
+		_, err := client.Call(context.Background(), "commands", "upper", amqp.Publishing{})
+
+		var remoteErr *rpc.RemoteError
+		assert.True(t, errors.As(err, &remoteErr))
+		assert.Equal(t, "empty request", remoteErr.Message)
+	})
+
+	t.Run("calls time out with the context", func(t *testing.T) {
+		client, _ := setup(t)
+		defer client.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		_, err := client.Call(ctx, "commands", "upper", amqp.Publishing{Body: []byte("slow")})
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	})
+
+	t.Run("calls fail after the client is closed", func(t *testing.T) {
+		client, _ := setup(t)
+
+		assert.NoError(t, client.Close())
+
+		_, err := client.Call(context.Background(), "commands", "upper", amqp.Publishing{Body: []byte("carrot")})
+		assert.True(t, errors.Is(err, rpc.ErrClientClosed))
+	})
+}
+
+func TestServer(t *testing.T) {
+	t.Run("requests without reply-to return the handler error", func(t *testing.T) {
+		b := &broker{replies: make(chan amqp.Delivery, 1)}
+		server := rpc.Server(replyPublisher{b}, upper)
+
+		assert.Error(t, server.Handle(context.Background(), amqp.Delivery{}))
+		assert.NoError(t, server.Handle(context.Background(), amqp.Delivery{Body: []byte("carrot")}))
+		assert.Empty(t, b.replies)
+	})
+}
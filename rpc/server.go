@@ -0,0 +1,72 @@
+// Package rpc implements the request-reply pattern over AMQP, using the
+// amqp.Delivery.ReplyTo and amqp.Delivery.CorrelationId properties.
+//
+// Server adapts a request Handler into a handler.Handler, publishing responses
+// back to the caller, while Client publishes requests and waits for their replies.
+package rpc
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ar3s3ru/go-carrot/handler"
+	"github.com/ar3s3ru/go-carrot/publisher"
+
+	"github.com/streadway/amqp"
+)
+
+// HeaderError is the reply header used to propagate the error returned
+// by the request Handler to the caller.
+const HeaderError = "x-rpc-error"
+
+// Handler handles incoming requests, returning the response to be published
+// to the caller.
+type Handler interface {
+	Handle(context.Context, amqp.Delivery) (amqp.Publishing, error)
+}
+
+// HandlerFunc is a function that implements the Handler interface, useful
+// to specify request Handlers inline as simple functions.
+type HandlerFunc func(context.Context, amqp.Delivery) (amqp.Publishing, error)
+
+// Handle calls the underlying function to handle the incoming request.
+func (fn HandlerFunc) Handle(ctx context.Context, delivery amqp.Delivery) (amqp.Publishing, error) {
+	return fn(ctx, delivery)
+}
+
+// Publisher is the component used to publish messages, such as publisher.Publisher
+// or channelpool.Pool.
+type Publisher = publisher.Interface
+
+// Server returns a message handler that calls the request Handler and publishes
+// its response on the default exchange, using amqp.Delivery.ReplyTo as routing key
+// and the same amqp.Delivery.CorrelationId of the request.
+//
+// If the request Handler fails, a reply with the HeaderError header is published
+// instead, and the request is acknowledged. Requests with no amqp.Delivery.ReplyTo
+// don't expect a reply: the request Handler error, if any, is returned instead.
+//
+// An error is returned if the reply could not be published.
+func Server(pub Publisher, h Handler) handler.Handler {
+	return handler.Func(func(ctx context.Context, delivery amqp.Delivery) error {
+		reply, err := h.Handle(ctx, delivery)
+
+		if delivery.ReplyTo == "" {
+			return err
+		}
+
+		if err != nil {
+			reply = amqp.Publishing{
+				Headers: amqp.Table{HeaderError: err.Error()},
+			}
+		}
+
+		reply.CorrelationId = delivery.CorrelationId
+
+		if err := pub.Publish(ctx, "", delivery.ReplyTo, reply); err != nil {
+			return fmt.Errorf("rpc.Server: failed to publish reply, %w", err)
+		}
+
+		return nil
+	})
+}
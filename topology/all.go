@@ -15,42 +15,81 @@ func All(declarers ...Declarer) Declarer {
 		return nil
 	}
 
-	return declarerFunc(func(ch Channel) error {
-		var err error
+	return group(declarers)
+}
+
+// group is the Declarer returned by All.
+type group []Declarer
 
-		if err = ch.Tx(); err != nil {
-			return fmt.Errorf("topology.All: failed to open transaction on channel, %w", err)
+// Declarers returns the Declarers grouped together.
+func (declarers group) Declarers() []Declarer { return declarers }
+
+// Describe returns the Spec of all the Declarers grouped together.
+//
+// Declarers that failed to be described, as explained in the Describe function,
+// are skipped: use the Describe function to have the error reported instead.
+func (declarers group) Describe() Spec {
+	var spec Spec
+
+	for _, declarer := range declarers {
+		if described, err := Describe(declarer); err == nil {
+			spec = spec.Merge(described)
 		}
+	}
 
-		// Rollbacks the transaction in case the topology declaration has failed.
-		defer func() {
-			if err == nil {
-				return
-			}
-
-			if rollbackErr := ch.TxRollback(); rollbackErr != nil {
-				err = fmt.Errorf("topology.All: failed to rollback transaction, %w (caused by %s)",
-					rollbackErr,
-					err,
-				)
-			}
-		}()
-
-		for _, declarer := range declarers {
-			if declarer == nil {
-				continue
-			}
-
-			if err = declarer.Declare(ch); err != nil {
-				err = fmt.Errorf("topology.All: failed to declare topology, %w", err)
-				return err
-			}
+	return spec
+}
+
+func (declarers group) describe() (Spec, error) {
+	var spec Spec
+
+	for _, declarer := range declarers {
+		described, err := Describe(declarer)
+		if err != nil {
+			return Spec{}, err
 		}
 
-		if err = ch.TxCommit(); err != nil {
-			err = fmt.Errorf("topology.All: failed to commit topology transaction, %w", err)
+		spec = spec.Merge(described)
+	}
+
+	return spec, nil
+}
+
+func (declarers group) Declare(ch Channel) error {
+	var err error
+
+	if err = ch.Tx(); err != nil {
+		return fmt.Errorf("topology.All: failed to open transaction on channel, %w", err)
+	}
+
+	// Rollbacks the transaction in case the topology declaration has failed.
+	defer func() {
+		if err == nil {
+			return
+		}
+
+		if rollbackErr := ch.TxRollback(); rollbackErr != nil {
+			err = fmt.Errorf("topology.All: failed to rollback transaction, %w (caused by %s)",
+				rollbackErr,
+				err,
+			)
+		}
+	}()
+
+	for _, declarer := range declarers {
+		if declarer == nil {
+			continue
+		}
+
+		if err = declarer.Declare(ch); err != nil {
+			err = fmt.Errorf("topology.All: failed to declare topology, %w", err)
+			return err
 		}
+	}
+
+	if err = ch.TxCommit(); err != nil {
+		err = fmt.Errorf("topology.All: failed to commit topology transaction, %w", err)
+	}
 
-		return err
-	})
+	return err
 }
@@ -27,18 +27,34 @@ type Declarer struct {
 
 // Declare declares the topology of the exchange using the supplied AMQP channel.
 func (d Declarer) Declare(ch topology.Channel) error {
-	err := ch.ExchangeDeclare(d.name, string(d.kind), d.durable, d.autoDelete, d.exclusive, d.noWait, d.args)
-	if err != nil {
-		return err
+	return d.Describe().Declare(ch)
+}
+
+// Describe returns the Spec of the exchange, including its bindings.
+func (d Declarer) Describe() topology.Spec {
+	spec := topology.Spec{
+		Exchanges: []topology.ExchangeSpec{{
+			Name:       d.name,
+			Kind:       string(d.kind),
+			Durable:    d.durable,
+			AutoDelete: d.autoDelete,
+			Internal:   d.exclusive,
+			NoWait:     d.noWait,
+			Arguments:  d.args,
+		}},
 	}
 
-	if len(d.bindings) > 0 {
-		if err := d.bindAll(ch); err != nil {
-			return err
-		}
+	for _, binding := range d.bindings {
+		spec.Bindings = append(spec.Bindings, topology.BindingSpec{
+			Source:          binding.exchange,
+			Destination:     d.name,
+			DestinationType: topology.ExchangeDestination,
+			RoutingKey:      binding.routingKey,
+			NoWait:          d.noWait,
+		})
 	}
 
-	return nil
+	return spec
 }
 
 type binding struct {
@@ -46,17 +62,6 @@ type binding struct {
 	routingKey string
 }
 
-func (d Declarer) bindAll(ch topology.Channel) error {
-	for _, binding := range d.bindings {
-		err := ch.ExchangeBind(d.name, binding.routingKey, binding.exchange, d.noWait, nil)
-		if err != nil {
-			return err
-		}
-	}
-
-	return nil
-}
-
 func (d *Declarer) addToTable(key string, value interface{}) {
 	if d.args == nil {
 		d.args = make(amqp.Table)
@@ -0,0 +1,353 @@
+// Package graph exports the topology declared by a topology.Declarer
+// as a diagram, using either the Graphviz DOT or the Mermaid language.
+package graph
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ar3s3ru/go-carrot/topology"
+)
+
+// NodeKind is the kind of a Node in the Graph.
+type NodeKind string
+
+// Supported node kinds.
+const (
+	ExchangeNode NodeKind = "exchange"
+	QueueNode    NodeKind = "queue"
+)
+
+// EdgeKind is the kind of an Edge in the Graph.
+type EdgeKind string
+
+// Supported edge kinds.
+const (
+	// QueueBinding is a binding from an exchange to a queue.
+	QueueBinding EdgeKind = "queue-binding"
+	// ExchangeBinding is a binding from an exchange to another exchange.
+	ExchangeBinding EdgeKind = "exchange-binding"
+	// DeadLetter links a queue to its dead-letter exchange.
+	DeadLetter EdgeKind = "dead-letter"
+)
+
+// Node is either an exchange or a queue.
+type Node struct {
+	Kind NodeKind
+	Name string
+
+	// ExchangeKind is the kind of the exchange, empty for queues.
+	ExchangeKind string
+
+	Durable    bool
+	AutoDelete bool
+	Exclusive  bool
+
+	// Declared is false for exchanges referenced by bindings or dead-letter
+	// arguments, but not declared in the topology, such as predefined ones.
+	Declared bool
+}
+
+// Edge is a link between two nodes, identified by their index in the Graph.
+type Edge struct {
+	Kind       EdgeKind
+	From, To   int
+	RoutingKey string
+}
+
+// Graph is the graph of exchanges and queues declared by a topology.Declarer.
+type Graph struct {
+	Nodes []Node
+	Edges []Edge
+
+	index map[string]int
+}
+
+// New builds the Graph of the topology declared by the provided Declarer,
+// including all the Declarers grouped with topology.All and dead-letter
+// queues declared with queue.DeadLetterWithQueue.
+func New(declarer topology.Declarer) (*Graph, error) {
+	spec, err := topology.Describe(declarer)
+	if err != nil {
+		return nil, fmt.Errorf("graph.New: failed to describe topology, %w", err)
+	}
+
+	g := &Graph{index: make(map[string]int)}
+
+	for _, exchange := range spec.Exchanges {
+		node := g.node(ExchangeNode, exchange.Name)
+		node.ExchangeKind = exchange.Kind
+		node.Durable = exchange.Durable
+		node.AutoDelete = exchange.AutoDelete
+		node.Declared = true
+	}
+
+	for _, queue := range spec.Queues {
+		node := g.node(QueueNode, queue.Name)
+		node.Durable = queue.Durable
+		node.AutoDelete = queue.AutoDelete
+		node.Exclusive = queue.Exclusive
+		node.Declared = true
+	}
+
+	for _, binding := range spec.Bindings {
+		edge := Edge{Kind: QueueBinding, RoutingKey: binding.RoutingKey}
+		edge.From = g.nodeIndex(ExchangeNode, binding.Source)
+
+		if binding.DestinationType == topology.ExchangeDestination {
+			edge.Kind = ExchangeBinding
+			edge.To = g.nodeIndex(ExchangeNode, binding.Destination)
+		} else {
+			edge.To = g.nodeIndex(QueueNode, binding.Destination)
+		}
+
+		g.Edges = append(g.Edges, edge)
+	}
+
+	for _, queue := range spec.Queues {
+		exchange, ok := queue.DeadLetterExchange()
+		if !ok {
+			continue
+		}
+
+		routingKey, _ := queue.DeadLetterRoutingKey()
+
+		g.Edges = append(g.Edges, Edge{
+			Kind:       DeadLetter,
+			From:       g.nodeIndex(QueueNode, queue.Name),
+			To:         g.nodeIndex(ExchangeNode, exchange),
+			RoutingKey: routingKey,
+		})
+	}
+
+	return g, nil
+}
+
+func (g *Graph) nodeIndex(kind NodeKind, name string) int {
+	key := string(kind) + ":" + name
+
+	if i, ok := g.index[key]; ok {
+		return i
+	}
+
+	g.Nodes = append(g.Nodes, Node{Kind: kind, Name: name})
+	g.index[key] = len(g.Nodes) - 1
+
+	return len(g.Nodes) - 1
+}
+
+func (g *Graph) node(kind NodeKind, name string) *Node {
+	return &g.Nodes[g.nodeIndex(kind, name)]
+}
+
+// flags returns the queue or exchange flags set on the node.
+func (node Node) flags() []string {
+	var flags []string
+
+	if node.Durable {
+		flags = append(flags, "durable")
+	}
+
+	if node.AutoDelete {
+		flags = append(flags, "auto-delete")
+	}
+
+	if node.Exclusive {
+		flags = append(flags, "exclusive")
+	}
+
+	if !node.Declared {
+		flags = append(flags, "undeclared")
+	}
+
+	return flags
+}
+
+// label returns the name of the node, followed by its exchange kind and flags.
+func (node Node) label(separator string) string {
+	name := node.Name
+	if node.Kind == ExchangeNode && name == "" {
+		name = "(default)"
+	}
+
+	details := node.flags()
+	if node.ExchangeKind != "" {
+		details = append([]string{node.ExchangeKind}, details...)
+	}
+
+	if len(details) == 0 {
+		return name
+	}
+
+	return name + separator + "(" + strings.Join(details, ", ") + ")"
+}
+
+// exchangeColors are the fill colors of exchange nodes, by exchange kind.
+var exchangeColors = map[string]string{
+	"direct":  "#cfe2ff",
+	"fanout":  "#d1e7dd",
+	"topic":   "#fff3cd",
+	"headers": "#e2d9f3",
+}
+
+const (
+	defaultExchangeColor = "#e9ecef"
+	queueColor           = "#ffffff"
+)
+
+func (node Node) color() string {
+	if node.Kind == QueueNode {
+		return queueColor
+	}
+
+	if color, ok := exchangeColors[node.ExchangeKind]; ok {
+		return color
+	}
+
+	return defaultExchangeColor
+}
+
+// DOT returns the Graph using the Graphviz DOT language.
+//
+// Exchanges are drawn as hexagons filled by exchange kind, queues as boxes;
+// durable nodes have a bold border, auto-delete and exclusive queues
+// a dashed one, and undeclared exchanges a dotted one.
+// Dead-letter links are drawn as dashed red edges.
+func (g *Graph) DOT() string {
+	var b strings.Builder
+
+	b.WriteString("digraph topology {\n")
+	b.WriteString("\trankdir=LR;\n")
+	b.WriteString("\tnode [style=filled, fontname=\"Helvetica\"];\n")
+
+	for i, node := range g.Nodes {
+		shape := "box"
+		if node.Kind == ExchangeNode {
+			shape = "hexagon"
+		}
+
+		styles := []string{"filled"}
+
+		switch {
+		case !node.Declared:
+			styles = append(styles, "dotted")
+		case node.AutoDelete || node.Exclusive:
+			styles = append(styles, "dashed")
+		}
+
+		if node.Durable {
+			styles = append(styles, "bold")
+		}
+
+		fmt.Fprintf(&b, "\tn%d [label=%q, shape=%s, style=%q, fillcolor=%q];\n",
+			i, node.label("\n"), shape, strings.Join(styles, ","), node.color())
+	}
+
+	for _, edge := range g.Edges {
+		attrs := []string{fmt.Sprintf("label=%q", edge.RoutingKey)}
+
+		switch edge.Kind {
+		case ExchangeBinding:
+			attrs = append(attrs, "style=bold")
+		case DeadLetter:
+			attrs = append(attrs, "style=dashed", "color=red", "fontcolor=red")
+		}
+
+		fmt.Fprintf(&b, "\tn%d -> n%d [%s];\n", edge.From, edge.To, strings.Join(attrs, ", "))
+	}
+
+	b.WriteString("}\n")
+
+	return b.String()
+}
+
+// Mermaid returns the Graph using the Mermaid flowchart language.
+//
+// Exchanges are drawn as hexagons, styled by exchange kind, and queues as
+// cylinders; durable nodes have a thicker border, auto-delete and exclusive
+// queues a dashed one. Dead-letter links are drawn as dotted edges.
+func (g *Graph) Mermaid() string {
+	var b strings.Builder
+
+	b.WriteString("flowchart LR\n")
+
+	classes := make(map[string][]string)
+
+	for i, node := range g.Nodes {
+		label := strings.ReplaceAll(node.label("<br/>"), `"`, "#quot;")
+
+		if node.Kind == ExchangeNode {
+			fmt.Fprintf(&b, "\tn%d{{\"%s\"}}\n", i, label)
+		} else {
+			fmt.Fprintf(&b, "\tn%d[(\"%s\")]\n", i, label)
+		}
+
+		id := fmt.Sprintf("n%d", i)
+
+		if node.Kind == ExchangeNode {
+			class := node.ExchangeKind
+			if _, ok := exchangeColors[class]; !ok {
+				class = "other"
+			}
+
+			classes[class] = append(classes[class], id)
+		}
+
+		for _, flag := range node.flags() {
+			classes[flag] = append(classes[flag], id)
+		}
+	}
+
+	for _, edge := range g.Edges {
+		arrow := "-->"
+
+		switch edge.Kind {
+		case ExchangeBinding:
+			arrow = "==>"
+		case DeadLetter:
+			arrow = "-.->"
+		}
+
+		if edge.RoutingKey == "" {
+			fmt.Fprintf(&b, "\tn%d %s n%d\n", edge.From, arrow, edge.To)
+		} else {
+			fmt.Fprintf(&b, "\tn%d %s|\"%s\"| n%d\n", edge.From, arrow, edge.RoutingKey, edge.To)
+		}
+	}
+
+	for _, class := range sortedKeys(classes) {
+		fmt.Fprintf(&b, "\tclassDef %s %s\n", mermaidClassName(class), mermaidStyles[class])
+		fmt.Fprintf(&b, "\tclass %s %s\n", strings.Join(classes[class], ","), mermaidClassName(class))
+	}
+
+	return b.String()
+}
+
+var mermaidStyles = map[string]string{
+	"direct":      "fill:" + exchangeColors["direct"],
+	"fanout":      "fill:" + exchangeColors["fanout"],
+	"topic":       "fill:" + exchangeColors["topic"],
+	"headers":     "fill:" + exchangeColors["headers"],
+	"other":       "fill:" + defaultExchangeColor,
+	"durable":     "stroke-width:3px",
+	"auto-delete": "stroke-dasharray:5 5",
+	"exclusive":   "stroke-dasharray:5 5",
+	"undeclared":  "stroke-dasharray:2 2",
+}
+
+// mermaidClassName returns a class name valid in Mermaid.
+func mermaidClassName(class string) string {
+	return strings.ReplaceAll(class, "-", "")
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
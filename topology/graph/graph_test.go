@@ -0,0 +1,80 @@
+package graph_test
+
+import (
+	"testing"
+
+	"github.com/ar3s3ru/go-carrot/topology"
+	"github.com/ar3s3ru/go-carrot/topology/exchange"
+	"github.com/ar3s3ru/go-carrot/topology/exchange/kind"
+	"github.com/ar3s3ru/go-carrot/topology/graph"
+	"github.com/ar3s3ru/go-carrot/topology/queue"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraph(t *testing.T) {
+	g, err := graph.New(topology.All(
+		exchange.Declare("orders", exchange.Kind(kind.Direct), exchange.Durable),
+		exchange.Declare("orders-audit", exchange.Kind(kind.Fanout), exchange.BindTo("orders", "#")),
+		queue.Declare("orders-created",
+			queue.Durable,
+			queue.BindTo("orders", "created"),
+			queue.DeadLetterWithQueue("orders-dlx", "created", queue.Declare("orders-created-dlq")),
+		),
+	))
+
+	require.NoError(t, err)
+
+	assert.Equal(t, []graph.Node{
+		{Kind: graph.ExchangeNode, Name: "orders", ExchangeKind: "direct", Durable: true, Declared: true},
+		{Kind: graph.ExchangeNode, Name: "orders-audit", ExchangeKind: "fanout", Declared: true},
+		{Kind: graph.QueueNode, Name: "orders-created", Durable: true, Declared: true},
+		{Kind: graph.QueueNode, Name: "orders-created-dlq", Declared: true},
+		{Kind: graph.ExchangeNode, Name: "orders-dlx"},
+	}, g.Nodes)
+
+	assert.Equal(t, []graph.Edge{
+		{Kind: graph.ExchangeBinding, From: 0, To: 1, RoutingKey: "#"},
+		{Kind: graph.QueueBinding, From: 0, To: 2, RoutingKey: "created"},
+		{Kind: graph.QueueBinding, From: 4, To: 3, RoutingKey: "created"},
+		{Kind: graph.DeadLetter, From: 2, To: 4, RoutingKey: "created"},
+	}, g.Edges)
+
+	assert.Equal(t, `digraph topology {
+	rankdir=LR;
+	node [style=filled, fontname="Helvetica"];
+	n0 [label="orders\n(direct, durable)", shape=hexagon, style="filled,bold", fillcolor="#cfe2ff"];
+	n1 [label="orders-audit\n(fanout)", shape=hexagon, style="filled", fillcolor="#d1e7dd"];
+	n2 [label="orders-created\n(durable)", shape=box, style="filled,bold", fillcolor="#ffffff"];
+	n3 [label="orders-created-dlq", shape=box, style="filled", fillcolor="#ffffff"];
+	n4 [label="orders-dlx\n(undeclared)", shape=hexagon, style="filled,dotted", fillcolor="#e9ecef"];
+	n0 -> n1 [label="#", style=bold];
+	n0 -> n2 [label="created"];
+	n4 -> n3 [label="created"];
+	n2 -> n4 [label="created", style=dashed, color=red, fontcolor=red];
+}
+`, g.DOT())
+
+	assert.Equal(t, `flowchart LR
+	n0{{"orders<br/>(direct, durable)"}}
+	n1{{"orders-audit<br/>(fanout)"}}
+	n2[("orders-created<br/>(durable)")]
+	n3[("orders-created-dlq")]
+	n4{{"orders-dlx<br/>(undeclared)"}}
+	n0 ==>|"#"| n1
+	n0 -->|"created"| n2
+	n4 -->|"created"| n3
+	n2 -.->|"created"| n4
+	classDef direct fill:#cfe2ff
+	class n0 direct
+	classDef durable stroke-width:3px
+	class n0,n2 durable
+	classDef fanout fill:#d1e7dd
+	class n1 fanout
+	classDef other fill:#e9ecef
+	class n4 other
+	classDef undeclared stroke-dasharray:2 2
+	class n4 undeclared
+`, g.Mermaid())
+}
@@ -0,0 +1,306 @@
+// Package migrate adds a topology.Declarer able to apply versioned migrations
+// to the topology, for changes that can't be applied by declaring it again,
+// such as changing the arguments of a queue.
+//
+// The version of the last migration applied is stored on the AMQP broker,
+// as the only message of a durable marker queue, so that migrations
+// are applied only once.
+//
+// Migrations are applied while holding a lock, an exclusive queue deleted
+// by the AMQP broker when the connection that declared it is closed,
+// so that only one application at a time applies them.
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/ar3s3ru/go-carrot/publisher"
+	"github.com/ar3s3ru/go-carrot/topology"
+
+	"github.com/streadway/amqp"
+)
+
+// DefaultMarkerQueue is the name of the queue used to store the version
+// of the last migration applied, if not specified with the MarkerQueue option.
+const DefaultMarkerQueue = "carrot.migrations"
+
+// HeaderVersion is the header of the marker message containing the version
+// of the last migration applied.
+const HeaderVersion = "x-migration-version"
+
+var (
+	// ErrUnsupportedChannel is returned by the Migrator when the channel
+	// provided doesn't support all the operations needed by migrations,
+	// e.g. when using topology.Describe.
+	ErrUnsupportedChannel = errors.New("migrate.Migrator: unsupported channel")
+
+	// ErrUnorderedMigrations is returned by the Migrator when migration
+	// versions are not strictly increasing.
+	ErrUnorderedMigrations = errors.New("migrate.Migrator: migration versions must be strictly increasing")
+
+	// ErrLocked is returned by the Migrator when another application is
+	// applying migrations. The AMQP broker closes the channel used
+	// by the Migrator, so the topology must be declared again later,
+	// on a new channel.
+	ErrLocked = errors.New("migrate.Migrator: migrations locked by another application")
+)
+
+// Channel is the channel interface the Migrator uses to apply migrations,
+// implemented by *amqp.Channel.
+//
+// The channel provided to Steps publishes messages with publisher confirms
+// and the mandatory flag: Publish returns only once the message has been
+// confirmed by the AMQP broker, and fails if it couldn't be routed.
+type Channel interface {
+	topology.Channel
+	publisher.Channel
+
+	QueueDelete(name string, ifUnused, ifEmpty, noWait bool) (int, error)
+	QueueUnbind(name, key, exchange string, args amqp.Table) error
+	ExchangeDelete(name string, ifUnused, noWait bool) error
+	ExchangeUnbind(destination, key, source string, noWait bool, args amqp.Table) error
+
+	Get(queue string, autoAck bool) (amqp.Delivery, bool, error)
+	NotifyReturn(chan amqp.Return) chan amqp.Return
+}
+
+// Migration is a set of Steps, identified by a version.
+type Migration struct {
+	Version int
+	Steps   []Step
+}
+
+// Version returns a new Migration, applying the specified Steps in order.
+func Version(version int, steps ...Step) Migration {
+	return Migration{Version: version, Steps: steps}
+}
+
+// Migrator is a topology.Declarer applying all the migrations with a version
+// greater than the last one applied, in order.
+//
+// Since moving messages and storing the version require publisher confirms,
+// the Migrator should not be grouped with other Declarers using topology.All,
+// which declares the topology in a transaction.
+//
+// If another application is applying migrations at the same time,
+// the Migrator fails with ErrLocked.
+//
+// Use New function to create a new Migrator instance.
+type Migrator struct {
+	migrations  []Migration
+	markerQueue string
+}
+
+// New returns a new Migrator, applying the provided migrations.
+func New(migrations []Migration, options ...Option) Migrator {
+	migrator := Migrator{
+		migrations:  migrations,
+		markerQueue: DefaultMarkerQueue,
+	}
+
+	for _, option := range options {
+		if option == nil {
+			continue
+		}
+
+		option(&migrator)
+	}
+
+	return migrator
+}
+
+// Declare applies all the migrations with a version greater than the last
+// applied one, storing the version after each migration has been applied.
+func (migrator Migrator) Declare(ch topology.Channel) (err error) {
+	mch, ok := ch.(Channel)
+	if !ok {
+		return ErrUnsupportedChannel
+	}
+
+	for i := 1; i < len(migrator.migrations); i++ {
+		if migrator.migrations[i].Version <= migrator.migrations[i-1].Version {
+			return ErrUnorderedMigrations
+		}
+	}
+
+	if err := migrator.lock(mch); err != nil {
+		return err
+	}
+
+	defer func() {
+		if unlockErr := migrator.unlock(mch); unlockErr != nil && err == nil {
+			err = unlockErr
+		}
+	}()
+
+	pub, err := publisher.New(mch, publisher.Mandatory)
+	if err != nil {
+		return fmt.Errorf("migrate.Migrator: failed to create publisher, %w", err)
+	}
+
+	mch = confirmedChannel{Channel: mch, publisher: pub}
+
+	if err := migrator.marker().Declare(ch); err != nil {
+		return fmt.Errorf("migrate.Migrator: failed to declare marker queue, %w", err)
+	}
+
+	current, err := migrator.currentVersion(mch)
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range migrator.migrations {
+		if migration.Version <= current {
+			continue
+		}
+
+		for i, step := range migration.Steps {
+			if err := step.Apply(mch); err != nil {
+				return fmt.Errorf("migrate.Migrator: failed to apply step %d of version %d, %w", i+1, migration.Version, err)
+			}
+		}
+
+		if err := migrator.storeVersion(mch, migration.Version); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Describe returns the Spec of the topology declared by all the migrations,
+// excluding the exchanges and queues deleted by later migrations,
+// and including the marker queue.
+func (migrator Migrator) Describe() topology.Spec {
+	spec := migrator.marker()
+
+	for _, migration := range migrator.migrations {
+		for _, step := range migration.Steps {
+			if step, ok := step.(describer); ok {
+				spec = step.describe(spec)
+			}
+		}
+	}
+
+	return spec
+}
+
+// lockQueue returns the name of the exclusive queue used as lock.
+func (migrator Migrator) lockQueue() string {
+	return migrator.markerQueue + ".lock"
+}
+
+// lock declares the exclusive lock queue, failing with ErrLocked if it has
+// been declared by another connection.
+func (migrator Migrator) lock(ch Channel) error {
+	_, err := ch.QueueDeclare(migrator.lockQueue(), false, true, true, false, nil)
+
+	var amqpErr *amqp.Error
+	if errors.As(err, &amqpErr) && amqpErr.Code == amqp.ResourceLocked {
+		return fmt.Errorf("%w, %s", ErrLocked, amqpErr.Reason)
+	}
+
+	if err != nil {
+		return fmt.Errorf("migrate.Migrator: failed to acquire lock, %w", err)
+	}
+
+	return nil
+}
+
+// unlock deletes the exclusive lock queue, which would otherwise be deleted
+// only once the connection is closed.
+func (migrator Migrator) unlock(ch Channel) error {
+	if _, err := ch.QueueDelete(migrator.lockQueue(), false, false, false); err != nil {
+		return fmt.Errorf("migrate.Migrator: failed to release lock, %w", err)
+	}
+
+	return nil
+}
+
+// confirmedChannel publishes messages with publisher confirms and
+// the mandatory flag, using the provided publisher.Publisher.
+type confirmedChannel struct {
+	Channel
+	publisher *publisher.Publisher
+}
+
+func (ch confirmedChannel) Publish(exchange, key string, _, _ bool, msg amqp.Publishing) error {
+	return ch.publisher.Publish(context.Background(), exchange, key, msg)
+}
+
+// describer is implemented by those Steps changing the topology described
+// by the Migrator.
+type describer interface {
+	describe(topology.Spec) topology.Spec
+}
+
+// marker returns the Spec of the marker queue, keeping only the last
+// message published.
+func (migrator Migrator) marker() topology.Spec {
+	return topology.Spec{
+		Queues: []topology.QueueSpec{{
+			Name:    migrator.markerQueue,
+			Durable: true,
+			Arguments: amqp.Table{
+				"x-max-length": int32(1),
+				"x-overflow":   "drop-head",
+			},
+		}},
+	}
+}
+
+// currentVersion returns the version stored in the marker queue, or zero
+// if no migration has been applied yet.
+func (migrator Migrator) currentVersion(ch Channel) (int, error) {
+	delivery, ok, err := ch.Get(migrator.markerQueue, false)
+	if err != nil {
+		return 0, fmt.Errorf("migrate.Migrator: failed to read current version, %w", err)
+	}
+
+	if !ok {
+		return 0, nil
+	}
+
+	// Puts the marker back, it will be replaced by the next version stored.
+	if err := delivery.Reject(true); err != nil {
+		return 0, fmt.Errorf("migrate.Migrator: failed to requeue current version, %w", err)
+	}
+
+	switch version := delivery.Headers[HeaderVersion].(type) {
+	case int64:
+		return int(version), nil
+	case int32:
+		return int(version), nil
+	default:
+		return 0, fmt.Errorf("migrate.Migrator: invalid version in marker queue, %v", version)
+	}
+}
+
+// storeVersion publishes the version as the only message of the marker queue.
+func (migrator Migrator) storeVersion(ch Channel, version int) error {
+	err := ch.Publish("", migrator.markerQueue, true, false, amqp.Publishing{
+		Headers:      amqp.Table{HeaderVersion: int64(version)},
+		DeliveryMode: amqp.Persistent,
+		ContentType:  "text/plain",
+		Body:         []byte(strconv.Itoa(version)),
+	})
+
+	if err != nil {
+		return fmt.Errorf("migrate.Migrator: failed to store version %d, %w", version, err)
+	}
+
+	return nil
+}
+
+// Option is an optional functionality that can be added to the Migrator
+// that is being initialized by the New factory method.
+type Option func(*Migrator)
+
+// MarkerQueue specifies the name of the queue used to store the version
+// of the last migration applied. If not specified, DefaultMarkerQueue is used.
+func MarkerQueue(name string) Option {
+	return func(migrator *Migrator) { migrator.markerQueue = name }
+}
@@ -0,0 +1,345 @@
+package migrate_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ar3s3ru/go-carrot/publisher"
+	"github.com/ar3s3ru/go-carrot/topology"
+	"github.com/ar3s3ru/go-carrot/topology/exchange"
+	"github.com/ar3s3ru/go-carrot/topology/exchange/kind"
+	"github.com/ar3s3ru/go-carrot/topology/migrate"
+	"github.com/ar3s3ru/go-carrot/topology/mocks"
+	"github.com/ar3s3ru/go-carrot/topology/queue"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrator_Declare(t *testing.T) {
+	t.Run("fails with a channel not supporting migrations", func(t *testing.T) {
+		migrator := migrate.New(nil)
+		assert.True(t, errors.Is(migrator.Declare(new(mocks.Channel)), migrate.ErrUnsupportedChannel))
+	})
+
+	t.Run("fails with unordered migrations", func(t *testing.T) {
+		migrator := migrate.New([]migrate.Migration{
+			migrate.Version(2),
+			migrate.Version(1),
+		})
+
+		assert.True(t, errors.Is(migrator.Declare(newBroker()), migrate.ErrUnorderedMigrations))
+	})
+
+	t.Run("applies all migrations and stores the last version", func(t *testing.T) {
+		var applied []int
+
+		migrator := migrate.New([]migrate.Migration{
+			migrate.Version(1, record(&applied, 1)),
+			migrate.Version(2, record(&applied, 2)),
+		})
+
+		broker := newBroker()
+
+		require.NoError(t, migrator.Declare(broker))
+		assert.Equal(t, []int{1, 2}, applied)
+		assert.Equal(t, []int64{2}, broker.versions(migrate.DefaultMarkerQueue))
+	})
+
+	t.Run("skips migrations already applied", func(t *testing.T) {
+		var applied []int
+
+		migrator := migrate.New([]migrate.Migration{
+			migrate.Version(1, record(&applied, 1)),
+			migrate.Version(2, record(&applied, 2)),
+			migrate.Version(3, record(&applied, 3)),
+		}, migrate.MarkerQueue("my-migrations"))
+
+		broker := newBroker()
+		broker.queues["my-migrations"] = []amqp.Delivery{
+			{Headers: amqp.Table{migrate.HeaderVersion: int32(2)}},
+		}
+
+		require.NoError(t, migrator.Declare(broker))
+		assert.Equal(t, []int{3}, applied)
+		assert.Equal(t, []int64{3}, broker.versions("my-migrations"))
+
+		// Declaring again doesn't apply any migration.
+		require.NoError(t, migrator.Declare(broker))
+		assert.Equal(t, []int{3}, applied)
+	})
+
+	t.Run("stops at the first failing migration", func(t *testing.T) {
+		var applied []int
+
+		migrator := migrate.New([]migrate.Migration{
+			migrate.Version(1, record(&applied, 1)),
+			migrate.Version(2, migrate.StepFunc(func(migrate.Channel) error {
+				return errors.New("failed")
+			})),
+			migrate.Version(3, record(&applied, 3)),
+		})
+
+		broker := newBroker()
+
+		assert.Error(t, migrator.Declare(broker))
+		assert.Equal(t, []int{1}, applied)
+		assert.Equal(t, []int64{1}, broker.versions(migrate.DefaultMarkerQueue))
+	})
+
+	t.Run("moves messages to the new queue", func(t *testing.T) {
+		migrator := migrate.New([]migrate.Migration{
+			migrate.Version(1,
+				migrate.Declare(queue.Declare("orders.v2")),
+				migrate.MoveMessages("orders", "", "orders.v2"),
+				migrate.DeleteQueue("orders"),
+			),
+		})
+
+		broker := newBroker()
+		broker.queues["orders"] = []amqp.Delivery{
+			{RoutingKey: "orders", MessageId: "1", Body: []byte("first")},
+			{RoutingKey: "orders", MessageId: "2", Body: []byte("second")},
+		}
+
+		require.NoError(t, migrator.Declare(broker))
+
+		moved := broker.queues["orders.v2"]
+		require.Len(t, moved, 2)
+		assert.Equal(t, "1", moved[0].MessageId)
+		assert.Equal(t, []byte("second"), moved[1].Body)
+		assert.NotContains(t, broker.queues, "orders")
+	})
+
+	t.Run("keeps messages that can't be moved", func(t *testing.T) {
+		migrator := migrate.New([]migrate.Migration{
+			migrate.Version(1, migrate.MoveMessages("orders", "", "orders.v2")),
+		})
+
+		broker := newBroker()
+		broker.queues["orders"] = []amqp.Delivery{{RoutingKey: "orders", MessageId: "1"}}
+
+		err := migrator.Declare(broker)
+		assert.True(t, errors.Is(err, publisher.ErrReturned))
+		assert.Len(t, broker.queues["orders"], 1)
+		assert.Empty(t, broker.versions(migrate.DefaultMarkerQueue))
+	})
+
+	t.Run("holds a lock while applying migrations", func(t *testing.T) {
+		var locked bool
+
+		broker := newBroker()
+
+		migrator := migrate.New([]migrate.Migration{
+			migrate.Version(1, migrate.StepFunc(func(migrate.Channel) error {
+				_, locked = broker.queues[migrate.DefaultMarkerQueue+".lock"]
+				return nil
+			})),
+		})
+
+		require.NoError(t, migrator.Declare(broker))
+		assert.True(t, locked)
+		assert.NotContains(t, broker.queues, migrate.DefaultMarkerQueue+".lock")
+	})
+
+	t.Run("fails when another application holds the lock", func(t *testing.T) {
+		var applied []int
+
+		migrator := migrate.New([]migrate.Migration{
+			migrate.Version(1, record(&applied, 1)),
+		})
+
+		broker := newBroker()
+		broker.locked[migrate.DefaultMarkerQueue+".lock"] = true
+
+		assert.True(t, errors.Is(migrator.Declare(broker), migrate.ErrLocked))
+		assert.Empty(t, applied)
+	})
+}
+
+func TestMigrator_Describe(t *testing.T) {
+	migrator := migrate.New([]migrate.Migration{
+		migrate.Version(1,
+			migrate.Declare(topology.All(
+				exchange.Declare("orders", exchange.Kind(kind.Direct)),
+				queue.Declare("orders", queue.BindTo("orders", "created")),
+			)),
+		),
+		migrate.Version(2,
+			migrate.Declare(queue.Declare("orders.v2", queue.BindTo("orders", "created"))),
+			migrate.UnbindQueue("orders", "orders", "created"),
+			migrate.DeleteQueue("orders"),
+		),
+	})
+
+	spec, err := topology.Describe(migrator)
+	require.NoError(t, err)
+
+	_, ok := spec.Queue("orders")
+	assert.False(t, ok)
+
+	_, ok = spec.Queue("orders.v2")
+	assert.True(t, ok)
+
+	_, ok = spec.Queue(migrate.DefaultMarkerQueue)
+	assert.True(t, ok)
+
+	assert.Equal(t, []topology.BindingSpec{{
+		Source:          "orders",
+		Destination:     "orders.v2",
+		DestinationType: topology.QueueDestination,
+		RoutingKey:      "created",
+	}}, spec.Bindings)
+
+	assert.NoError(t, topology.Validate(migrator, topology.Strict))
+}
+
+func record(applied *[]int, version int) migrate.Step {
+	return migrate.StepFunc(func(migrate.Channel) error {
+		*applied = append(*applied, version)
+		return nil
+	})
+}
+
+// broker is an in-memory implementation of migrate.Channel,
+// storing the messages of each declared queue.
+type broker struct {
+	queues map[string][]amqp.Delivery
+	// locked contains the exclusive queues declared by other connections.
+	locked map[string]bool
+
+	confirms  chan amqp.Confirmation
+	returns   chan amqp.Return
+	published uint64
+}
+
+func newBroker() *broker {
+	return &broker{
+		queues: make(map[string][]amqp.Delivery),
+		locked: make(map[string]bool),
+	}
+}
+
+func (b *broker) versions(queue string) []int64 {
+	var versions []int64
+	for _, delivery := range b.queues[queue] {
+		versions = append(versions, delivery.Headers[migrate.HeaderVersion].(int64))
+	}
+
+	return versions
+}
+
+func (b *broker) ExchangeDeclare(string, string, bool, bool, bool, bool, amqp.Table) error {
+	return nil
+}
+
+func (b *broker) QueueDeclare(name string, _, _, exclusive, _ bool, args amqp.Table) (amqp.Queue, error) {
+	if exclusive && b.locked[name] {
+		return amqp.Queue{}, &amqp.Error{Code: amqp.ResourceLocked, Reason: "RESOURCE_LOCKED"}
+	}
+
+	if _, ok := b.queues[name]; !ok {
+		b.queues[name] = nil
+	}
+
+	return amqp.Queue{Name: name, Messages: len(b.queues[name])}, nil
+}
+
+func (b *broker) ExchangeBind(string, string, string, bool, amqp.Table) error   { return nil }
+func (b *broker) QueueBind(string, string, string, bool, amqp.Table) error      { return nil }
+func (b *broker) QueueUnbind(string, string, string, amqp.Table) error          { return nil }
+func (b *broker) ExchangeUnbind(string, string, string, bool, amqp.Table) error { return nil }
+func (b *broker) ExchangeDelete(string, bool, bool) error                       { return nil }
+func (b *broker) Tx() error                                                     { return nil }
+func (b *broker) TxCommit() error                                               { return nil }
+func (b *broker) TxRollback() error                                             { return nil }
+
+func (b *broker) QueueDelete(name string, _, _, _ bool) (int, error) {
+	purged := len(b.queues[name])
+	delete(b.queues, name)
+
+	return purged, nil
+}
+
+func (b *broker) Get(queue string, _ bool) (amqp.Delivery, bool, error) {
+	messages := b.queues[queue]
+	if len(messages) == 0 {
+		return amqp.Delivery{}, false, nil
+	}
+
+	delivery := messages[0]
+	delivery.MessageCount = uint32(len(messages) - 1)
+	delivery.Acknowledger = acknowledger{broker: b, queue: queue, delivery: delivery}
+	b.queues[queue] = messages[1:]
+
+	return delivery, true, nil
+}
+
+func (b *broker) Confirm(bool) error { return nil }
+
+func (b *broker) NotifyPublish(confirms chan amqp.Confirmation) chan amqp.Confirmation {
+	b.confirms = confirms
+	return confirms
+}
+
+func (b *broker) NotifyReturn(returns chan amqp.Return) chan amqp.Return {
+	b.returns = returns
+	return returns
+}
+
+func (b *broker) Publish(exchange, key string, mandatory, _ bool, msg amqp.Publishing) error {
+	if exchange != "" {
+		return errors.New("only the default exchange is supported")
+	}
+
+	b.published++
+	confirmation := amqp.Confirmation{DeliveryTag: b.published, Ack: true}
+
+	queue, ok := b.queues[key]
+	if !ok {
+		if mandatory {
+			b.returns <- amqp.Return{ReplyCode: amqp.NoRoute, ReplyText: "NO_ROUTE", RoutingKey: key}
+		}
+
+		b.confirms <- confirmation
+
+		return nil
+	}
+
+	defer func() { b.confirms <- confirmation }()
+
+	// Marker queues keep only the last message.
+	if _, marker := msg.Headers[migrate.HeaderVersion]; marker {
+		queue = nil
+	}
+
+	b.queues[key] = append(queue, amqp.Delivery{
+		Headers:    msg.Headers,
+		MessageId:  msg.MessageId,
+		RoutingKey: key,
+		Body:       msg.Body,
+	})
+
+	return nil
+}
+
+type acknowledger struct {
+	broker   *broker
+	queue    string
+	delivery amqp.Delivery
+}
+
+func (acknowledger) Ack(uint64, bool) error { return nil }
+
+func (ack acknowledger) Nack(_ uint64, _ bool, requeue bool) error {
+	if requeue {
+		ack.broker.queues[ack.queue] = append([]amqp.Delivery{ack.delivery}, ack.broker.queues[ack.queue]...)
+	}
+
+	return nil
+}
+
+func (ack acknowledger) Reject(tag uint64, requeue bool) error {
+	return ack.Nack(tag, false, requeue)
+}
@@ -0,0 +1,217 @@
+package migrate
+
+import (
+	"fmt"
+
+	"github.com/ar3s3ru/go-carrot/internal/republish"
+	"github.com/ar3s3ru/go-carrot/topology"
+)
+
+// Step is a single operation of a Migration.
+type Step interface {
+	Apply(Channel) error
+}
+
+// StepFunc is a function that implements the Step interface, useful to specify
+// custom Steps inline as simple functions.
+type StepFunc func(Channel) error
+
+// Apply calls the underlying function to apply the Step.
+func (fn StepFunc) Apply(ch Channel) error { return fn(ch) }
+
+type declareStep struct {
+	declarer topology.Declarer
+}
+
+// Declare declares the topology described by the Declarer.
+func Declare(declarer topology.Declarer) Step {
+	return declareStep{declarer: declarer}
+}
+
+func (step declareStep) Apply(ch Channel) error {
+	return step.declarer.Declare(ch)
+}
+
+func (step declareStep) describe(spec topology.Spec) topology.Spec {
+	described, err := topology.Describe(step.declarer)
+	if err != nil {
+		return spec
+	}
+
+	return spec.Merge(described)
+}
+
+type deleteQueueStep struct {
+	name string
+}
+
+// DeleteQueue deletes the queue, together with all its messages and bindings.
+func DeleteQueue(name string) Step {
+	return deleteQueueStep{name: name}
+}
+
+func (step deleteQueueStep) Apply(ch Channel) error {
+	if _, err := ch.QueueDelete(step.name, false, false, false); err != nil {
+		return fmt.Errorf("failed to delete queue %q, %w", step.name, err)
+	}
+
+	return nil
+}
+
+func (step deleteQueueStep) describe(spec topology.Spec) topology.Spec {
+	described := topology.Spec{Exchanges: spec.Exchanges}
+
+	for _, queue := range spec.Queues {
+		if queue.Name != step.name {
+			described.Queues = append(described.Queues, queue)
+		}
+	}
+
+	for _, binding := range spec.Bindings {
+		if binding.DestinationType != topology.QueueDestination || binding.Destination != step.name {
+			described.Bindings = append(described.Bindings, binding)
+		}
+	}
+
+	return described
+}
+
+type deleteExchangeStep struct {
+	name string
+}
+
+// DeleteExchange deletes the exchange, together with all its bindings.
+func DeleteExchange(name string) Step {
+	return deleteExchangeStep{name: name}
+}
+
+func (step deleteExchangeStep) Apply(ch Channel) error {
+	if err := ch.ExchangeDelete(step.name, false, false); err != nil {
+		return fmt.Errorf("failed to delete exchange %q, %w", step.name, err)
+	}
+
+	return nil
+}
+
+func (step deleteExchangeStep) describe(spec topology.Spec) topology.Spec {
+	described := topology.Spec{Queues: spec.Queues}
+
+	for _, exchange := range spec.Exchanges {
+		if exchange.Name != step.name {
+			described.Exchanges = append(described.Exchanges, exchange)
+		}
+	}
+
+	for _, binding := range spec.Bindings {
+		toExchange := binding.DestinationType == topology.ExchangeDestination && binding.Destination == step.name
+		if binding.Source != step.name && !toExchange {
+			described.Bindings = append(described.Bindings, binding)
+		}
+	}
+
+	return described
+}
+
+type unbindStep struct {
+	binding topology.BindingSpec
+}
+
+// UnbindQueue removes the binding of the queue to the exchange,
+// with the specified routing key.
+func UnbindQueue(queue, exchange, routingKey string) Step {
+	return unbindStep{binding: topology.BindingSpec{
+		Source:          exchange,
+		Destination:     queue,
+		DestinationType: topology.QueueDestination,
+		RoutingKey:      routingKey,
+	}}
+}
+
+// UnbindExchange removes the binding of the destination exchange to
+// the source exchange, with the specified routing key.
+func UnbindExchange(destination, source, routingKey string) Step {
+	return unbindStep{binding: topology.BindingSpec{
+		Source:          source,
+		Destination:     destination,
+		DestinationType: topology.ExchangeDestination,
+		RoutingKey:      routingKey,
+	}}
+}
+
+func (step unbindStep) Apply(ch Channel) error {
+	var err error
+
+	binding := step.binding
+
+	switch binding.DestinationType {
+	case topology.ExchangeDestination:
+		err = ch.ExchangeUnbind(binding.Destination, binding.RoutingKey, binding.Source, false, nil)
+	default:
+		err = ch.QueueUnbind(binding.Destination, binding.RoutingKey, binding.Source, nil)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to unbind %q from %q, %w", binding.Destination, binding.Source, err)
+	}
+
+	return nil
+}
+
+func (step unbindStep) describe(spec topology.Spec) topology.Spec {
+	described := topology.Spec{Exchanges: spec.Exchanges, Queues: spec.Queues}
+
+	for _, binding := range spec.Bindings {
+		if binding.Source != step.binding.Source ||
+			binding.Destination != step.binding.Destination ||
+			binding.DestinationType != step.binding.DestinationType ||
+			binding.RoutingKey != step.binding.RoutingKey {
+			described.Bindings = append(described.Bindings, binding)
+		}
+	}
+
+	return described
+}
+
+// MoveMessages moves all the messages in the queue to the specified exchange,
+// keeping their properties and headers. If routingKey is empty, the original
+// routing key of each message is used.
+//
+// Only the messages in the queue when the Step starts are moved, so that
+// the Step terminates even if messages keep being published to the queue.
+func MoveMessages(queue, exchange, routingKey string) Step {
+	return StepFunc(func(ch Channel) error {
+		for moved, remaining := 0, 1; moved < remaining; moved++ {
+			delivery, ok, err := ch.Get(queue, false)
+			if err != nil {
+				return fmt.Errorf("failed to get message from %q, %w", queue, err)
+			}
+
+			if !ok {
+				return nil
+			}
+
+			if moved == 0 {
+				remaining = int(delivery.MessageCount) + 1
+			}
+
+			key := routingKey
+			if key == "" {
+				key = delivery.RoutingKey
+			}
+
+			// Messages are acknowledged only once the broker confirmed
+			// they've been routed to a queue.
+			if err := ch.Publish(exchange, key, true, false, republish.Publishing(delivery)); err != nil {
+				// nolint:errcheck
+				delivery.Reject(true)
+				return fmt.Errorf("failed to move message to %q, %w", exchange, err)
+			}
+
+			if err := delivery.Ack(false); err != nil {
+				return fmt.Errorf("failed to acknowledge moved message, %w", err)
+			}
+		}
+
+		return nil
+	})
+}
@@ -28,41 +28,60 @@ type Declarer struct {
 
 // Declare declares the topology of the queue using the supplied AMQP channel.
 func (d Declarer) Declare(ch topology.Channel) error {
-	_, err := ch.QueueDeclare(d.name, d.durable, d.autoDelete, d.exclusive, d.noWait, d.args)
-	if err != nil {
-		return err
+	return d.Describe().Declare(ch)
+}
+
+// Describe returns the Spec of the queue, including its bindings
+// and dead-letter queue, if any.
+func (d Declarer) Describe() topology.Spec {
+	spec := topology.Spec{
+		Queues: []topology.QueueSpec{{
+			Name:        d.name,
+			Description: d.description,
+			Durable:     d.durable,
+			AutoDelete:  d.autoDelete,
+			Exclusive:   d.exclusive,
+			NoWait:      d.noWait,
+			Arguments:   d.args,
+		}},
 	}
 
-	if len(d.bindings) > 0 {
-		if err = d.bindAll(ch); err != nil {
-			return err
-		}
+	for _, binding := range d.bindings {
+		spec.Bindings = append(spec.Bindings, topology.BindingSpec{
+			Source:          binding.exchange,
+			Destination:     d.name,
+			DestinationType: topology.QueueDestination,
+			RoutingKey:      binding.routingKey,
+			NoWait:          d.noWait,
+		})
 	}
 
 	if dlq := d.deadLetterQueue; dlq != nil {
-		err = dlq.Declare(ch)
-		if err != nil {
-			return err
-		}
+		spec = spec.Merge(dlq.Describe())
 	}
 
-	return nil
+	return spec
 }
 
-type binding struct {
-	exchange   string
-	routingKey string
-}
+// Name returns the name of the queue.
+func (d Declarer) Name() string { return d.name }
 
-func (d Declarer) bindAll(ch topology.Channel) error {
-	for _, binding := range d.bindings {
-		err := ch.QueueBind(d.name, binding.routingKey, binding.exchange, d.noWait, nil)
-		if err != nil {
-			return err
-		}
+// Description returns the description of the queue, if any.
+func (d Declarer) Description() string { return d.description }
+
+// Declarers returns the dead-letter queue declared together with the queue,
+// if the DeadLetterWithQueue option has been used.
+func (d Declarer) Declarers() []topology.Declarer {
+	if d.deadLetterQueue == nil {
+		return nil
 	}
 
-	return nil
+	return []topology.Declarer{*d.deadLetterQueue}
+}
+
+type binding struct {
+	exchange   string
+	routingKey string
 }
 
 func (d *Declarer) addToTable(key string, value interface{}) {
@@ -147,7 +166,8 @@ func DeadLetter(exchange, routingKey string) Option {
 // will be binded to the specified exchange and routing key.
 //
 // Useful to persist failed messages in a specified queue and consuming
-// messages from such queue from the application with a compensating action.
+// messages from such queue from the application with a compensating action,
+// e.g. using the deadletter package.
 func DeadLetterWithQueue(exchange, routingKey string, dlq Declarer) Option {
 	return func(queue *Declarer) {
 		DeadLetter(exchange, routingKey)(queue)
@@ -0,0 +1,265 @@
+package topology
+
+import (
+	"fmt"
+
+	"github.com/streadway/amqp"
+)
+
+// Spec is a read-only model of a topology, made of exchanges, queues
+// and the bindings between them.
+//
+// Spec implements the Declarer interface, by declaring all the exchanges first,
+// then all the queues and finally all the bindings.
+type Spec struct {
+	Exchanges []ExchangeSpec
+	Queues    []QueueSpec
+	Bindings  []BindingSpec
+}
+
+// ExchangeSpec describes an AMQP exchange.
+type ExchangeSpec struct {
+	Name       string
+	Kind       string
+	Durable    bool
+	AutoDelete bool
+	Internal   bool
+	NoWait     bool
+	Arguments  amqp.Table
+}
+
+// QueueSpec describes an AMQP queue.
+type QueueSpec struct {
+	Name        string
+	Description string
+	Durable     bool
+	AutoDelete  bool
+	Exclusive   bool
+	NoWait      bool
+	Arguments   amqp.Table
+}
+
+// DeadLetterExchange returns the dead-letter exchange of the queue,
+// specified with the "x-dead-letter-exchange" argument.
+func (queue QueueSpec) DeadLetterExchange() (string, bool) {
+	exchange, ok := queue.Arguments["x-dead-letter-exchange"].(string)
+	return exchange, ok
+}
+
+// DeadLetterRoutingKey returns the routing key used for dead-lettered messages,
+// specified with the "x-dead-letter-routing-key" argument.
+func (queue QueueSpec) DeadLetterRoutingKey() (string, bool) {
+	key, ok := queue.Arguments["x-dead-letter-routing-key"].(string)
+	return key, ok
+}
+
+// DestinationType is the type of the destination of a binding.
+type DestinationType string
+
+// Supported binding destinations.
+const (
+	QueueDestination    DestinationType = "queue"
+	ExchangeDestination DestinationType = "exchange"
+)
+
+// BindingSpec describes a binding from a source exchange to a destination,
+// either a queue or another exchange.
+type BindingSpec struct {
+	Source          string
+	Destination     string
+	DestinationType DestinationType
+	RoutingKey      string
+	NoWait          bool
+	Arguments       amqp.Table
+}
+
+// Describer is implemented by those Declarers able to describe the topology
+// they declare as a Spec.
+type Describer interface {
+	Describe() Spec
+}
+
+// Describe returns the Spec of the topology declared by the Declarer.
+//
+// Declarers not implementing Describer are described by recording
+// the declarations they perform on a channel that doesn't communicate
+// with the AMQP broker.
+func Describe(declarer Declarer) (Spec, error) {
+	switch d := declarer.(type) {
+	case nil:
+		return Spec{}, nil
+	case group:
+		return d.describe()
+	case Describer:
+		return d.Describe(), nil
+	}
+
+	rec := new(recorder)
+
+	if err := declarer.Declare(rec); err != nil {
+		return Spec{}, fmt.Errorf("topology.Describe: failed to record topology, %w", err)
+	}
+
+	return rec.spec, nil
+}
+
+// Describe returns the Spec itself.
+func (spec Spec) Describe() Spec { return spec }
+
+// Merge returns a new Spec containing the topology of both the Spec
+// and the provided ones.
+func (spec Spec) Merge(others ...Spec) Spec {
+	merged := Spec{
+		Exchanges: append([]ExchangeSpec(nil), spec.Exchanges...),
+		Queues:    append([]QueueSpec(nil), spec.Queues...),
+		Bindings:  append([]BindingSpec(nil), spec.Bindings...),
+	}
+
+	for _, other := range others {
+		merged.Exchanges = append(merged.Exchanges, other.Exchanges...)
+		merged.Queues = append(merged.Queues, other.Queues...)
+		merged.Bindings = append(merged.Bindings, other.Bindings...)
+	}
+
+	return merged
+}
+
+// Exchange returns the exchange with the specified name, if any.
+func (spec Spec) Exchange(name string) (ExchangeSpec, bool) {
+	for _, exchange := range spec.Exchanges {
+		if exchange.Name == name {
+			return exchange, true
+		}
+	}
+
+	return ExchangeSpec{}, false
+}
+
+// Queue returns the queue with the specified name, if any.
+func (spec Spec) Queue(name string) (QueueSpec, bool) {
+	for _, queue := range spec.Queues {
+		if queue.Name == name {
+			return queue, true
+		}
+	}
+
+	return QueueSpec{}, false
+}
+
+// Declare declares all the exchanges first, then all the queues and finally
+// all the bindings of the Spec, using the supplied AMQP channel.
+func (spec Spec) Declare(ch Channel) error {
+	for _, exchange := range spec.Exchanges {
+		if err := ch.ExchangeDeclare(
+			exchange.Name,
+			exchange.Kind,
+			exchange.Durable,
+			exchange.AutoDelete,
+			exchange.Internal,
+			exchange.NoWait,
+			exchange.Arguments,
+		); err != nil {
+			return err
+		}
+	}
+
+	for _, queue := range spec.Queues {
+		if _, err := ch.QueueDeclare(
+			queue.Name,
+			queue.Durable,
+			queue.AutoDelete,
+			queue.Exclusive,
+			queue.NoWait,
+			queue.Arguments,
+		); err != nil {
+			return err
+		}
+	}
+
+	for _, binding := range spec.Bindings {
+		var err error
+
+		switch binding.DestinationType {
+		case ExchangeDestination:
+			err = ch.ExchangeBind(binding.Destination, binding.RoutingKey, binding.Source, binding.NoWait, binding.Arguments)
+		default:
+			err = ch.QueueBind(binding.Destination, binding.RoutingKey, binding.Source, binding.NoWait, binding.Arguments)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// recorder is a Channel recording all the declarations in a Spec.
+type recorder struct {
+	spec Spec
+}
+
+func (*recorder) Tx() error         { return nil }
+func (*recorder) TxCommit() error   { return nil }
+func (*recorder) TxRollback() error { return nil }
+
+func (rec *recorder) QueueDeclare(
+	name string,
+	durable, autoDelete, exclusive, noWait bool,
+	args amqp.Table,
+) (amqp.Queue, error) {
+	rec.spec.Queues = append(rec.spec.Queues, QueueSpec{
+		Name:       name,
+		Durable:    durable,
+		AutoDelete: autoDelete,
+		Exclusive:  exclusive,
+		NoWait:     noWait,
+		Arguments:  args,
+	})
+
+	return amqp.Queue{Name: name}, nil
+}
+
+func (rec *recorder) QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error {
+	rec.spec.Bindings = append(rec.spec.Bindings, BindingSpec{
+		Source:          exchange,
+		Destination:     name,
+		DestinationType: QueueDestination,
+		RoutingKey:      key,
+		NoWait:          noWait,
+		Arguments:       args,
+	})
+
+	return nil
+}
+
+func (rec *recorder) ExchangeBind(destination, key, source string, noWait bool, args amqp.Table) error {
+	rec.spec.Bindings = append(rec.spec.Bindings, BindingSpec{
+		Source:          source,
+		Destination:     destination,
+		DestinationType: ExchangeDestination,
+		RoutingKey:      key,
+		NoWait:          noWait,
+		Arguments:       args,
+	})
+
+	return nil
+}
+
+func (rec *recorder) ExchangeDeclare(
+	name, kind string,
+	durable, autoDelete, internal, noWait bool,
+	args amqp.Table,
+) error {
+	rec.spec.Exchanges = append(rec.spec.Exchanges, ExchangeSpec{
+		Name:       name,
+		Kind:       kind,
+		Durable:    durable,
+		AutoDelete: autoDelete,
+		Internal:   internal,
+		NoWait:     noWait,
+		Arguments:  args,
+	})
+
+	return nil
+}
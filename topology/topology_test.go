@@ -6,6 +6,7 @@ import (
 
 	"github.com/ar3s3ru/go-carrot/topology/mocks"
 
+	"github.com/streadway/amqp"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -62,3 +63,67 @@ func TestAll(t *testing.T) {
 		assert.False(t, called2)
 	})
 }
+
+func TestWalk(t *testing.T) {
+	first := declarerFunc(func(Channel) error { return nil })
+	second := declarerFunc(func(Channel) error { return nil })
+
+	var walked int
+
+	Walk(All(first, All(second)), func(Declarer) { walked++ })
+
+	// Both groups, and both declarers inside them.
+	assert.Equal(t, 4, walked)
+}
+
+func TestDescribe(t *testing.T) {
+	described := Spec{
+		Exchanges: []ExchangeSpec{{Name: "orders", Kind: "topic", Durable: true}},
+	}
+
+	recorded := declarerFunc(func(ch Channel) error {
+		if _, err := ch.QueueDeclare("orders-created", true, false, false, false, nil); err != nil {
+			return err
+		}
+
+		return ch.QueueBind("orders-created", "created", "orders", false, nil)
+	})
+
+	spec, err := Describe(All(described, recorded))
+	assert.NoError(t, err)
+	assert.Equal(t, Spec{
+		Exchanges: []ExchangeSpec{{Name: "orders", Kind: "topic", Durable: true}},
+		Queues:    []QueueSpec{{Name: "orders-created", Durable: true}},
+		Bindings: []BindingSpec{{
+			Source:          "orders",
+			Destination:     "orders-created",
+			DestinationType: QueueDestination,
+			RoutingKey:      "created",
+		}},
+	}, spec)
+
+	assert.Equal(t, spec, All(described, recorded).(Describer).Describe())
+
+	_, err = Describe(All(declarerFunc(func(Channel) error { return errors.New("failed") })))
+	assert.Error(t, err)
+}
+
+func TestSpec_Declare(t *testing.T) {
+	spec := Spec{
+		Exchanges: []ExchangeSpec{{Name: "orders", Kind: "topic"}},
+		Queues:    []QueueSpec{{Name: "orders-created", Durable: true}},
+		Bindings: []BindingSpec{
+			{Source: "orders", Destination: "orders-created", DestinationType: QueueDestination, RoutingKey: "created"},
+			{Source: "orders", Destination: "audit", DestinationType: ExchangeDestination, RoutingKey: "#"},
+		},
+	}
+
+	ch := new(mocks.Channel)
+	ch.On("ExchangeDeclare", "orders", "topic", false, false, false, false, amqp.Table(nil)).Return(nil).Once()
+	ch.On("QueueDeclare", "orders-created", true, false, false, false, amqp.Table(nil)).Return(amqp.Queue{}, nil).Once()
+	ch.On("QueueBind", "orders-created", "created", "orders", false, amqp.Table(nil)).Return(nil).Once()
+	ch.On("ExchangeBind", "audit", "#", "orders", false, amqp.Table(nil)).Return(nil).Once()
+
+	assert.NoError(t, spec.Declare(ch))
+	ch.AssertExpectations(t)
+}
@@ -0,0 +1,292 @@
+package topology
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/streadway/amqp"
+)
+
+// ProblemKind identifies the kind of a Problem found by Validate.
+type ProblemKind string
+
+// Supported problem kinds.
+const (
+	// DanglingReference is reported for bindings and dead-letter exchanges
+	// referencing exchanges or queues that are not declared in the topology.
+	// Only reported in Strict mode.
+	DanglingReference ProblemKind = "dangling-reference"
+	// ConflictingDeclaration is reported for exchanges or queues declared
+	// more than once, with different flags or arguments.
+	ConflictingDeclaration ProblemKind = "conflicting-declaration"
+	// InvalidExchangeKind is reported for exchanges declared with a kind
+	// not supported by the AMQP broker.
+	InvalidExchangeKind ProblemKind = "invalid-exchange-kind"
+	// InvalidBindingKey is reported for binding keys not valid for the kind
+	// of the source exchange.
+	InvalidBindingKey ProblemKind = "invalid-binding-key"
+)
+
+// Problem is an issue found in a topology by Validate.
+type Problem struct {
+	Kind    ProblemKind
+	Message string
+}
+
+func (problem Problem) String() string {
+	return fmt.Sprintf("%s: %s", problem.Kind, problem.Message)
+}
+
+// ValidationError is returned by Validate when problems have been found
+// in the topology.
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (err *ValidationError) Error() string {
+	problems := make([]string, 0, len(err.Problems))
+	for _, problem := range err.Problems {
+		problems = append(problems, problem.String())
+	}
+
+	return fmt.Sprintf("topology.Validate: %d problems found (%s)", len(problems), strings.Join(problems, "; "))
+}
+
+// maxBindingKeyLength is the maximum length, in bytes, of a binding key.
+const maxBindingKeyLength = 255
+
+type validation struct {
+	strict    bool
+	exchanges map[string]bool
+	queues    map[string]bool
+}
+
+// Validate validates the topology declared by the Declarer, without
+// communicating with the AMQP broker, returning a *ValidationError
+// with all the problems found, if any.
+//
+// Exchanges and queues declared more than once must be declared with
+// the same flags and arguments. Exchanges must be of a kind supported by
+// the AMQP broker, either a standard one or a plugin one, starting with "x-";
+// binding keys must be valid for the kind of the source exchange, if declared.
+//
+// In Strict mode, bindings and dead-letter exchanges must also refer to
+// exchanges and queues declared in the topology, the default and "amq."
+// exchanges, or the ones specified with KnownExchanges and KnownQueues.
+func Validate(declarer Declarer, options ...ValidateOption) error {
+	v := validation{
+		exchanges: make(map[string]bool),
+		queues:    make(map[string]bool),
+	}
+
+	for _, option := range options {
+		if option == nil {
+			continue
+		}
+
+		option(&v)
+	}
+
+	spec, err := Describe(declarer)
+	if err != nil {
+		return err
+	}
+
+	var problems []Problem
+
+	exchanges := make(map[string]ExchangeSpec)
+
+	for _, exchange := range spec.Exchanges {
+		if !validExchangeKind(exchange.Kind) {
+			problems = append(problems, Problem{
+				Kind:    InvalidExchangeKind,
+				Message: fmt.Sprintf("exchange %q has invalid kind %q", exchange.Name, exchange.Kind),
+			})
+		}
+
+		if declared, ok := exchanges[exchange.Name]; ok && !sameExchange(declared, exchange) {
+			problems = append(problems, Problem{
+				Kind:    ConflictingDeclaration,
+				Message: fmt.Sprintf("exchange %q is declared more than once with different options", exchange.Name),
+			})
+		}
+
+		exchanges[exchange.Name] = exchange
+	}
+
+	queues := make(map[string]QueueSpec)
+
+	for _, queue := range spec.Queues {
+		if declared, ok := queues[queue.Name]; ok && !sameQueue(declared, queue) {
+			problems = append(problems, Problem{
+				Kind:    ConflictingDeclaration,
+				Message: fmt.Sprintf("queue %q is declared more than once with different options", queue.Name),
+			})
+		}
+
+		queues[queue.Name] = queue
+	}
+
+	for _, binding := range spec.Bindings {
+		source, ok := exchanges[binding.Source]
+
+		switch {
+		case ok && source.Kind == "topic":
+			if err := validateTopicBindingKey(binding.RoutingKey); err != nil {
+				problems = append(problems, Problem{
+					Kind:    InvalidBindingKey,
+					Message: fmt.Sprintf("binding from %q to %q: %s", binding.Source, binding.Destination, err),
+				})
+			}
+
+		case len(binding.RoutingKey) > maxBindingKeyLength:
+			problems = append(problems, Problem{
+				Kind:    InvalidBindingKey,
+				Message: fmt.Sprintf("binding from %q to %q: key longer than 255 bytes", binding.Source, binding.Destination),
+			})
+		}
+	}
+
+	if v.strict {
+		problems = append(problems, v.danglingReferences(spec, exchanges, queues)...)
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+
+	return nil
+}
+
+func (v validation) danglingReferences(
+	spec Spec,
+	exchanges map[string]ExchangeSpec,
+	queues map[string]QueueSpec,
+) []Problem {
+	var problems []Problem
+
+	exchangeExists := func(name string) bool {
+		_, declared := exchanges[name]
+		return declared || v.exchanges[name] || predeclaredExchange(name)
+	}
+
+	dangling := func(format string, args ...interface{}) {
+		problems = append(problems, Problem{Kind: DanglingReference, Message: fmt.Sprintf(format, args...)})
+	}
+
+	for _, binding := range spec.Bindings {
+		if !exchangeExists(binding.Source) {
+			dangling("binding to %q references undeclared exchange %q", binding.Destination, binding.Source)
+		}
+
+		switch binding.DestinationType {
+		case ExchangeDestination:
+			if !exchangeExists(binding.Destination) {
+				dangling("binding from %q references undeclared exchange %q", binding.Source, binding.Destination)
+			}
+
+		default:
+			if _, declared := queues[binding.Destination]; !declared && !v.queues[binding.Destination] {
+				dangling("binding from %q references undeclared queue %q", binding.Source, binding.Destination)
+			}
+		}
+	}
+
+	for _, queue := range spec.Queues {
+		if exchange, ok := queue.DeadLetterExchange(); ok && !exchangeExists(exchange) {
+			dangling("queue %q references undeclared dead-letter exchange %q", queue.Name, exchange)
+		}
+	}
+
+	return problems
+}
+
+func predeclaredExchange(name string) bool {
+	return name == "" || strings.HasPrefix(name, "amq.")
+}
+
+func validExchangeKind(kind string) bool {
+	switch kind {
+	case "direct", "fanout", "topic", "headers":
+		return true
+	default:
+		return strings.HasPrefix(kind, "x-")
+	}
+}
+
+// sameExchange reports whether the two exchange declarations are equivalent
+// for the AMQP broker.
+func sameExchange(a, b ExchangeSpec) bool {
+	a.NoWait, b.NoWait = false, false
+	a.Arguments, b.Arguments = nilIfEmpty(a.Arguments), nilIfEmpty(b.Arguments)
+
+	return reflect.DeepEqual(a, b)
+}
+
+// sameQueue reports whether the two queue declarations are equivalent
+// for the AMQP broker.
+func sameQueue(a, b QueueSpec) bool {
+	a.NoWait, b.NoWait = false, false
+	a.Description, b.Description = "", ""
+	a.Arguments, b.Arguments = nilIfEmpty(a.Arguments), nilIfEmpty(b.Arguments)
+
+	return reflect.DeepEqual(a, b)
+}
+
+func nilIfEmpty(args amqp.Table) amqp.Table {
+	if len(args) == 0 {
+		return nil
+	}
+
+	return args
+}
+
+// validateTopicBindingKey validates a binding key for a topic exchange:
+// a list of words delimited by dots, where "*" and "#" can only be used
+// as whole words.
+func validateTopicBindingKey(key string) error {
+	if len(key) > maxBindingKeyLength {
+		return fmt.Errorf("key longer than %d bytes", maxBindingKeyLength)
+	}
+
+	for _, word := range strings.Split(key, ".") {
+		if word == "*" || word == "#" {
+			continue
+		}
+
+		if strings.ContainsAny(word, "*#") {
+			return fmt.Errorf("invalid key %q, wildcards must be whole words", key)
+		}
+	}
+
+	return nil
+}
+
+// ValidateOption is an optional functionality that can be added
+// to the Validate function.
+type ValidateOption func(*validation)
+
+// Strict enables the validation of references to exchanges and queues
+// from bindings and dead-letter exchanges.
+var Strict ValidateOption = func(v *validation) { v.strict = true }
+
+// KnownExchanges specifies exchanges declared outside of the validated topology,
+// e.g. by other applications, which can be referenced in Strict mode.
+func KnownExchanges(names ...string) ValidateOption {
+	return func(v *validation) {
+		for _, name := range names {
+			v.exchanges[name] = true
+		}
+	}
+}
+
+// KnownQueues specifies queues declared outside of the validated topology,
+// e.g. by other applications, which can be referenced in Strict mode.
+func KnownQueues(names ...string) ValidateOption {
+	return func(v *validation) {
+		for _, name := range names {
+			v.queues[name] = true
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package topology_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ar3s3ru/go-carrot/topology"
+	"github.com/ar3s3ru/go-carrot/topology/exchange"
+	"github.com/ar3s3ru/go-carrot/topology/exchange/kind"
+	"github.com/ar3s3ru/go-carrot/topology/queue"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	testcases := map[string]struct {
+		declarer topology.Declarer
+		options  []topology.ValidateOption
+		problems []topology.ProblemKind
+	}{
+		"valid topology": {
+			declarer: topology.All(
+				exchange.Declare("orders", exchange.Kind(kind.Topic)),
+				exchange.Declare("delayed", exchange.Kind("x-delayed-message")),
+				queue.Declare("orders-created", queue.BindTo("orders", "orders.*.created.#")),
+				queue.Declare("orders-created", queue.Description("declared twice, same options")),
+			),
+		},
+		"dangling references are valid in non-strict mode": {
+			declarer: queue.Declare("orders-created",
+				queue.BindTo("orders", "created"),
+				queue.DeadLetter("orders-dlx", "created"),
+			),
+		},
+		"dangling references are reported in strict mode": {
+			declarer: topology.All(
+				exchange.Declare("audit", exchange.BindTo("orders", "#")),
+				queue.Declare("orders-created",
+					queue.BindTo("orders", "created"),
+					queue.DeadLetter("orders-dlx", "created"),
+				),
+			),
+			options: []topology.ValidateOption{topology.Strict},
+			problems: []topology.ProblemKind{
+				topology.DanglingReference,
+				topology.DanglingReference,
+				topology.DanglingReference,
+			},
+		},
+		"predeclared and known exchanges are not dangling": {
+			declarer: topology.All(
+				queue.Declare("orders-created",
+					queue.BindTo("amq.topic", "created"),
+					queue.BindTo("orders", "created"),
+					queue.DeadLetter("", "orders-created-dlq"),
+				),
+			),
+			options: []topology.ValidateOption{topology.Strict, topology.KnownExchanges("orders")},
+		},
+		"conflicting declarations": {
+			declarer: topology.All(
+				exchange.Declare("orders", exchange.Kind(kind.Topic)),
+				exchange.Declare("orders", exchange.Kind(kind.Direct)),
+				queue.Declare("orders-created", queue.Durable),
+				queue.Declare("orders-created", queue.Arguments(amqp.Table{"x-queue-type": "quorum"})),
+			),
+			problems: []topology.ProblemKind{
+				topology.ConflictingDeclaration,
+				topology.ConflictingDeclaration,
+			},
+		},
+		"invalid exchange kinds": {
+			declarer: exchange.Declare("orders", exchange.Kind(kind.Internal)),
+			problems: []topology.ProblemKind{topology.InvalidExchangeKind},
+		},
+		"invalid topic binding keys": {
+			declarer: topology.All(
+				exchange.Declare("orders", exchange.Kind(kind.Topic)),
+				exchange.Declare("payments", exchange.Kind(kind.Direct)),
+				queue.Declare("orders-created",
+					queue.BindTo("orders", "orders.created*"),
+					queue.BindTo("payments", "payments.created*"),
+				),
+			),
+			problems: []topology.ProblemKind{topology.InvalidBindingKey},
+		},
+	}
+
+	for name, tc := range testcases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			err := topology.Validate(tc.declarer, tc.options...)
+
+			if len(tc.problems) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+
+			var validationErr *topology.ValidationError
+			if !assert.True(t, errors.As(err, &validationErr)) {
+				return
+			}
+
+			kinds := make([]topology.ProblemKind, 0, len(validationErr.Problems))
+			for _, problem := range validationErr.Problems {
+				kinds = append(kinds, problem.Kind)
+			}
+
+			assert.Equal(t, tc.problems, kinds)
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package topology
+
+// Walk calls the provided function for the Declarer and, recursively,
+// for all the Declarers it contains, such as the ones grouped with All.
+//
+// Declarers containing other Declarers expose them with a Declarers method.
+func Walk(declarer Declarer, fn func(Declarer)) {
+	if declarer == nil {
+		return
+	}
+
+	fn(declarer)
+
+	parent, ok := declarer.(interface{ Declarers() []Declarer })
+	if !ok {
+		return
+	}
+
+	for _, child := range parent.Declarers() {
+		Walk(child, fn)
+	}
+}